@@ -2,12 +2,12 @@ package handler
 
 import (
 	"context"
+	"errors"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
 
-	corev1 "k8s.io/api/core/v1"
-
+	"learn/biz/middleware"
 	"learn/biz/model"
 	"learn/biz/service"
 )
@@ -25,6 +25,21 @@ func AppCreate(ctx context.Context, c *app.RequestContext) {
 	}
 
 	deployment, err := service.NewAppService(ctx, c).CreateApp(&appParam)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "ok",
+		Data:       deployment,
+	})
+}
+
+func AppClone(ctx context.Context, c *app.RequestContext) {
+	var appParam model.AppParam
+	err := c.BindAndValidate(&appParam)
 	if err != nil {
 		c.JSON(consts.StatusOK, model.Response{
 			StatusCode: consts.StatusInternalServerError,
@@ -33,6 +48,12 @@ func AppCreate(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	deployment, err := service.NewAppService(ctx, c).CloneApp(appParam.Deployment, appParam.Name)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
 	c.JSON(consts.StatusOK, model.Response{
 		StatusCode: consts.StatusOK,
 		Message:    "ok",
@@ -40,7 +61,7 @@ func AppCreate(ctx context.Context, c *app.RequestContext) {
 	})
 }
 
-func AppStop(ctx context.Context, c *app.RequestContext) {
+func AppProvisionStatus(ctx context.Context, c *app.RequestContext) {
 	var appParam model.AppParam
 	err := c.BindAndValidate(&appParam)
 	if err != nil {
@@ -51,7 +72,22 @@ func AppStop(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	err = service.NewAppService(ctx, c).StopApp(&appParam)
+	record, err := service.NewProvisionService(ctx, c).GetProvisionStatus(appParam.Deployment)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       record,
+	})
+}
+
+func AppStop(ctx context.Context, c *app.RequestContext) {
+	var appParam model.AppParam
+	err := c.BindAndValidate(&appParam)
 	if err != nil {
 		c.JSON(consts.StatusOK, model.Response{
 			StatusCode: consts.StatusInternalServerError,
@@ -60,6 +96,12 @@ func AppStop(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	err = service.NewAppService(ctx, c).StopApp(&appParam)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
 	c.JSON(consts.StatusOK, model.Response{
 		StatusCode: consts.StatusOK,
 		Message:    "ok",
@@ -79,6 +121,20 @@ func AppRestart(ctx context.Context, c *app.RequestContext) {
 	}
 
 	err = service.NewAppService(ctx, c).RestartApp(&appParam)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "ok",
+	})
+}
+
+func AppUpdate(ctx context.Context, c *app.RequestContext) {
+	var appParam model.AppParam
+	err := c.BindAndValidate(&appParam)
 	if err != nil {
 		c.JSON(consts.StatusOK, model.Response{
 			StatusCode: consts.StatusInternalServerError,
@@ -87,16 +143,22 @@ func AppRestart(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	result, err := service.NewAppService(ctx, c).UpdateApp(&appParam)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
 	c.JSON(consts.StatusOK, model.Response{
 		StatusCode: consts.StatusOK,
 		Message:    "ok",
+		Data:       result,
 	})
 }
 
-func AppGetPodInfo(ctx context.Context, c *app.RequestContext) {
-	var kbParam model.KubernetesParam
-
-	err := c.BindAndValidate(&kbParam)
+func AppSetIdleTimeout(ctx context.Context, c *app.RequestContext) {
+	var appParam model.AppParam
+	err := c.BindAndValidate(&appParam)
 	if err != nil {
 		c.JSON(consts.StatusOK, model.Response{
 			StatusCode: consts.StatusInternalServerError,
@@ -105,7 +167,21 @@ func AppGetPodInfo(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	podInfo, err := service.NewAppService(ctx, c).GetStateOfApp(&kbParam)
+	err = service.NewAppService(ctx, c).SetIdleTimeout(&appParam)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "ok",
+	})
+}
+
+func AppGetPassword(ctx context.Context, c *app.RequestContext) {
+	var appParam model.AppParam
+	err := c.BindAndValidate(&appParam)
 	if err != nil {
 		c.JSON(consts.StatusOK, model.Response{
 			StatusCode: consts.StatusInternalServerError,
@@ -114,22 +190,22 @@ func AppGetPodInfo(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	password, err := service.NewAppService(ctx, c).GetPassword(&appParam)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
 	c.JSON(consts.StatusOK, model.Response{
 		StatusCode: consts.StatusOK,
-		Message:    "ok",
-		Data: struct {
-			Phase      corev1.PodPhase       `json:"phase"`
-			Conditions []corev1.PodCondition `json:"conditions"`
-		}{
-			Phase:      podInfo.Phase,
-			Conditions: podInfo.Conditions,
-		},
+		Message:    "查询成功",
+		Data:       password,
 	})
 }
 
-func AppList(ctx context.Context, c *app.RequestContext) {
+// AppRotatePassword 重置工作空间密码，pod_password 为空时随机生成，返回新密码
+func AppRotatePassword(ctx context.Context, c *app.RequestContext) {
 	var appParam model.AppParam
-
 	err := c.BindAndValidate(&appParam)
 	if err != nil {
 		c.JSON(consts.StatusOK, model.Response{
@@ -139,7 +215,59 @@ func AppList(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
-	applications, err := service.NewAppService(ctx, c).ListApp()
+	password, err := service.NewAppService(ctx, c).RotatePassword(&appParam)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "密码已重置",
+		Data:       password,
+	})
+}
+
+// AppGetPodInfo 查询应用的 Pod 状态，管理员可通过 raw=true 获取原始 Pod 对象
+func AppGetPodInfo(ctx context.Context, c *app.RequestContext) {
+	var kbParam model.KubernetesParam
+
+	err := c.BindAndValidate(&kbParam)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	if !checkRawAccess(ctx, c) {
+		return
+	}
+
+	var podInfo interface{}
+	if c.Query("raw") == "true" {
+		podInfo, err = service.NewAppService(ctx, c).GetPodOfApp(&kbParam)
+	} else {
+		podInfo, err = service.NewAppService(ctx, c).GetPodViewOfApp(&kbParam)
+	}
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "ok",
+		Data:       podInfo,
+	})
+}
+
+// AppList 查询当前用户的应用，支持按状态、名称、标签过滤，排序和游标分页
+func AppList(ctx context.Context, c *app.RequestContext) {
+	var param model.AppListParam
+
+	err := c.BindAndValidate(&param)
 	if err != nil {
 		c.JSON(consts.StatusOK, model.Response{
 			StatusCode: consts.StatusInternalServerError,
@@ -148,10 +276,21 @@ func AppList(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	result, err := service.NewAppService(ctx, c).ListApp(&param)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	// 不带参数的请求保持原来的应用数组格式，带参数时返回 {items, next_cursor}
+	var data interface{} = result
+	if param.IsEmpty() {
+		data = result.Items
+	}
 	c.JSON(consts.StatusOK, model.Response{
 		StatusCode: consts.StatusOK,
 		Message:    "查询成功",
-		Data:       applications,
+		Data:       data,
 	})
 
 }
@@ -170,10 +309,7 @@ func AppDelete(ctx context.Context, c *app.RequestContext) {
 
 	err = service.NewAppService(ctx, c).DeleteApp(&appParam)
 	if err != nil {
-		c.JSON(consts.StatusOK, model.Response{
-			StatusCode: consts.StatusInternalServerError,
-			Message:    err.Error(),
-		})
+		appError(c, err)
 		return
 	}
 
@@ -183,14 +319,21 @@ func AppDelete(ctx context.Context, c *app.RequestContext) {
 	})
 }
 
+// AppGetPodStateList 查询用户所有 Pod 的状态，管理员可通过 raw=true 获取原始 Pod 对象
 func AppGetPodStateList(ctx context.Context, c *app.RequestContext) {
+	if !checkRawAccess(ctx, c) {
+		return
+	}
 
-	podList, err := service.NewAppService(ctx, c).GetPodStateList()
+	var podList interface{}
+	var err error
+	if c.Query("raw") == "true" {
+		podList, err = service.NewAppService(ctx, c).GetPodStateList()
+	} else {
+		podList, err = service.NewAppService(ctx, c).GetPodViewList()
+	}
 	if err != nil {
-		c.JSON(consts.StatusOK, model.Response{
-			StatusCode: consts.StatusInternalServerError,
-			Message:    err.Error(),
-		})
+		appError(c, err)
 		return
 	}
 
@@ -201,6 +344,18 @@ func AppGetPodStateList(ctx context.Context, c *app.RequestContext) {
 	})
 }
 
+// checkRawAccess 原始 Pod 对象包含环境变量等敏感信息，只有管理员可以获取
+func checkRawAccess(ctx context.Context, c *app.RequestContext) bool {
+	if c.Query("raw") != "true" || middleware.IsAdmin(ctx, c) {
+		return true
+	}
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusForbidden,
+		Message:    "需要管理员权限",
+	})
+	return false
+}
+
 func AppGetLog(ctx context.Context, c *app.RequestContext) {
 	var appParam model.AppParam
 
@@ -214,6 +369,35 @@ func AppGetLog(ctx context.Context, c *app.RequestContext) {
 	}
 
 	logs, err := service.NewAppService(ctx, c).GetLogOfApp(&appParam)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       logs,
+	})
+}
+
+func AppGetUsage(ctx context.Context, c *app.RequestContext) {
+	usage, err := service.NewAppService(ctx, c).GetUsageOfApp()
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       usage,
+	})
+}
+
+// AppGetQuota 查询当前用户的套餐限额与用量
+func AppGetQuota(ctx context.Context, c *app.RequestContext) {
+	quota, err := service.NewAppService(ctx, c).GetQuota()
 	if err != nil {
 		c.JSON(consts.StatusOK, model.Response{
 			StatusCode: consts.StatusInternalServerError,
@@ -225,12 +409,15 @@ func AppGetLog(ctx context.Context, c *app.RequestContext) {
 	c.JSON(consts.StatusOK, model.Response{
 		StatusCode: consts.StatusOK,
 		Message:    "查询成功",
-		Data:       logs,
+		Data:       quota,
 	})
 }
 
-func AppGetUsage(ctx context.Context, c *app.RequestContext) {
-	usage, err := service.NewAppService(ctx, c).GetUsageOfApp()
+// AppGetEvents 查询应用最近的 Kubernetes 事件，用于排查工作空间无法启动的原因
+func AppGetEvents(ctx context.Context, c *app.RequestContext) {
+	var param model.EventParam
+
+	err := c.BindAndValidate(&param)
 	if err != nil {
 		c.JSON(consts.StatusOK, model.Response{
 			StatusCode: consts.StatusInternalServerError,
@@ -239,9 +426,27 @@ func AppGetUsage(ctx context.Context, c *app.RequestContext) {
 		return
 	}
 
+	events, err := service.NewAppService(ctx, c).GetEventsOfApp(&param)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
 	c.JSON(consts.StatusOK, model.Response{
 		StatusCode: consts.StatusOK,
 		Message:    "查询成功",
-		Data:       usage,
+		Data:       events,
+	})
+}
+
+// appError 返回服务层的错误，应用、快照或计划不存在或不属于当前用户时状态码为 404
+func appError(c *app.RequestContext, err error) {
+	statusCode := consts.StatusInternalServerError
+	if errors.Is(err, service.ErrAppNotFound) || errors.Is(err, service.ErrSnapshotNotFound) || errors.Is(err, service.ErrScheduleNotFound) {
+		statusCode = consts.StatusNotFound
+	}
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: statusCode,
+		Message:    err.Error(),
 	})
 }
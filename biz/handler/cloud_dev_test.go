@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"gorm.io/gorm"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/testutil"
+)
+
+// uploadRequest 以 multipart 表单上传一个文件
+func uploadRequest(deployment string) testRequest {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	_ = writer.WriteField("deployment", deployment)
+	_ = writer.WriteField("path", "/")
+	part, _ := writer.CreateFormFile("file", "a.txt")
+	_, _ = part.Write([]byte("hello"))
+	_ = writer.Close()
+	return testRequest{method: "POST", uri: "/app/common/files/upload", contentType: writer.FormDataContentType(), body: body.Bytes()}
+}
+
+// TestAppHandlersRejectOtherUsersApp 用户 1 通过任何接口访问用户 2 的应用、快照或计划时都返回 404
+func TestAppHandlersRejectOtherUsersApp(t *testing.T) {
+	setupTestEnv(t)
+	testutil.CreateApp(t, 1, "deployment-aaaa1111")
+	other := testutil.CreateApp(t, 2, "deployment-bbbb2222")
+	deployment := other.Deployment
+
+	snapshot := &model.WorkspaceSnapshot{
+		UserId: 2, ApplicationId: other.ID, Deployment: deployment, Name: "snapshot-bbbb2222", State: model.SnapshotReady,
+	}
+	schedule := &model.AppSchedule{
+		UserId: 2, ApplicationId: other.ID, Deployment: deployment, Action: model.ScheduleActionStop, Cron: "0 0 22 * * *",
+	}
+	if err := config.DB.Create(snapshot).Error; err != nil {
+		t.Fatalf("写入快照失败: %v", err)
+	}
+	if err := config.DB.Create(schedule).Error; err != nil {
+		t.Fatalf("写入计划失败: %v", err)
+	}
+	if err := config.DB.Create(&model.Provision{UserId: 2, Deployment: deployment, State: model.ProvisionReady}).Error; err != nil {
+		t.Fatalf("写入创建记录失败: %v", err)
+	}
+
+	byDeployment := map[string]interface{}{"deployment": deployment}
+	query := "?deployment=" + deployment
+	tests := []struct {
+		name    string
+		handler app.HandlerFunc
+		request testRequest
+	}{
+		{"details", AppGetPodInfo, jsonRequest("/app/common/details", map[string]interface{}{"Deployment": deployment})},
+		{"clone", AppClone, jsonRequest("/app/common/clone", map[string]interface{}{"deployment": deployment, "name": "clone"})},
+		{"provision/status", AppProvisionStatus, jsonRequest("/app/common/provision/status", byDeployment)},
+		{"stop", AppStop, jsonRequest("/app/common/stop", byDeployment)},
+		{"restart", AppRestart, jsonRequest("/app/common/restart", byDeployment)},
+		{"delete", AppDelete, jsonRequest("/app/common/delete", byDeployment)},
+		{"delete by id", AppDelete, jsonRequest("/app/common/delete", map[string]interface{}{"ID": other.ID})},
+		{"events", AppGetEvents, testRequest{method: "GET", uri: "/app/common/events" + query}},
+		{"metrics", AppGetMetrics, testRequest{method: "GET", uri: "/app/common/metrics" + query}},
+		{"log", AppGetLog, jsonRequest("/app/common/log", byDeployment)},
+		{"log/stream", AppLogStream, testRequest{method: "GET", uri: "/app/common/log/stream" + query}},
+		{"terminal", AppTerminal, testRequest{method: "GET", uri: "/app/common/terminal" + query}},
+		{"update", AppUpdate, jsonRequest("/app/common/update", map[string]interface{}{"deployment": deployment, "name": "renamed"})},
+		{"idle-timeout", AppSetIdleTimeout, jsonRequest("/app/common/idle-timeout", map[string]interface{}{"deployment": deployment, "idle_timeout": 30})},
+		{"password", AppGetPassword, jsonRequest("/app/common/password", byDeployment)},
+		{"password/rotate", AppRotatePassword, jsonRequest("/app/common/password/rotate", byDeployment)},
+		{"schedule/create", ScheduleCreate, jsonRequest("/app/common/schedule/create", map[string]interface{}{"deployment": deployment, "action": model.ScheduleActionStart, "cron": "0 0 8 * * *"})},
+		{"schedule/list", ScheduleList, jsonRequest("/app/common/schedule/list", byDeployment)},
+		{"schedule/delete", ScheduleDelete, jsonRequest("/app/common/schedule/delete", map[string]interface{}{"ID": schedule.ID})},
+		{"snapshot/create", SnapshotCreate, jsonRequest("/app/common/snapshot/create", byDeployment)},
+		{"snapshot/list", SnapshotList, jsonRequest("/app/common/snapshot/list", byDeployment)},
+		{"snapshot/delete", SnapshotDelete, jsonRequest("/app/common/snapshot/delete", map[string]interface{}{"ID": snapshot.ID})},
+		{"snapshot/restore", SnapshotRestore, jsonRequest("/app/common/snapshot/restore", map[string]interface{}{"ID": snapshot.ID})},
+		{"files/list", FileList, testRequest{method: "GET", uri: "/app/common/files/list" + query + "&path=/"}},
+		{"files/download", FileDownload, testRequest{method: "GET", uri: "/app/common/files/download" + query + "&path=/a.txt"}},
+		{"files/upload", FileUpload, uploadRequest(deployment)},
+		{"files/delete", FileDelete, jsonRequest("/app/common/files/delete", map[string]interface{}{"deployment": deployment, "path": "/a.txt"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := serve(t, tt.handler, tt.request, 1)
+			if response.StatusCode != consts.StatusNotFound {
+				t.Fatalf("状态码为 %d，期望 404: %s", response.StatusCode, response.Message)
+			}
+		})
+	}
+
+	var count int64
+	config.DB.Model(&model.Application{}).Where("user_id = ?", 2).Count(&count)
+	if count != 1 {
+		t.Fatalf("用户 2 的应用数量为 %d", count)
+	}
+	for _, record := range []interface{}{&model.WorkspaceSnapshot{}, &model.AppSchedule{}} {
+		if err := config.DB.First(record).Error; err != nil {
+			t.Fatalf("用户 2 的记录被删除: %v", err)
+		}
+	}
+}
+
+// TestAppDeleteRemovesOnlyOwnApp 删除只影响调用者自己的应用记录
+func TestAppDeleteRemovesOnlyOwnApp(t *testing.T) {
+	setupTestEnv(t)
+	own := testutil.CreateApp(t, 1, "deployment-aaaa1111")
+	other := testutil.CreateApp(t, 2, "deployment-bbbb2222")
+
+	response := serve(t, AppDelete, jsonRequest("/app/common/delete", map[string]interface{}{"deployment": own.Deployment}), 1)
+	if response.StatusCode != consts.StatusOK {
+		t.Fatalf("删除失败: %d %s", response.StatusCode, response.Message)
+	}
+
+	err := config.DB.First(&model.Application{}, own.ID).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("自己的应用应当被删除: %v", err)
+	}
+	if err := config.DB.First(&model.Application{}, other.ID).Error; err != nil {
+		t.Fatalf("其他用户的应用不应被删除: %v", err)
+	}
+
+	// 再次删除同一个应用返回 404
+	response = serve(t, AppDelete, jsonRequest("/app/common/delete", map[string]interface{}{"deployment": own.Deployment}), 1)
+	if response.StatusCode != consts.StatusNotFound {
+		t.Fatalf("重复删除的状态码为 %d", response.StatusCode)
+	}
+}
+
+// TestAppListKeepsArrayWithoutParams 不带参数时 Data 仍为全部应用的数组，带分页参数时为 {items, next_cursor}
+func TestAppListKeepsArrayWithoutParams(t *testing.T) {
+	setupTestEnv(t)
+	for _, deployment := range []string{"deployment-aaaa1111", "deployment-bbbb2222", "deployment-cccc3333"} {
+		testutil.CreateApp(t, 1, deployment)
+	}
+
+	response := serve(t, AppList, testRequest{method: "GET", uri: "/app/common/list"}, 1)
+	items, ok := response.Data.([]interface{})
+	if response.StatusCode != consts.StatusOK || !ok || len(items) != 3 {
+		t.Fatalf("不带参数时返回 %d: %#v", response.StatusCode, response.Data)
+	}
+
+	response = serve(t, AppList, testRequest{method: "GET", uri: "/app/common/list?limit=2"}, 1)
+	page, ok := response.Data.(map[string]interface{})
+	if response.StatusCode != consts.StatusOK || !ok {
+		t.Fatalf("带参数时返回 %d: %#v", response.StatusCode, response.Data)
+	}
+	if items, _ := page["items"].([]interface{}); len(items) != 2 || page["next_cursor"] == "" {
+		t.Fatalf("分页结果为 %#v", page)
+	}
+}
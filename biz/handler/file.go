@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"learn/biz/model"
+	"learn/biz/service"
+)
+
+func FileList(ctx context.Context, c *app.RequestContext) {
+	var param model.FileParam
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	files, err := service.NewFileService(ctx, c).ListFiles(&param)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       files,
+	})
+}
+
+// FileDownload 下载文件，目录以 .tar.gz 格式返回
+func FileDownload(ctx context.Context, c *app.RequestContext) {
+	var param model.FileParam
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	reader, name, err := service.NewFileService(ctx, c).DownloadFile(&param)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	contentType := "application/octet-stream"
+	if strings.HasSuffix(name, ".tar.gz") {
+		contentType = "application/gzip"
+	}
+	c.Response.Header.Set("Content-Type", contentType)
+	c.Response.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	c.SetBodyStream(reader, -1)
+}
+
+// FileUpload 上传文件，表单字段 file 为文件内容，path 为目标目录
+func FileUpload(ctx context.Context, c *app.RequestContext) {
+	var param model.FileParam
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewFileService(ctx, c).UploadFile(&param, header)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "上传成功",
+	})
+}
+
+func FileDelete(ctx context.Context, c *app.RequestContext) {
+	var param model.FileParam
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewFileService(ctx, c).DeleteFile(&param)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "删除成功",
+	})
+}
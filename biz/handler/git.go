@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"learn/biz/model"
+	"learn/biz/service"
+)
+
+func GitCredentialCreate(ctx context.Context, c *app.RequestContext) {
+	var credential model.GitCredential
+
+	err := c.BindAndValidate(&credential)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewGitCredentialService(ctx, c).CreateGitCredential(&credential)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "保存成功",
+		Data:       credential.Name,
+	})
+}
+
+func GitCredentialList(ctx context.Context, c *app.RequestContext) {
+	credentials, err := service.NewGitCredentialService(ctx, c).ListGitCredential()
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       credentials,
+	})
+}
+
+func GitCredentialDelete(ctx context.Context, c *app.RequestContext) {
+	var credential model.GitCredential
+
+	err := c.BindAndValidate(&credential)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewGitCredentialService(ctx, c).DeleteGitCredential(credential.Name)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "删除成功",
+	})
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+
+	"learn/biz/model"
+	"learn/biz/testutil"
+	"learn/biz/util"
+)
+
+// setupTestEnv 使用内存中的 SQLite、miniredis 和进程内后端，每个测试独立一个数据库
+func setupTestEnv(t *testing.T) {
+	t.Helper()
+	util.UseMemoryBackend()
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+}
+
+// testRequest 构造通过 JWT 认证后的请求上下文
+type testRequest struct {
+	method      string
+	uri         string
+	contentType string
+	body        []byte
+}
+
+func (r testRequest) context(userId int64) *app.RequestContext {
+	c := testutil.NewContext(userId)
+	c.Request.SetMethod(r.method)
+	c.Request.SetRequestURI(r.uri)
+	if r.body != nil {
+		c.Request.Header.SetContentTypeBytes([]byte(r.contentType))
+		c.Request.SetBody(r.body)
+		c.Request.Header.SetContentLength(len(r.body))
+	}
+	return c
+}
+
+// jsonRequest 以 JSON 请求体调用 POST 接口
+func jsonRequest(uri string, body interface{}) testRequest {
+	data, _ := json.Marshal(body)
+	return testRequest{method: "POST", uri: uri, contentType: "application/json", body: data}
+}
+
+// serve 调用 handler 并解析返回的 model.Response
+func serve(t *testing.T, handler app.HandlerFunc, request testRequest, userId int64) model.Response {
+	t.Helper()
+	c := request.context(userId)
+	handler(context.Background(), c)
+
+	var response model.Response
+	body := c.Response.Body()
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&response); err != nil {
+		t.Fatalf("解析响应失败: %v, body: %s", err, body)
+	}
+	return response
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/protocol/http1/resp"
+
+	"learn/biz/model"
+	"learn/biz/service"
+)
+
+// sseKeepAlive 没有新日志时发送注释行的间隔，既防止代理断开空闲连接，也用来发现已经断开的客户端
+const sseKeepAlive = 15 * time.Second
+
+// AppLogStream 以 Server-Sent Events 推送应用日志，每行日志对应一个 data 事件，日志读完后发送 end 事件
+func AppLogStream(ctx context.Context, c *app.RequestContext) {
+	var param model.LogStreamParam
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	stream, err := service.NewAppService(ctx, c).StreamLogOfApp(&param)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+	defer stream.Close()
+
+	c.SetStatusCode(consts.StatusOK)
+	c.Response.Header.Set("Content-Type", "text/event-stream; charset=utf-8")
+	c.Response.Header.Set("Cache-Control", "no-cache")
+	c.Response.Header.Set("Connection", "keep-alive")
+	c.Response.Header.Set("X-Accel-Buffering", "no")
+	c.Response.HijackWriter(resp.NewChunkedBodyWriter(&c.Response, c.GetWriter()))
+
+	// 单独的协程逐行读取日志，主循环负责写出，这样等待新日志时也能发送心跳
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stream)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		readErr <- scanner.Err()
+	}()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		var event string
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				if err := <-readErr; err != nil {
+					writeEvent(c, fmt.Sprintf("event: error\ndata: %s\n\n", err.Error()))
+				}
+				writeEvent(c, "event: end\ndata: \n\n")
+				return
+			}
+			event = fmt.Sprintf("data: %s\n\n", line)
+		case <-ticker.C:
+			event = ": ping\n\n"
+		}
+
+		if err := writeEvent(c, event); err != nil {
+			// 客户端已断开，关闭日志流让读取协程退出
+			log.Printf("日志推送结束 - Deployment: %s, Error: %v", param.Deployment, err)
+			stream.Close()
+			for range lines {
+			}
+			return
+		}
+	}
+}
+
+func writeEvent(c *app.RequestContext, event string) error {
+	if _, err := c.Write([]byte(event)); err != nil {
+		return err
+	}
+	return c.Flush()
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"learn/biz/model"
+	"learn/biz/service"
+)
+
+// AppGetMetrics 查询工作空间的实时 CPU、内存用量及最近一小时的历史
+func AppGetMetrics(ctx context.Context, c *app.RequestContext) {
+	var param model.MetricsParam
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	metrics, err := service.NewMetricsService(ctx, c).GetMetricsOfApp(&param)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       metrics,
+	})
+}
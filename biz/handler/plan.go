@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"learn/biz/model"
+	"learn/biz/service"
+)
+
+func PlanList(ctx context.Context, c *app.RequestContext) {
+	plans, err := service.NewPlanService(ctx, c).ListPlan()
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       plans,
+	})
+}
+
+func PlanCreate(ctx context.Context, c *app.RequestContext) {
+	var plan model.Plan
+
+	err := c.BindAndValidate(&plan)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	id, err := service.NewPlanService(ctx, c).CreatePlan(&plan)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "创建成功",
+		Data:       id,
+	})
+}
+
+func PlanUpdate(ctx context.Context, c *app.RequestContext) {
+	var plan model.Plan
+
+	err := c.BindAndValidate(&plan)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewPlanService(ctx, c).UpdatePlan(&plan)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "更新成功",
+	})
+}
+
+func PlanDelete(ctx context.Context, c *app.RequestContext) {
+	var plan model.Plan
+
+	err := c.BindAndValidate(&plan)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewPlanService(ctx, c).DeletePlan(plan.ID)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "删除成功",
+	})
+}
+
+// PlanAssign 为用户分配套餐
+func PlanAssign(ctx context.Context, c *app.RequestContext) {
+	var param model.PlanAssignParam
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewPlanService(ctx, c).AssignPlan(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "分配成功",
+	})
+}
+
+// UserLimitSet 为用户单独设置限额，覆盖套餐中的对应项
+func UserLimitSet(ctx context.Context, c *app.RequestContext) {
+	var param model.UserLimit
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewPlanService(ctx, c).SetUserLimit(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "设置成功",
+	})
+}
+
+// UserLimitDelete 删除用户单独设置的限额
+func UserLimitDelete(ctx context.Context, c *app.RequestContext) {
+	var param model.UserLimit
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewPlanService(ctx, c).DeleteUserLimit(param.UserId)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "删除成功",
+	})
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"learn/biz/model"
+	"learn/biz/service"
+)
+
+// AdminReconcile 立即执行一次对账，dry_run=true 时只报告不修改集群
+func AdminReconcile(ctx context.Context, c *app.RequestContext) {
+	var param model.ReconcileParam
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	report, err := service.NewReconcileService(ctx).Reconcile(param.DryRun)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "对账完成",
+		Data:       report,
+	})
+}
+
+// AdminReconcileReport 查询最近一次对账的结果
+func AdminReconcileReport(ctx context.Context, c *app.RequestContext) {
+	report, err := service.NewReconcileService(ctx).LastReport()
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       report,
+	})
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"learn/biz/model"
+	"learn/biz/service"
+)
+
+func ScheduleCreate(ctx context.Context, c *app.RequestContext) {
+	var schedule model.AppSchedule
+
+	err := c.BindAndValidate(&schedule)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	id, err := service.NewScheduleService(ctx, c).CreateSchedule(&schedule)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "创建成功",
+		Data:       id,
+	})
+}
+
+func ScheduleList(ctx context.Context, c *app.RequestContext) {
+	var schedule model.AppSchedule
+
+	err := c.BindAndValidate(&schedule)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	schedules, err := service.NewScheduleService(ctx, c).ListSchedule(schedule.Deployment)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       schedules,
+	})
+}
+
+func ScheduleDelete(ctx context.Context, c *app.RequestContext) {
+	var schedule model.AppSchedule
+
+	err := c.BindAndValidate(&schedule)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewScheduleService(ctx, c).DeleteSchedule(schedule.ID)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "删除成功",
+	})
+}
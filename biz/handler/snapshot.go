@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"learn/biz/model"
+	"learn/biz/service"
+)
+
+func SnapshotCreate(ctx context.Context, c *app.RequestContext) {
+	var snapshot model.WorkspaceSnapshot
+
+	err := c.BindAndValidate(&snapshot)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	record, err := service.NewSnapshotService(ctx, c).CreateSnapshot(&snapshot)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "创建成功",
+		Data:       record,
+	})
+}
+
+func SnapshotList(ctx context.Context, c *app.RequestContext) {
+	var snapshot model.WorkspaceSnapshot
+
+	err := c.BindAndValidate(&snapshot)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	records, err := service.NewSnapshotService(ctx, c).ListSnapshot(snapshot.Deployment)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "查询成功",
+		Data:       records,
+	})
+}
+
+func SnapshotDelete(ctx context.Context, c *app.RequestContext) {
+	var snapshot model.WorkspaceSnapshot
+
+	err := c.BindAndValidate(&snapshot)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewSnapshotService(ctx, c).DeleteSnapshot(snapshot.ID)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "删除成功",
+	})
+}
+
+func SnapshotRestore(ctx context.Context, c *app.RequestContext) {
+	var snapshot model.WorkspaceSnapshot
+
+	err := c.BindAndValidate(&snapshot)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewSnapshotService(ctx, c).RestoreSnapshot(snapshot.ID)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "开始恢复",
+	})
+}
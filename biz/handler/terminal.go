@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/adaptor"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/gorilla/websocket"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"learn/biz/model"
+	"learn/biz/service"
+	"learn/biz/util"
+)
+
+var terminalUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin:     checkTerminalOrigin,
+}
+
+// checkTerminalOrigin 默认只允许同源连接，前端单独部署时在 TERMINAL_ALLOWED_ORIGINS 中用逗号分隔列出允许的来源
+func checkTerminalOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "http://"+r.Host || origin == "https://"+r.Host {
+		return true
+	}
+	for _, allowed := range strings.Split(util.GetEnvOrDefault("TERMINAL_ALLOWED_ORIGINS", ""), ",") {
+		if strings.TrimSpace(allowed) == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// AppTerminal 通过 WebSocket 连接到工作空间 code-server 容器中的 shell
+func AppTerminal(ctx context.Context, c *app.RequestContext) {
+	var param model.TerminalParam
+
+	err := c.BindAndValidate(&param)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	terminalService := service.NewTerminalService(ctx, c)
+	kbParam, err := terminalService.GetTerminalTarget(param.Deployment)
+	if err != nil {
+		appError(c, err)
+		return
+	}
+
+	adaptor.HertzHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := terminalUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("升级WebSocket失败: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		sessionCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		session := newTerminalSession(conn, cancel)
+		err = terminalService.Attach(sessionCtx, kbParam, session)
+		if err != nil && sessionCtx.Err() == nil {
+			log.Printf("终端会话异常结束 - Deployment: %s, Error: %v", kbParam.Deployment, err)
+			_, _ = session.Write([]byte("\r\n" + err.Error() + "\r\n"))
+		}
+		session.close()
+	}))(ctx, c)
+}
+
+// terminalSession 把 WebSocket 连接适配成 exec 需要的输入输出和窗口大小队列
+type terminalSession struct {
+	conn    *websocket.Conn
+	cancel  context.CancelFunc
+	sizes   chan remotecommand.TerminalSize
+	done    chan struct{}
+	pending []byte
+	writeMu sync.Mutex
+}
+
+func newTerminalSession(conn *websocket.Conn, cancel context.CancelFunc) *terminalSession {
+	return &terminalSession{
+		conn:   conn,
+		cancel: cancel,
+		sizes:  make(chan remotecommand.TerminalSize, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// Read 读取浏览器的输入，resize 消息转入窗口大小队列；连接断开时取消 exec
+func (t *terminalSession) Read(p []byte) (int, error) {
+	for len(t.pending) == 0 {
+		_, data, err := t.conn.ReadMessage()
+		if err != nil {
+			t.cancel()
+			return 0, err
+		}
+
+		var message model.TerminalMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			continue
+		}
+		switch message.Type {
+		case model.TerminalMessageInput:
+			t.pending = []byte(message.Data)
+		case model.TerminalMessageResize:
+			size := remotecommand.TerminalSize{Width: message.Cols, Height: message.Rows}
+			// 只保留最新的窗口大小
+			select {
+			case <-t.sizes:
+			default:
+			}
+			t.sizes <- size
+		}
+	}
+
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+func (t *terminalSession) Write(p []byte) (int, error) {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if err := t.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Next 实现 remotecommand.TerminalSizeQueue，会话结束时返回 nil
+func (t *terminalSession) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-t.sizes:
+		return &size
+	case <-t.done:
+		return nil
+	}
+}
+
+func (t *terminalSession) close() {
+	close(t.done)
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_ = t.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
@@ -118,3 +118,29 @@ func UserInfo(ctx context.Context, c *app.RequestContext) {
 		Message:    "Success",
 	})
 }
+
+func UserSetIdleTimeout(ctx context.Context, c *app.RequestContext) {
+	var userParam model.UserParam
+
+	err := c.BindAndValidate(&userParam)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	err = service.NewUserService(ctx, c).SetIdleTimeout(userParam)
+	if err != nil {
+		c.JSON(consts.StatusOK, model.Response{
+			StatusCode: consts.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return
+	}
+	c.JSON(consts.StatusOK, model.Response{
+		StatusCode: consts.StatusOK,
+		Message:    "Success",
+	})
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"log"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+
+	"learn/biz/config"
+	"learn/biz/model"
+)
+
+// AdminAuth 校验当前用户是否为管理员，需要挂在 JWT 中间件之后
+func AdminAuth() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if !IsAdmin(ctx, c) {
+			c.JSON(consts.StatusOK, model.Response{
+				StatusCode: consts.StatusForbidden,
+				Message:    "需要管理员权限",
+			})
+			c.Abort()
+			return
+		}
+		c.Next(ctx)
+	}
+}
+
+// IsAdmin 判断当前登录用户是否拥有 admin 角色
+func IsAdmin(ctx context.Context, c *app.RequestContext) bool {
+	userId, ok := c.Get("user_id")
+	if !ok {
+		return false
+	}
+
+	var count int64
+	err := config.DB.WithContext(ctx).Model(&model.Role{}).
+		Where("user_id = ? AND type = ?", userId, "admin").
+		Count(&count).Error
+	if err != nil {
+		log.Printf("查询用户角色失败: %v", err)
+		return false
+	}
+	return count > 0
+}
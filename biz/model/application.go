@@ -6,19 +6,32 @@ import (
 
 type Application struct {
 	gorm.Model
-	Name       string `gorm:"type:varchar(100); not null;" json:"name"`
-	PodName    string `gorm:"type:varchar(100); not null; unique" json:"pod_name"`
-	UserId     uint   `gorm:"type:integer; not null;" json:"user_id"`
-	Cpu        string `gorm:"type:varchar(100); not null;" json:"cpu"`
-	Memory     string `gorm:"type:varchar(100); not null;" json:"memory"`
-	Url        string `gorm:"type:varchar(255); not null;" json:"url"`
-	Deployment string `gorm:"type:varchar(100); not null;" json:"deployment"`
-	State      string `gorm:"-" json:"state"`
+	Name        string    `gorm:"type:varchar(100); not null;" json:"name"`
+	PodName     string    `gorm:"type:varchar(100); not null; unique" json:"pod_name"`
+	UserId      uint      `gorm:"type:integer; not null;" json:"user_id"`
+	Cpu         string    `gorm:"type:varchar(100); not null;" json:"cpu"`
+	Memory      string    `gorm:"type:varchar(100); not null;" json:"memory"`
+	Storage     string    `gorm:"type:varchar(100); not null; default:'';" json:"storage"`
+	Url         string    `gorm:"type:varchar(255); not null;" json:"url"`
+	Deployment  string    `gorm:"type:varchar(100); not null;" json:"deployment"`
+	TemplateId  uint      `gorm:"type:integer; not null; default:0;" json:"template_id"`
+	IdleTimeout int       `gorm:"not null; default:0;" json:"idle_timeout"`
+	Repos       []GitRepo `gorm:"type:text; serializer:json" json:"repos"`
+	Tags        []string  `gorm:"type:text; serializer:json" json:"tags"`
+	State       string    `gorm:"-" json:"state"`
+	// RepoStatus 仓库克隆结果，只在查询时从 Pod 状态中读取
+	RepoStatus []GitRepoStatus `gorm:"-" json:"repo_status,omitempty"`
+	// Reason 工作空间未正常运行时，根据最近的 Warning 事件给出的原因
+	Reason string `gorm:"-" json:"reason,omitempty"`
+	// Pvc 数据卷的名称，为空时为 pvc-<后缀>，恢复快照后换成从快照创建的新 PVC
+	Pvc string `gorm:"type:varchar(100); not null; default:'';" json:"pvc,omitempty"`
 }
 
 type AppParam struct {
 	Application
 	PodPassword string `json:"pod_password"`
+	// PlanId 按套餐的规格创建，未指定的 Cpu、Memory、Storage 取套餐的值
+	PlanId uint `json:"plan_id"`
 }
 
 type KubernetesParam struct {
@@ -28,6 +41,38 @@ type KubernetesParam struct {
 	State      string
 	Pvc        string
 	Svc        string
+	Secret     string
 	Cpu        string
 	Memory     string
+	Port       int32
+}
+
+// AppUpdateResult 修改应用规格后的结果，Restarted 表示是否触发了 Pod 重建
+type AppUpdateResult struct {
+	Deployment string `json:"deployment"`
+	Mode       string `json:"mode"`
+	Restarted  bool   `json:"restarted"`
+}
+
+// AppListParam 应用列表的查询参数
+// State 可用逗号分隔多个状态；Sort 可选 created_at、updated_at、name、state，前缀 - 表示倒序；
+// Cursor 为上一页返回的 next_cursor，Limit 默认 50，最大 100
+type AppListParam struct {
+	State  string `query:"state" json:"state"`
+	Name   string `query:"name" json:"name"`
+	Tag    string `query:"tag" json:"tag"`
+	Sort   string `query:"sort" json:"sort"`
+	Cursor string `query:"cursor" json:"cursor"`
+	Limit  int    `query:"limit" json:"limit"`
+}
+
+// IsEmpty 是否没有指定任何查询参数，此时返回全部应用且列表接口的 Data 仍为应用数组
+func (p *AppListParam) IsEmpty() bool {
+	return *p == AppListParam{}
+}
+
+// AppListResult 一页应用列表，NextCursor 为空表示没有下一页
+type AppListResult struct {
+	Items      []*Application `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
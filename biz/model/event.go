@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// EventParam 查询工作空间事件的参数
+type EventParam struct {
+	Deployment string `query:"deployment" json:"deployment"`
+}
+
+// WorkspaceEvent 工作空间相关的 Kubernetes 事件，相同对象、原因和信息的事件合并为一条
+type WorkspaceEvent struct {
+	Type           string    `json:"type"`
+	Reason         string    `json:"reason"`
+	Message        string    `json:"message"`
+	Kind           string    `json:"kind"`
+	Name           string    `json:"name"`
+	Count          int32     `json:"count"`
+	FirstTimestamp time.Time `json:"first_timestamp"`
+	LastTimestamp  time.Time `json:"last_timestamp"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+const (
+	FileTypeFile = "file"
+	FileTypeDir  = "dir"
+	FileTypeLink = "link"
+)
+
+// FileParam 文件接口的参数，Path 是相对于工作空间数据目录的路径
+type FileParam struct {
+	Deployment string `query:"deployment" form:"deployment" json:"deployment"`
+	Path       string `query:"path" form:"path" json:"path"`
+}
+
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Type    string    `json:"type"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
@@ -0,0 +1,31 @@
+package model
+
+const (
+	GitCloneSucceeded = "cloned"
+	GitCloneExists    = "exists"
+	GitCloneFailed    = "failed"
+)
+
+// GitRepo 创建工作空间时要克隆的仓库，Directory 是相对数据目录的路径，为空时取仓库名
+// CredentialSecret 引用用户命名空间中的 Secret，其中 username、password 两个键用于 HTTPS 认证
+type GitRepo struct {
+	Url              string `json:"url"`
+	Branch           string `json:"branch"`
+	Directory        string `json:"directory"`
+	CredentialSecret string `json:"credential_secret"`
+}
+
+// GitRepoStatus 最近一次 Pod 启动时克隆仓库的结果
+type GitRepoStatus struct {
+	Directory string `json:"directory"`
+	State     string `json:"state"`
+	Message   string `json:"message"`
+}
+
+// GitCredential 用户命名空间中保存仓库 HTTPS 认证信息的 Secret，Name 即创建工作空间时引用的 credential_secret
+// 查询时不返回密码
+type GitCredential struct {
+	Name     string `json:"name"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// UserLimit 管理员为单个用户设置的限额，覆盖套餐中的对应项；数量为 nil、总量为空时沿用套餐的值
+type UserLimit struct {
+	gorm.Model
+	UserId        uint   `gorm:"type:integer; not null; uniqueIndex" json:"user_id"`
+	MaxWorkspaces *int   `json:"max_workspaces"`
+	MaxRunning    *int   `json:"max_running"`
+	TotalCpu      string `gorm:"type:varchar(100); not null; default:'';" json:"total_cpu"`
+	TotalMemory   string `gorm:"type:varchar(100); not null; default:'';" json:"total_memory"`
+	TotalStorage  string `gorm:"type:varchar(100); not null; default:'';" json:"total_storage"`
+}
+
+// WorkspaceLimits 用户实际生效的限额，数量为 0 或总量为空表示不限制
+// Cpu、Memory 限制运行中工作空间的总和，Storage 限制所有工作空间数据卷的总和
+type WorkspaceLimits struct {
+	MaxWorkspaces int    `json:"max_workspaces"`
+	MaxRunning    int    `json:"max_running"`
+	Cpu           string `json:"cpu"`
+	Memory        string `json:"memory"`
+	Storage       string `json:"storage"`
+}
+
+// WorkspaceUsage 用户当前的用量，统计口径与 WorkspaceLimits 一致，创建中的工作空间按运行中计算
+type WorkspaceUsage struct {
+	Workspaces int    `json:"workspaces"`
+	Running    int    `json:"running"`
+	Cpu        string `json:"cpu"`
+	Memory     string `json:"memory"`
+	Storage    string `json:"storage"`
+}
+
+// QuotaStatus 用户的套餐、限额与当前用量
+type QuotaStatus struct {
+	Plan   string          `json:"plan"`
+	Limits WorkspaceLimits `json:"limits"`
+	Usage  WorkspaceUsage  `json:"usage"`
+}
@@ -0,0 +1,17 @@
+package model
+
+const (
+	ContainerCodeServer  = "code-server"
+	ContainerHeartbeater = "heartbeater"
+)
+
+// LogStreamParam 实时日志的查询参数，SSE 连接只能走 GET，因此全部从 query 中读取
+type LogStreamParam struct {
+	Deployment   string `query:"deployment" json:"deployment"`
+	Container    string `query:"container" json:"container"`
+	Follow       bool   `query:"follow" json:"follow"`
+	TailLines    int64  `query:"tail_lines" json:"tail_lines"`
+	SinceSeconds int64  `query:"since_seconds" json:"since_seconds"`
+	Timestamps   bool   `query:"timestamps" json:"timestamps"`
+	Previous     bool   `query:"previous" json:"previous"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// MetricsParam 查询工作空间用量的参数
+type MetricsParam struct {
+	Deployment string `query:"deployment" json:"deployment"`
+}
+
+// ContainerMetrics 单个容器的当前用量与限制，CPU 单位为毫核，内存单位为字节，未设置限制时为 0
+type ContainerMetrics struct {
+	Name        string `json:"name"`
+	CpuUsage    int64  `json:"cpu_usage"`
+	CpuLimit    int64  `json:"cpu_limit"`
+	MemoryUsage int64  `json:"memory_usage"`
+	MemoryLimit int64  `json:"memory_limit"`
+}
+
+// MetricsSample 工作空间所有容器用量之和的一次采样
+type MetricsSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Cpu       int64     `json:"cpu"`
+	Memory    int64     `json:"memory"`
+}
+
+// WorkspaceMetrics 工作空间当前用量及最近一小时的采样记录
+type WorkspaceMetrics struct {
+	Deployment  string             `json:"deployment"`
+	Pod         string             `json:"pod"`
+	Timestamp   time.Time          `json:"timestamp"`
+	Containers  []ContainerMetrics `json:"containers"`
+	CpuUsage    int64              `json:"cpu_usage"`
+	CpuLimit    int64              `json:"cpu_limit"`
+	MemoryUsage int64              `json:"memory_usage"`
+	MemoryLimit int64              `json:"memory_limit"`
+	History     []MetricsSample    `json:"history"`
+}
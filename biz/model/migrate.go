@@ -0,0 +1,19 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// AutoMigrate 同步业务表结构
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&User{},
+		&Application{},
+		&WorkspaceTemplate{},
+		&Provision{},
+		&AppSchedule{},
+		&WorkspaceSnapshot{},
+		&Plan{},
+		&UserLimit{},
+	)
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// Plan 资源套餐，由管理员维护并分配给用户
+// Cpu、Memory、Storage 是单个工作空间规格的上限，创建时选择该套餐则直接使用这一规格；
+// MaxWorkspaces、MaxRunning 为 0 表示不限制数量，TotalCpu、TotalMemory、TotalStorage 为空表示不限制总量，
+// TemplateIds 为空表示可以使用所有模板，0 代表内置默认模板
+type Plan struct {
+	gorm.Model
+	Name          string `gorm:"type:varchar(100); not null; unique" json:"name"`
+	Description   string `gorm:"type:varchar(255);" json:"description"`
+	Cpu           string `gorm:"type:varchar(100); not null;" json:"cpu"`
+	Memory        string `gorm:"type:varchar(100); not null;" json:"memory"`
+	Storage       string `gorm:"type:varchar(100); not null;" json:"storage"`
+	MaxWorkspaces int    `gorm:"not null; default:0;" json:"max_workspaces"`
+	MaxRunning    int    `gorm:"not null; default:0;" json:"max_running"`
+	TotalCpu      string `gorm:"type:varchar(100); not null; default:'';" json:"total_cpu"`
+	TotalMemory   string `gorm:"type:varchar(100); not null; default:'';" json:"total_memory"`
+	TotalStorage  string `gorm:"type:varchar(100); not null; default:'';" json:"total_storage"`
+	TemplateIds   []uint `gorm:"type:text; serializer:json" json:"template_ids"`
+}
+
+// AllowsTemplate 判断套餐是否可以使用指定模板
+func (p *Plan) AllowsTemplate(templateId uint) bool {
+	if len(p.TemplateIds) == 0 {
+		return true
+	}
+	for _, id := range p.TemplateIds {
+		if id == templateId {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanAssignParam 为用户分配套餐，PlanId 为 0 表示恢复为默认套餐
+type PlanAssignParam struct {
+	UserId uint `json:"user_id"`
+	PlanId uint `json:"plan_id"`
+}
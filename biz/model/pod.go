@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// WorkspacePodView 对外返回的 Pod 信息，只包含状态与资源规格，不含环境变量、挂载和 managedFields 等内部字段
+type WorkspacePodView struct {
+	Name        string                `json:"name"`
+	Namespace   string                `json:"namespace"`
+	Deployment  string                `json:"deployment"`
+	Application string                `json:"application"`
+	Phase       corev1.PodPhase       `json:"phase"`
+	Ready       bool                  `json:"ready"`
+	Conditions  []corev1.PodCondition `json:"conditions"`
+	StartTime   *time.Time            `json:"start_time"`
+	NodeName    string                `json:"node_name"`
+	Containers  []ContainerView       `json:"containers"`
+}
+
+type ContainerView struct {
+	Name         string            `json:"name"`
+	Image        string            `json:"image"`
+	Ready        bool              `json:"ready"`
+	RestartCount int32             `json:"restart_count"`
+	State        string            `json:"state"`
+	Reason       string            `json:"reason"`
+	Requests     map[string]string `json:"requests"`
+	Limits       map[string]string `json:"limits"`
+}
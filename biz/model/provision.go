@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 创建流程的各个状态，按推进顺序排列，source_stopped、pvc_bound 和 source_resumed 只出现在克隆流程中
+const (
+	ProvisionPending           = "pending"
+	ProvisionSecretCreated     = "secret_created"
+	ProvisionSourceStopped     = "source_stopped"
+	ProvisionPvcCreated        = "pvc_created"
+	ProvisionDeploymentCreated = "deployment_created"
+	ProvisionPvcBound          = "pvc_bound"
+	ProvisionSourceResumed     = "source_resumed"
+	ProvisionServiceCreated    = "service_created"
+	ProvisionReady             = "ready"
+	ProvisionFailed            = "failed"
+)
+
+// Provision 应用创建流程的持久化记录，State 为已到达的状态，Step 为正在执行或失败的步骤
+// Owner 为执行流程的服务实例，执行期间定期刷新 HeartbeatAt，心跳过期的未完成流程才会被其他实例回滚
+// Source 为克隆流程的源工作空间，SourceStopped 表示源工作空间被流程停止且尚未恢复，回滚时需要重新启动
+type Provision struct {
+	gorm.Model
+	UserId        uint       `gorm:"type:integer; not null; index" json:"user_id"`
+	Deployment    string     `gorm:"type:varchar(100); not null; unique" json:"deployment"`
+	Namespace     string     `gorm:"type:varchar(100); not null;" json:"namespace"`
+	Pvc           string     `gorm:"type:varchar(100); not null;" json:"pvc"`
+	Svc           string     `gorm:"type:varchar(100); not null;" json:"svc"`
+	Secret        string     `gorm:"type:varchar(100); not null; default:''" json:"secret"`
+	State         string     `gorm:"type:varchar(50); not null;" json:"state"`
+	Step          string     `gorm:"type:varchar(50);" json:"step"`
+	Error         string     `gorm:"type:text" json:"error"`
+	RolledBack    bool       `gorm:"not null; default:false" json:"rolled_back"`
+	Owner         string     `gorm:"type:varchar(100); not null; default:''" json:"owner"`
+	HeartbeatAt   *time.Time `gorm:"index" json:"heartbeat_at"`
+	Source        string     `gorm:"type:varchar(100); not null; default:''" json:"source"`
+	SourceStopped bool       `gorm:"not null; default:false" json:"source_stopped"`
+}
@@ -0,0 +1,14 @@
+package model
+
+// NamespaceQuota 用户命名空间的资源上限，对应 ResourceQuota 与 LimitRange
+// Cpu、Memory 限制所有运行中 Pod 的资源总和，Storage、Pvcs 限制数据卷，
+// DefaultCpu、DefaultMemory 是未声明资源的容器（如 heartbeater、辅助 Pod）的默认规格
+type NamespaceQuota struct {
+	Cpu           string `json:"cpu"`
+	Memory        string `json:"memory"`
+	Storage       string `json:"storage"`
+	Pvcs          int64  `json:"pvcs"`
+	Pods          int64  `json:"pods"`
+	DefaultCpu    string `json:"default_cpu"`
+	DefaultMemory string `json:"default_memory"`
+}
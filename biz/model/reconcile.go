@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// 对账发现的问题类型
+const (
+	DriftMissing = "missing" // 应用记录存在，但集群中缺少对应资源
+	DriftOrphan  = "orphan"  // 集群中的资源没有对应的应用记录
+)
+
+// 对账对问题采取的处理
+const (
+	DriftActionNone      = "none"
+	DriftActionRecreated = "recreated"
+	DriftActionCollected = "collected"
+	DriftActionFailed    = "failed"
+)
+
+// DriftItem 一条应用记录与集群资源不一致的记录，Kind 为 Deployment、Service、PersistentVolumeClaim 或 Secret
+type DriftItem struct {
+	Type       string     `json:"type"`
+	Namespace  string     `json:"namespace"`
+	Kind       string     `json:"kind"`
+	Name       string     `json:"name"`
+	Deployment string     `json:"deployment"`
+	Action     string     `json:"action"`
+	Message    string     `json:"message,omitempty"`
+	FirstSeen  *time.Time `json:"first_seen,omitempty"`
+}
+
+// DriftReport 一次对账的结果，DryRun 为 true 时只报告不做任何修改
+type DriftReport struct {
+	DryRun     bool         `json:"dry_run"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Items      []*DriftItem `json:"items"`
+}
+
+type ReconcileParam struct {
+	DryRun bool `json:"dry_run" form:"dry_run" query:"dry_run"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	ScheduleActionStart = "start"
+	ScheduleActionStop  = "stop"
+)
+
+// AppSchedule 工作空间的定时启停计划，Cron 为带秒字段的六段式表达式，可用 CRON_TZ= 前缀指定时区
+type AppSchedule struct {
+	gorm.Model
+	UserId        uint   `gorm:"type:integer; not null; index" json:"user_id"`
+	ApplicationId uint   `gorm:"type:integer; not null; index" json:"application_id"`
+	Deployment    string `gorm:"type:varchar(100); not null;" json:"deployment"`
+	Action        string `gorm:"type:varchar(20); not null;" json:"action"`
+	Cron          string `gorm:"type:varchar(100); not null;" json:"cron"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	SnapshotPending = "pending"
+	SnapshotReady   = "ready"
+	SnapshotFailed  = "failed"
+
+	RestoreRunning  = "restoring"
+	RestoreFinished = "restored"
+	RestoreFailed   = "failed"
+)
+
+// WorkspaceSnapshot 工作空间数据卷的快照记录，Name 为集群中 VolumeSnapshot 的名称
+// ReplacedPvc 为恢复到已停止的工作空间时保留的原 PVC，新 PVC 绑定后由对账删除
+type WorkspaceSnapshot struct {
+	gorm.Model
+	UserId        uint   `gorm:"type:integer; not null; index" json:"user_id"`
+	ApplicationId uint   `gorm:"type:integer; not null; index" json:"application_id"`
+	Deployment    string `gorm:"type:varchar(100); not null;" json:"deployment"`
+	Name          string `gorm:"type:varchar(100); not null; unique" json:"name"`
+	Description   string `gorm:"type:varchar(255);" json:"description"`
+	Namespace     string `gorm:"type:varchar(100); not null;" json:"namespace"`
+	SourcePvc     string `gorm:"type:varchar(100); not null;" json:"source_pvc"`
+	State         string `gorm:"type:varchar(50); not null;" json:"state"`
+	RestoreSize   string `gorm:"type:varchar(50);" json:"restore_size"`
+	RestoreState  string `gorm:"type:varchar(50);" json:"restore_state"`
+	ReplacedPvc   string `gorm:"type:varchar(100);" json:"replaced_pvc"`
+	Error         string `gorm:"type:text" json:"error"`
+}
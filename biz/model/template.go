@@ -0,0 +1,30 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// WorkspaceTemplate 工作空间模板，决定 code-server 容器使用的镜像、环境变量、端口与默认资源
+type WorkspaceTemplate struct {
+	gorm.Model
+	Name            string         `gorm:"type:varchar(100); not null; unique" json:"name"`
+	Description     string         `gorm:"type:varchar(255);" json:"description"`
+	Image           string         `gorm:"type:varchar(255); not null;" json:"image"`
+	ImagePullPolicy string         `gorm:"type:varchar(50);" json:"image_pull_policy"`
+	Env             []TemplateEnv  `gorm:"type:text; serializer:json" json:"env"`
+	Ports           []TemplatePort `gorm:"type:text; serializer:json" json:"ports"`
+	MountPath       string         `gorm:"type:varchar(255); not null;" json:"mount_path"`
+	Cpu             string         `gorm:"type:varchar(100); not null;" json:"cpu"`
+	Memory          string         `gorm:"type:varchar(100); not null;" json:"memory"`
+}
+
+type TemplateEnv struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type TemplatePort struct {
+	Name          string `json:"name"`
+	ContainerPort int32  `json:"container_port"`
+	Protocol      string `json:"protocol"`
+}
@@ -0,0 +1,19 @@
+package model
+
+const (
+	TerminalMessageInput  = "input"
+	TerminalMessageResize = "resize"
+)
+
+type TerminalParam struct {
+	Deployment string `query:"deployment" json:"deployment"`
+}
+
+// TerminalMessage 浏览器发往终端的 WebSocket 消息，input 携带键盘输入，resize 携带新的窗口大小
+// 终端输出以二进制消息原样发回浏览器
+type TerminalMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+	Cols uint16 `json:"cols"`
+	Rows uint16 `json:"rows"`
+}
@@ -6,11 +6,13 @@ import (
 
 type User struct {
 	gorm.Model
-	Username string `json:"username" gorm:"type:varchar(50);uniqueIndex;"`
-	Email    string `json:"email" gorm:"type:varchar(50);uniqueIndex;not null"`
-	Password string `json:"password" gorm:"type:varchar(255);not null"`
-	Nickname string `json:"nickname" gorm:"type:varchar(50)"`
-	Avatar   string `json:"avatar" gorm:"type:varchar(255)"`
+	Username    string `json:"username" gorm:"type:varchar(50);uniqueIndex;"`
+	Email       string `json:"email" gorm:"type:varchar(50);uniqueIndex;not null"`
+	Password    string `json:"password" gorm:"type:varchar(255);not null"`
+	Nickname    string `json:"nickname" gorm:"type:varchar(50)"`
+	Avatar      string `json:"avatar" gorm:"type:varchar(255)"`
+	IdleTimeout int    `json:"idle_timeout" gorm:"not null;default:0"`
+	PlanId      uint   `json:"plan_id" gorm:"not null;default:0"`
 }
 
 type UserParam struct {
@@ -20,6 +22,8 @@ type UserParam struct {
 	Email    string `json:"email"`
 	Nickname string `json:"nickname"`
 	Code     string `json:"code"`
+
+	IdleTimeout int `json:"idle_timeout"`
 }
 
 type EmailParam struct {
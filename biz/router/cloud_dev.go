@@ -20,17 +20,56 @@ func RegisterCloudDev(r *route.RouterGroup) {
 		commonRouter.POST("/details", handler.AppGetPodInfo)
 		commonRouter.GET("/list", handler.AppList)
 		commonRouter.POST("/create", handler.AppCreate)
+		commonRouter.POST("/provision/status", handler.AppProvisionStatus)
+		commonRouter.POST("/clone", handler.AppClone)
 		commonRouter.POST("/stop", handler.AppStop)
 		commonRouter.POST("/restart", handler.AppRestart)
 		commonRouter.POST("/delete", handler.AppDelete)
 		commonRouter.GET("/details/list", handler.AppGetPodStateList)
+		commonRouter.GET("/events", handler.AppGetEvents)
+		commonRouter.GET("/metrics", handler.AppGetMetrics)
 		commonRouter.POST("/log", handler.AppGetLog)
-		commonRouter.POST("/update")
+		commonRouter.GET("/log/stream", handler.AppLogStream)
+		commonRouter.GET("/terminal", handler.AppTerminal)
+		commonRouter.POST("/update", handler.AppUpdate)
+		commonRouter.POST("/idle-timeout", handler.AppSetIdleTimeout)
+		commonRouter.POST("/password", handler.AppGetPassword)
+		commonRouter.POST("/password/rotate", handler.AppRotatePassword)
 		commonRouter.POST("/usage", handler.AppGetUsage)
+		commonRouter.GET("/quota", handler.AppGetQuota)
+		commonRouter.GET("/template/list", handler.TemplateList)
+		commonRouter.GET("/plan/list", handler.PlanList)
+		commonRouter.POST("/schedule/create", handler.ScheduleCreate)
+		commonRouter.POST("/schedule/list", handler.ScheduleList)
+		commonRouter.POST("/schedule/delete", handler.ScheduleDelete)
+		commonRouter.POST("/snapshot/create", handler.SnapshotCreate)
+		commonRouter.POST("/snapshot/list", handler.SnapshotList)
+		commonRouter.POST("/snapshot/delete", handler.SnapshotDelete)
+		commonRouter.POST("/snapshot/restore", handler.SnapshotRestore)
+		commonRouter.GET("/files/list", handler.FileList)
+		commonRouter.GET("/files/download", handler.FileDownload)
+		commonRouter.POST("/files/upload", handler.FileUpload)
+		commonRouter.POST("/files/delete", handler.FileDelete)
+		commonRouter.POST("/git/credential/create", handler.GitCredentialCreate)
+		commonRouter.GET("/git/credential/list", handler.GitCredentialList)
+		commonRouter.POST("/git/credential/delete", handler.GitCredentialDelete)
 	}
 
-	adminRouter := r.Group("/admin")
+	adminRouter := r.Group("/admin", middleware.JwtMiddleware.MiddlewareFunc(), middleware.AdminAuth())
 	{
 		adminRouter.GET("/")
+		adminRouter.GET("/template/list", handler.TemplateList)
+		adminRouter.POST("/template/create", handler.TemplateCreate)
+		adminRouter.POST("/template/update", handler.TemplateUpdate)
+		adminRouter.POST("/template/delete", handler.TemplateDelete)
+		adminRouter.GET("/plan/list", handler.PlanList)
+		adminRouter.POST("/plan/create", handler.PlanCreate)
+		adminRouter.POST("/plan/update", handler.PlanUpdate)
+		adminRouter.POST("/plan/delete", handler.PlanDelete)
+		adminRouter.POST("/plan/assign", handler.PlanAssign)
+		adminRouter.POST("/limit/set", handler.UserLimitSet)
+		adminRouter.POST("/limit/delete", handler.UserLimitDelete)
+		adminRouter.POST("/reconcile", handler.AdminReconcile)
+		adminRouter.GET("/reconcile/report", handler.AdminReconcileReport)
 	}
 }
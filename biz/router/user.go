@@ -21,6 +21,7 @@ func RegisterUser(r *route.RouterGroup) {
 	{
 		commonRouter.GET("/hello", handler.UserHello)
 		commonRouter.GET("/info", handler.UserInfo)
+		commonRouter.POST("/idle-timeout", handler.UserSetIdleTimeout)
 	}
 
 	adminRouter := r.Group("/admin")
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"learn/biz/model"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+
+	maxTags      = 10
+	maxTagLength = 32
+)
+
+// appStates ListApp 可能计算出的应用状态
+var appStates = map[string]bool{
+	"running":   true,
+	"pending":   true,
+	"cloning":   true,
+	"stopped":   true,
+	"succeeded": true,
+	"failed":    true,
+}
+
+// appSortKeys 可排序的字段，返回值按字典序比较即为该字段的顺序
+var appSortKeys = map[string]func(*model.Application) string{
+	"created_at": func(a *model.Application) string { return sortableTime(a.CreatedAt) },
+	"updated_at": func(a *model.Application) string { return sortableTime(a.UpdatedAt) },
+	"name":       func(a *model.Application) string { return strings.ToLower(a.Name) },
+	"state":      func(a *model.Application) string { return a.State },
+}
+
+// appListQuery 解析后的列表参数
+type appListQuery struct {
+	states map[string]bool
+	name   string
+	tag    string
+	sort   string
+	desc   bool
+	key    func(*model.Application) string
+	after  *appCursor
+	limit  int
+}
+
+// appCursor 上一页最后一个应用的排序键，Sort 用于拒绝与当前排序方式不一致的游标
+type appCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   uint   `json:"i"`
+}
+
+func newAppListQuery(param *model.AppListParam) (*appListQuery, error) {
+	query := &appListQuery{
+		name:  strings.ToLower(strings.TrimSpace(param.Name)),
+		tag:   strings.TrimSpace(param.Tag),
+		sort:  param.Sort,
+		limit: param.Limit,
+	}
+
+	if param.State != "" {
+		query.states = make(map[string]bool)
+		for _, state := range strings.Split(param.State, ",") {
+			state = strings.TrimSpace(state)
+			if !appStates[state] {
+				return nil, fmt.Errorf("不支持的状态: %s", state)
+			}
+			query.states[state] = true
+		}
+	}
+
+	if query.sort == "" {
+		query.sort = "created_at"
+	}
+	field := strings.TrimPrefix(query.sort, "-")
+	query.desc = field != query.sort
+	query.key = appSortKeys[field]
+	if query.key == nil {
+		return nil, fmt.Errorf("不支持的排序字段: %s", field)
+	}
+
+	switch {
+	case query.limit == 0:
+		query.limit = defaultListLimit
+	case query.limit < 0 || query.limit > maxListLimit:
+		return nil, fmt.Errorf("limit 需要在 1 到 %d 之间", maxListLimit)
+	}
+
+	if param.Cursor != "" {
+		cursor, err := decodeAppCursor(param.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != query.sort {
+			return nil, errors.New("游标与排序方式不一致，请从第一页重新查询")
+		}
+		query.after = cursor
+	}
+	return query, nil
+}
+
+// filter 过滤应用，byState 为 false 时只按名称和标签过滤，为 true 时只按状态过滤
+func (q *appListQuery) filter(applications []*model.Application, byState bool) []*model.Application {
+	filtered := applications[:0]
+	for _, application := range applications {
+		if byState {
+			if q.states != nil && !q.states[application.State] {
+				continue
+			}
+		} else {
+			if q.name != "" && !strings.Contains(strings.ToLower(application.Name), q.name) {
+				continue
+			}
+			if q.tag != "" && !hasTag(application, q.tag) {
+				continue
+			}
+		}
+		filtered = append(filtered, application)
+	}
+	return filtered
+}
+
+// page 排序后返回游标之后的一页，排序键相同时按 ID 排列，保证翻页时顺序稳定
+func (q *appListQuery) page(applications []*model.Application) (*model.AppListResult, error) {
+	less := func(aKey string, aID uint, bKey string, bID uint) bool {
+		if aKey != bKey {
+			return (aKey < bKey) != q.desc
+		}
+		return (aID < bID) != q.desc
+	}
+	sort.Slice(applications, func(i, j int) bool {
+		return less(q.key(applications[i]), applications[i].ID, q.key(applications[j]), applications[j].ID)
+	})
+
+	start := 0
+	if q.after != nil {
+		start = sort.Search(len(applications), func(i int) bool {
+			return less(q.after.Key, q.after.ID, q.key(applications[i]), applications[i].ID)
+		})
+	}
+	end := start + q.limit
+	if end > len(applications) {
+		end = len(applications)
+	}
+
+	result := &model.AppListResult{Items: append([]*model.Application{}, applications[start:end]...)}
+	if end < len(applications) {
+		last := applications[end-1]
+		cursor, err := encodeAppCursor(&appCursor{Sort: q.sort, Key: q.key(last), ID: last.ID})
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = cursor
+	}
+	return result, nil
+}
+
+func encodeAppCursor(cursor *appCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeAppCursor(value string) (*appCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("游标格式错误")
+	}
+	var cursor appCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.New("游标格式错误")
+	}
+	return &cursor, nil
+}
+
+// sortableTime 定长的 UTC 时间，按字典序比较与按时间比较一致
+func sortableTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+func hasTag(application *model.Application, tag string) bool {
+	for _, t := range application.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTags 去掉首尾空白、空标签和重复的标签，并检查数量与长度
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("标签长度不能超过 %d 个字符: %s", maxTagLength, tag)
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("最多只能设置 %d 个标签", maxTags)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"learn/biz/model"
+	"learn/biz/testutil"
+	"learn/biz/util"
+)
+
+// newListFixture 在进程内后端创建 alpha、beta、gamma、delta 四个工作空间并停止 beta 和 delta
+func newListFixture(t *testing.T) (*AppService, map[string]string) {
+	t.Helper()
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	testutil.CreateUser(t, 1)
+	s := NewAppServiceWithBackend(context.Background(), testutil.NewContext(1), util.NewMemoryBackend())
+
+	deployments := make(map[string]string)
+	for _, app := range []struct {
+		name string
+		tags []string
+	}{
+		{"alpha", []string{"go"}},
+		{"beta", []string{"go", "web"}},
+		{"Gamma", []string{"web"}},
+		{"delta", nil},
+	} {
+		deployments[app.name] = createAndWait(t, s, &model.AppParam{Application: model.Application{
+			Name: app.name, Cpu: "1", Memory: "2Gi", Tags: app.tags,
+		}})
+	}
+	for _, name := range []string{"beta", "delta"} {
+		if err := s.StopApp(&model.AppParam{Application: model.Application{Deployment: deployments[name]}}); err != nil {
+			t.Fatalf("停止应用失败: %v", err)
+		}
+		waitFor(t, "停止", func() bool { return stateOf(t, s, deployments[name]) == "stopped" })
+	}
+	return s, deployments
+}
+
+// namesOf 按返回顺序列出应用名称
+func namesOf(result *model.AppListResult) []string {
+	names := make([]string, 0, len(result.Items))
+	for _, application := range result.Items {
+		names = append(names, application.Name)
+	}
+	return names
+}
+
+func listNames(t *testing.T, s *AppService, param *model.AppListParam) []string {
+	t.Helper()
+	result, err := s.ListApp(param)
+	if err != nil {
+		t.Fatalf("查询应用列表失败: %v", err)
+	}
+	return namesOf(result)
+}
+
+func TestListAppFilters(t *testing.T) {
+	s, _ := newListFixture(t)
+
+	cases := []struct {
+		param *model.AppListParam
+		want  []string
+	}{
+		// 状态是查询时计算出来的，不是数据库中的字段
+		{&model.AppListParam{State: "stopped", Sort: "name"}, []string{"beta", "delta"}},
+		{&model.AppListParam{State: "running,stopped", Sort: "name"}, []string{"alpha", "beta", "delta", "Gamma"}},
+		// 名称不区分大小写按子串匹配
+		{&model.AppListParam{Name: "GAM"}, []string{"Gamma"}},
+		{&model.AppListParam{Tag: "go", Sort: "name"}, []string{"alpha", "beta"}},
+		{&model.AppListParam{Tag: "web", State: "running"}, []string{"Gamma"}},
+	}
+	for _, c := range cases {
+		if got := listNames(t, s, c.param); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%+v 返回 %v，期望 %v", *c.param, got, c.want)
+		}
+	}
+
+	if _, err := s.ListApp(&model.AppListParam{State: "unknown"}); err == nil {
+		t.Error("不支持的状态应当返回错误")
+	}
+}
+
+func TestListAppSort(t *testing.T) {
+	s, _ := newListFixture(t)
+
+	if got, want := listNames(t, s, &model.AppListParam{Sort: "-name"}), []string{"Gamma", "delta", "beta", "alpha"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("按名称倒序返回 %v，期望 %v", got, want)
+	}
+	if got, want := listNames(t, s, &model.AppListParam{Sort: "-created_at"}), []string{"delta", "Gamma", "beta", "alpha"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("按创建时间倒序返回 %v，期望 %v", got, want)
+	}
+}
+
+func TestListAppCursorAcrossTies(t *testing.T) {
+	s, _ := newListFixture(t)
+
+	// 按状态排序时两两相同，逐条翻页不能重复也不能遗漏
+	all := listNames(t, s, &model.AppListParam{Sort: "state", Limit: maxListLimit})
+	var paged []string
+	param := &model.AppListParam{Sort: "state", Limit: 1}
+	for {
+		result, err := s.ListApp(param)
+		if err != nil {
+			t.Fatalf("翻页查询失败: %v", err)
+		}
+		paged = append(paged, namesOf(result)...)
+		if result.NextCursor == "" {
+			break
+		}
+		if len(paged) > len(all) {
+			t.Fatalf("翻页没有结束: %v", paged)
+		}
+		param.Cursor = result.NextCursor
+	}
+	if !reflect.DeepEqual(paged, all) {
+		t.Fatalf("翻页结果为 %v，期望 %v", paged, all)
+	}
+	if len(all) != 4 || all[0] != "alpha" || all[1] != "Gamma" {
+		t.Fatalf("按状态排序的结果为 %v", all)
+	}
+}
+
+func TestListAppRejectsCursorOfOtherSort(t *testing.T) {
+	s, _ := newListFixture(t)
+
+	result, err := s.ListApp(&model.AppListParam{Sort: "name", Limit: 2})
+	if err != nil {
+		t.Fatalf("查询应用列表失败: %v", err)
+	}
+	if result.NextCursor == "" {
+		t.Fatal("还有下一页时应当返回游标")
+	}
+	for _, sort := range []string{"-name", "created_at"} {
+		if _, err := s.ListApp(&model.AppListParam{Sort: sort, Limit: 2, Cursor: result.NextCursor}); err == nil {
+			t.Errorf("排序方式为 %s 时使用按 name 排序的游标应当返回错误", sort)
+		}
+	}
+	if _, err := s.ListApp(&model.AppListParam{Cursor: "not-a-cursor"}); err == nil {
+		t.Error("格式错误的游标应当返回错误")
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"learn/biz/model"
+)
+
+// CloneApp 以源工作空间的数据卷为数据源克隆出新的工作空间，进度通过 /provision/status 查询
+// 复制期间源工作空间会被停止，克隆卷就绪后再恢复运行
+func (s *AppService) CloneApp(sourceDeployment, newName string) (string, error) {
+	source, err := s.ResolveApp(0, sourceDeployment)
+	if err != nil {
+		return "", err
+	}
+	if newName == "" {
+		newName = source.Name + "-copy"
+	}
+
+	template, err := NewTemplateService(s.ctx, s.c).GetTemplate(source.TemplateId)
+	if err != nil {
+		return "", err
+	}
+
+	sourceParam := KubernetesParamOf(source)
+	status, err := s.backend.Status(sourceParam)
+	if err != nil {
+		return "", err
+	}
+	storage, err := s.backend.VolumeSize(sourceParam)
+	if err != nil {
+		return "", err
+	}
+	running := status.Replicas > 0
+
+	laterfix := uuid.NewString()[:8]
+	kbParam := &model.KubernetesParam{
+		Namespace:  sourceParam.Namespace,
+		Deployment: fmt.Sprintf("deployment-%s", laterfix),
+		Pod:        fmt.Sprintf("pod-%s", laterfix),
+		Svc:        fmt.Sprintf("svc-%s", laterfix),
+		Pvc:        fmt.Sprintf("pvc-%s", laterfix),
+		Secret:     fmt.Sprintf("secret-%s", laterfix),
+		State:      "initializing",
+		Port:       template.Ports[0].ContainerPort,
+	}
+
+	// 副本沿用源应用的密码
+	password, err := s.backend.Password(sourceParam)
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", errors.New("无法读取源应用的密码")
+	}
+
+	appParam := &model.AppParam{
+		Application: model.Application{
+			Name:       newName,
+			UserId:     source.UserId,
+			Cpu:        source.Cpu,
+			Memory:     source.Memory,
+			Storage:    storage,
+			TemplateId: source.TemplateId,
+			Repos:      source.Repos,
+		},
+		PodPassword: password,
+	}
+
+	application := &model.Application{
+		Name:        newName,
+		UserId:      source.UserId,
+		Cpu:         source.Cpu,
+		Memory:      source.Memory,
+		Storage:     storage,
+		PodName:     kbParam.Pod,
+		Deployment:  kbParam.Deployment,
+		TemplateId:  source.TemplateId,
+		IdleTimeout: source.IdleTimeout,
+		Repos:       source.Repos,
+		Tags:        source.Tags,
+	}
+
+	// 检查限额与写入创建记录在用户锁内完成，创建记录写入后即计入用量
+	var record *model.Provision
+	err = withUserLock(s.ctx, source.UserId, func() error {
+		err := admitWorkspace(s.ctx, s.backend, source.UserId, source.TemplateId, source.Cpu, source.Memory, storage)
+		if err != nil {
+			return err
+		}
+		err = checkWorkspaceQuota(s.ctx, s.backend, source.UserId, sourceParam.Namespace, source.Cpu, source.Memory, storage)
+		if err != nil {
+			return err
+		}
+		record, err = NewProvisionService(s.ctx, s.c).Start(kbParam, application.UserId)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx := context.Background()
+	backend := s.backend.WithContext(ctx)
+	provisions := NewProvisionService(ctx, nil)
+
+	steps := []provisionStep{secretStep(backend, kbParam, password)}
+	if running {
+		steps = append(steps, provisionStep{
+			name:  "quiesce_source",
+			state: model.ProvisionSourceStopped,
+			run: func() error {
+				// 先记录再停止，实例在停止途中退出时恢复流程同样会重新启动源工作空间
+				if err := provisions.markSource(record, source.Deployment, true); err != nil {
+					return err
+				}
+				if err := stopWorkspace(ctx, backend, sourceParam); err != nil {
+					return err
+				}
+				// 缩容后旧 Pod 仍在写入数据卷，全部退出后再开始复制
+				return backend.WaitStopped(sourceParam, 5*time.Minute)
+			},
+			undo: func() error { return provisions.resumeSource(backend, record) },
+		})
+	}
+	steps = append(steps,
+		provisionStep{
+			name:  "clone_pvc",
+			state: model.ProvisionPvcCreated,
+			run:   func() error { return backend.CloneVolume(kbParam, sourceParam, storage) },
+			undo:  func() error { return backend.DeleteVolume(kbParam) },
+		},
+		deploymentStep(backend, kbParam, appParam, template),
+		provisionStep{
+			// 使用 WaitForFirstConsumer 的存储类要等新 Pod 调度后才开始复制，因此放在创建 Deployment 之后
+			name:  "wait_clone",
+			state: model.ProvisionPvcBound,
+			run:   func() error { return backend.WaitVolumeReady(kbParam, 10*time.Minute) },
+		},
+	)
+	if running {
+		steps = append(steps, provisionStep{
+			name:  "resume_source",
+			state: model.ProvisionSourceResumed,
+			run:   func() error { return provisions.resumeSource(backend, record) },
+		})
+	}
+	steps = append(steps,
+		serviceStep(backend, kbParam, application),
+		saveApplicationStep(ctx, application),
+	)
+
+	log.Printf("开始克隆应用 - Source: %s, Target: %s", source.Deployment, kbParam.Deployment)
+
+	go func() {
+		_ = provisions.Run(record, steps)
+	}()
+
+	return kbParam.Deployment, nil
+}
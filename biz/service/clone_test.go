@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/testutil"
+	"learn/biz/util"
+)
+
+func TestCloneAppOnMemoryBackend(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	testutil.CreateUser(t, 1)
+	backend := util.NewMemoryBackend()
+	s := NewAppServiceWithBackend(context.Background(), testutil.NewContext(1), backend)
+
+	source := createAndWait(t, s, &model.AppParam{
+		Application: model.Application{Name: "demo", Cpu: "1", Memory: "2Gi", Storage: "5Gi"},
+		PodPassword: "secret-password",
+	})
+	clone, err := s.CloneApp(source, "")
+	if err != nil {
+		t.Fatalf("克隆应用失败: %v", err)
+	}
+	record := waitProvision(t, s, clone)
+	if record.State != model.ProvisionReady {
+		t.Fatalf("克隆失败: %s", record.Error)
+	}
+	if record.Source != source || record.SourceStopped {
+		t.Fatalf("克隆结束后源工作空间的记录为 %q, %v", record.Source, record.SourceStopped)
+	}
+
+	// 源工作空间在复制结束后恢复运行
+	if state := stateOf(t, s, source); state != "running" {
+		t.Fatalf("克隆后源应用的状态为 %q", state)
+	}
+
+	application, err := s.ResolveApp(0, clone)
+	if err != nil {
+		t.Fatalf("克隆完成后没有应用记录: %v", err)
+	}
+	if application.Name != "demo-copy" || application.Storage != "5Gi" {
+		t.Fatalf("克隆出的应用为 %s, %s", application.Name, application.Storage)
+	}
+	password, err := backend.Password(KubernetesParamOf(application))
+	if err != nil || password != "secret-password" {
+		t.Fatalf("克隆出的应用密码为 %q, %v", password, err)
+	}
+}
+
+func TestRecoverStaleProvisionsResumesClonedSource(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	testutil.CreateUser(t, 1)
+	backend := util.NewMemoryBackend()
+	s := NewAppServiceWithBackend(context.Background(), testutil.NewContext(1), backend)
+
+	source := createAndWait(t, s, &model.AppParam{Application: model.Application{Name: "demo", Cpu: "1", Memory: "2Gi"}})
+	application, err := s.ResolveApp(0, source)
+	if err != nil {
+		t.Fatalf("查询源应用失败: %v", err)
+	}
+	// 克隆流程停止源工作空间后所在实例退出
+	if err := stopWorkspace(context.Background(), backend, KubernetesParamOf(application)); err != nil {
+		t.Fatalf("停止源应用失败: %v", err)
+	}
+	stale := time.Now().Add(-2 * provisionStaleAfter)
+	record := &model.Provision{
+		UserId:        1,
+		Deployment:    "deployment-clone001",
+		Namespace:     "ns-1",
+		State:         model.ProvisionSourceStopped,
+		HeartbeatAt:   &stale,
+		Source:        source,
+		SourceStopped: true,
+	}
+	if err := config.DB.Create(record).Error; err != nil {
+		t.Fatalf("写入创建记录失败: %v", err)
+	}
+
+	recoverStaleProvisions(context.Background(), backend)
+
+	if state := stateOf(t, s, source); state != "running" {
+		t.Fatalf("回滚后源应用的状态为 %q", state)
+	}
+	var current model.Provision
+	config.DB.First(&current, record.ID)
+	if current.State != model.ProvisionFailed || !current.RolledBack || current.SourceStopped {
+		t.Fatalf("回滚后的创建记录为 %+v", current)
+	}
+}
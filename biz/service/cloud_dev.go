@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"time"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/google/uuid"
@@ -16,23 +18,45 @@ import (
 )
 
 type AppService struct {
-	ctx context.Context
-	c   *app.RequestContext
+	ctx     context.Context
+	c       *app.RequestContext
+	backend util.WorkspaceBackend
 }
 
 func NewAppService(ctx context.Context, c *app.RequestContext) *AppService {
-	return &AppService{ctx: ctx, c: c}
+	return NewAppServiceWithBackend(ctx, c, util.NewWorkspaceBackend(ctx))
 }
 
-func (s *AppService) ListApp() ([]*model.Application, error) {
+// NewAppServiceWithBackend 使用指定的工作空间后端，测试时可传入 util.MemoryBackend
+func NewAppServiceWithBackend(ctx context.Context, c *app.RequestContext, backend util.WorkspaceBackend) *AppService {
+	return &AppService{ctx: ctx, c: c, backend: backend}
+}
+
+// requireCluster 以 --dev 启动时没有集群，快照、文件、终端、监控等直接操作 Kubernetes 的功能不可用
+func requireCluster() error {
+	if util.DevMode() {
+		return errors.New("开发模式下不支持该功能")
+	}
+	return nil
+}
+
+// ListApp 按条件查询当前用户的应用
+// State 由 Kubernetes 实时计算而不在数据库中，所以先取出用户的全部应用、计算状态后再过滤、排序和分页
+// 不带任何参数时与加入分页之前一样返回全部应用
+func (s *AppService) ListApp(param *model.AppListParam) (*model.AppListResult, error) {
 	userId, ok := s.c.Get("user_id")
 	if !ok {
 		return nil, errors.New("没有找到用户ID")
 	}
 
+	query, err := newAppListQuery(param)
+	if err != nil {
+		return nil, err
+	}
+
 	var applications []*model.Application
 
-	err := config.DB.WithContext(s.ctx).
+	err = config.DB.WithContext(s.ctx).
 		Where("user_id = ?", userId).
 		Find(&applications).Error
 
@@ -40,21 +64,59 @@ func (s *AppService) ListApp() ([]*model.Application, error) {
 		return nil, err
 	}
 
-	// 为每个应用查询Pod状态并设置State
-	kubernetesUtil := util.NewKubernetesUtil(s.ctx)
-	namespace := fmt.Sprintf("ns-%d", userId.(int64))
+	// 名称和标签不依赖状态，先过滤以减少状态查询
+	applications = query.filter(applications, false)
+	s.fillStates(fmt.Sprintf("ns-%d", userId.(int64)), applications)
+	applications = query.filter(applications, true)
 
-	for i := range applications {
-		kbParam := &model.KubernetesParam{
-			Namespace:  namespace,
-			Deployment: applications[i].Deployment,
+	if param.IsEmpty() {
+		query.limit = len(applications)
+	}
+	return query.page(applications)
+}
+
+// fillStates 为每个应用查询Pod状态并设置State，未正常运行时附带原因
+func (s *AppService) fillStates(namespace string, applications []*model.Application) {
+
+	// 命名空间的事件只在有工作空间未正常运行时查询一次，进程内后端没有事件
+	var events []corev1.Event
+	eventsLoaded := false
+	reasonOf := func(kbParam *model.KubernetesParam) string {
+		eventLister, ok := s.backend.(util.EventLister)
+		if !ok {
+			return ""
 		}
+		if !eventsLoaded {
+			eventsLoaded = true
+			var err error
+			if events, err = eventLister.ListNamespaceEvents(namespace); err != nil {
+				log.Printf("获取事件失败 - Namespace: %s, Error: %v", namespace, err)
+			}
+		}
+		return util.WorkspaceReason(util.WorkspaceEventsOf(events, kbParam))
+	}
 
-		// 查询Pod信息
-		pod, err := kubernetesUtil.GetPodInfo(kbParam)
-		if err != nil {
-			log.Printf("获取Pod信息失败 - Deployment: %s, Error: %v", applications[i].Deployment, err)
+	for i := range applications {
+		kbParam := KubernetesParamOf(applications[i])
+
+		status, err := s.backend.Status(kbParam)
+		if err != nil || status.Pod == nil {
+			if err != nil {
+				log.Printf("获取Pod信息失败 - Deployment: %s, Error: %v", applications[i].Deployment, err)
+			}
 			applications[i].State = "stopped"
+			// 副本数不为0却没有Pod，通常是配额不足或 ReplicaSet 创建 Pod 失败
+			if err == nil && status.Replicas > 0 {
+				applications[i].Reason = reasonOf(kbParam)
+			}
+			continue
+		}
+		pod := status.Pod
+
+		repoStatus, cloning := util.GitCloneStatusOf(pod)
+		applications[i].RepoStatus = repoStatus
+		if cloning {
+			applications[i].State = "cloning"
 			continue
 		}
 
@@ -71,9 +133,10 @@ func (s *AppService) ListApp() ([]*model.Application, error) {
 		default:
 			applications[i].State = "stopped"
 		}
+		if applications[i].State != "running" || !podReady(pod) {
+			applications[i].Reason = reasonOf(kbParam)
+		}
 	}
-
-	return applications, nil
 }
 
 func (s *AppService) CreateApp(appParam *model.AppParam) (string, error) {
@@ -84,6 +147,18 @@ func (s *AppService) CreateApp(appParam *model.AppParam) (string, error) {
 		return "", errors.New("没有找到用户ID")
 	}
 
+	template, err := NewTemplateService(s.ctx, s.c).GetTemplate(appParam.TemplateId)
+	if err != nil {
+		return "", err
+	}
+
+	appParam.UserId = uint(userId.(int64))
+
+	// 用户没有指定密码时由平台生成，之后可通过 /password 查询
+	if err := preparePassword(appParam); err != nil {
+		return "", err
+	}
+
 	laterfix := uuid.NewString()[:8]
 
 	kbParam := &model.KubernetesParam{
@@ -92,69 +167,157 @@ func (s *AppService) CreateApp(appParam *model.AppParam) (string, error) {
 		Pod:        fmt.Sprintf("pod-%s", laterfix),
 		Svc:        fmt.Sprintf("svc-%s", laterfix),
 		Pvc:        fmt.Sprintf("pvc-%s", laterfix),
+		Secret:     fmt.Sprintf("secret-%s", laterfix),
 		State:      "initializing",
+		Port:       template.Ports[0].ContainerPort,
+	}
+
+	if err := validateRepos(util.NewKubernetesUtil(s.ctx), kbParam.Namespace, appParam.Repos); err != nil {
+		return "", err
+	}
+	if appParam.Tags, err = normalizeTags(appParam.Tags); err != nil {
+		return "", err
+	}
+
+	// 检查限额与写入创建记录在用户锁内完成，创建记录写入后即计入用量
+	var record *model.Provision
+	err = withUserLock(s.ctx, appParam.UserId, func() error {
+		// 规格按套餐和模板补全，并且不能超出用户套餐的范围
+		if err := applyPlan(s.ctx, s.backend, appParam, template); err != nil {
+			return err
+		}
+
+		// 超出配额时直接拒绝，避免留下一直 Pending 的 Pod
+		err := checkWorkspaceQuota(s.ctx, s.backend, appParam.UserId, kbParam.Namespace, appParam.Cpu, appParam.Memory, appParam.Storage)
+		if err != nil {
+			log.Printf("检查资源配额失败: %v", err)
+			return err
+		}
+
+		record, err = NewProvisionService(s.ctx, s.c).Start(kbParam, appParam.UserId)
+		return err
+	})
+	if err != nil {
+		return "", err
 	}
 
 	application := &model.Application{
 		Name:       appParam.Name,
-		UserId:     uint(userId.(int64)),
+		UserId:     appParam.UserId,
 		Cpu:        appParam.Cpu,
 		Memory:     appParam.Memory,
+		Storage:    appParam.Storage,
 		PodName:    kbParam.Pod,
 		Deployment: kbParam.Deployment,
-	}
-
-	if err := util.NewKubernetesUtil(s.ctx).EnsureNamespace(kbParam.Namespace); err != nil {
-		log.Printf("创建命名空间失败: %v", err)
-		return "", err
+		TemplateId: appParam.TemplateId,
+		Repos:      appParam.Repos,
+		Tags:       appParam.Tags,
 	}
 
 	log.Printf("开始提交创建请求")
 
+	// 请求结束后 s.ctx 会被取消，后台创建流程使用独立的上下文
+	ctx := context.Background()
+	backend := s.backend.WithContext(ctx)
+	steps := []provisionStep{
+		secretStep(backend, kbParam, appParam.PodPassword),
+		volumeStep(backend, kbParam, appParam),
+		deploymentStep(backend, kbParam, appParam, template),
+		serviceStep(backend, kbParam, application),
+		saveApplicationStep(ctx, application),
+	}
+
 	go func() {
-		err := util.NewKubernetesUtil(s.ctx).CreatePvc(kbParam, appParam)
-		if err != nil {
-			log.Printf("创建PVC失败: %v", err)
-			return
-		}
-		err = util.NewKubernetesUtil(s.ctx).CreateDeployment(kbParam, appParam)
-		if err != nil {
-			log.Printf("创建Deployment失败: %v", err)
-			return
-		}
-		err = util.NewKubernetesUtil(s.ctx).CreateSvc(kbParam, application)
-		if err != nil {
-			log.Printf("创建Svc失败: %v", err)
-			return
-		}
-		err = config.DB.WithContext(s.ctx).Create(application).Error
-		if err != nil {
-			log.Printf("插入数据库失败: %v", err)
-			return
-		}
+		_ = NewProvisionService(ctx, nil).Run(record, steps)
 	}()
 
 	return kbParam.Deployment, nil
 }
 
-func (s *AppService) GetStateOfApp(kbParam *model.KubernetesParam) (*corev1.PodStatus, error) {
-	userId, ok := s.c.Get("user_id")
+func secretStep(backend util.WorkspaceBackend, kbParam *model.KubernetesParam, password string) provisionStep {
+	return provisionStep{
+		name:  "create_secret",
+		state: model.ProvisionSecretCreated,
+		run:   func() error { return backend.CreateSecret(kbParam, password) },
+		undo:  func() error { return backend.DeleteSecret(kbParam) },
+	}
+}
 
-	if !ok {
-		return nil, errors.New("没有找到用户ID")
+func volumeStep(backend util.WorkspaceBackend, kbParam *model.KubernetesParam, appParam *model.AppParam) provisionStep {
+	return provisionStep{
+		name:  "create_pvc",
+		state: model.ProvisionPvcCreated,
+		run:   func() error { return backend.CreateVolume(kbParam, appParam) },
+		undo:  func() error { return backend.DeleteVolume(kbParam) },
 	}
+}
 
-	kbParam.Namespace = fmt.Sprintf("ns-%d", userId.(int64))
+func deploymentStep(backend util.WorkspaceBackend, kbParam *model.KubernetesParam, appParam *model.AppParam, template *model.WorkspaceTemplate) provisionStep {
+	return provisionStep{
+		name:  "create_deployment",
+		state: model.ProvisionDeploymentCreated,
+		run:   func() error { return backend.CreateWorkload(kbParam, appParam, template) },
+		undo:  func() error { return backend.DeleteWorkload(kbParam) },
+	}
+}
 
-	podInfo, err := util.NewKubernetesUtil(s.ctx).GetPodInfo(kbParam)
+// serviceStep 创建访问入口，Kubernetes 后端按 EXPOSE_MODE 创建，回滚时连同 Ingress/HTTPRoute 一起删除
+func serviceStep(exposer interface {
+	Expose(*model.KubernetesParam, *model.Application) error
+	Unexpose(*model.KubernetesParam) error
+}, kbParam *model.KubernetesParam, application *model.Application) provisionStep {
+	return provisionStep{
+		name:  "create_service",
+		state: model.ProvisionServiceCreated,
+		run:   func() error { return exposer.Expose(kbParam, application) },
+		undo:  func() error { return exposer.Unexpose(kbParam) },
+	}
+}
+
+func saveApplicationStep(ctx context.Context, application *model.Application) provisionStep {
+	return provisionStep{
+		name:  "save_application",
+		state: model.ProvisionReady,
+		run:   func() error { return config.DB.WithContext(ctx).Create(application).Error },
+	}
+}
+
+// GetPodOfApp 返回应用当前的 Pod 原始对象，仅供管理员查看
+func (s *AppService) GetPodOfApp(kbParam *model.KubernetesParam) (*corev1.Pod, error) {
+	application, err := s.ResolveApp(0, kbParam.Deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := s.backend.Status(KubernetesParamOf(application))
 	if err != nil {
 		log.Println(err.Error())
 		return nil, err
 	}
-	return &podInfo.Status, nil
+	if status.Pod == nil {
+		return nil, fmt.Errorf("获取Pod信息失败: 应用 %s 当前没有运行中的Pod", application.Deployment)
+	}
+	return status.Pod, nil
 }
 
+func (s *AppService) GetPodViewOfApp(kbParam *model.KubernetesParam) (*model.WorkspacePodView, error) {
+	pod, err := s.GetPodOfApp(kbParam)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := s.applicationNames()
+	if err != nil {
+		return nil, err
+	}
+	return podViewOf(pod, names[kbParam.Deployment]), nil
+}
+
+// GetPodStateList 返回用户命名空间下所有 Pod 的原始对象，仅供管理员查看
 func (s *AppService) GetPodStateList() ([]corev1.Pod, error) {
+	if err := requireCluster(); err != nil {
+		return nil, err
+	}
 	userId, ok := s.c.Get("user_id")
 
 	if !ok {
@@ -165,152 +328,389 @@ func (s *AppService) GetPodStateList() ([]corev1.Pod, error) {
 		Namespace: fmt.Sprintf("ns-%d", userId.(int64)),
 	}
 
-	podList, err := util.NewKubernetesUtil(s.ctx).GetPodList(kbParam)
+	return util.NewKubernetesUtil(s.ctx).GetCachedPodList(kbParam.Namespace)
+}
+
+func (s *AppService) GetPodViewList() ([]*model.WorkspacePodView, error) {
+	pods, err := s.GetPodStateList()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := s.applicationNames()
 	if err != nil {
 		return nil, err
 	}
 
-	return podList.Items, nil
+	views := make([]*model.WorkspacePodView, 0, len(pods))
+	for i := range pods {
+		views = append(views, podViewOf(&pods[i], names[pods[i].Labels["deployment"]]))
+	}
+	return views, nil
 }
 
-func (s *AppService) DeleteApp(appParam *model.AppParam) error {
+// applicationNames 当前用户的 deployment 到应用名称的映射
+func (s *AppService) applicationNames() (map[string]string, error) {
 	userId, ok := s.c.Get("user_id")
 	if !ok {
-		return errors.New("没有找到用户ID")
+		return nil, errors.New("没有找到用户ID")
 	}
 
-	var laterfix string
-	if len(appParam.Deployment) >= 8 {
-		laterfix = appParam.Deployment[len(appParam.Deployment)-8:]
-	} else {
-		return errors.New("应用名称错误！")
+	var applications []*model.Application
+	err := config.DB.WithContext(s.ctx).Select("deployment", "name").Where("user_id = ?", userId).Find(&applications).Error
+	if err != nil {
+		return nil, err
 	}
 
-	kbParam := &model.KubernetesParam{
-		Namespace:  fmt.Sprintf("ns-%d", userId.(int64)),
-		Deployment: fmt.Sprintf("deployment-%s", laterfix),
-		Pod:        fmt.Sprintf("pod-%s", laterfix),
-		Svc:        fmt.Sprintf("svc-%s", laterfix),
-		Pvc:        fmt.Sprintf("pvc-%s", laterfix),
+	names := make(map[string]string, len(applications))
+	for _, application := range applications {
+		names[application.Deployment] = application.Name
 	}
+	return names, nil
+}
 
-	err := util.NewKubernetesUtil(s.ctx).DeleteDeploymentSvcPvc(kbParam)
+func (s *AppService) DeleteApp(appParam *model.AppParam) error {
+	application, err := s.ResolveApp(appParam.ID, appParam.Deployment)
 	if err != nil {
 		return err
 	}
+	kbParam := KubernetesParamOf(application)
+
+	// 已停止的应用没有访问入口，Destroy 会忽略已经不存在的资源
+	if err := s.backend.Destroy(kbParam); err != nil {
+		return err
+	}
 
-	err = config.DB.Delete(&model.Application{}, "deployment = ?", appParam.Deployment).Error
+	if err := NewScheduleService(s.ctx, s.c).deleteByApplication(application.ID); err != nil {
+		log.Printf("删除启停计划失败: %v", err)
+	}
+	if err := NewSnapshotService(s.ctx, s.c).deleteByApplication(application.ID); err != nil {
+		log.Printf("删除快照失败: %v", err)
+	}
+
+	return config.DB.WithContext(s.ctx).Delete(application).Error
+}
+
+func (s *AppService) StopApp(appParam *model.AppParam) error {
+	application, err := s.ResolveApp(appParam.ID, appParam.Deployment)
 	if err != nil {
 		return err
 	}
+	kbParam := KubernetesParamOf(application)
+
+	go func() {
+		ctx := context.Background()
+		_ = stopWorkspace(ctx, s.backend.WithContext(ctx), kbParam)
+	}()
 
 	return nil
 }
 
-func (s *AppService) StopApp(appParam *model.AppParam) error {
-	userId, ok := s.c.Get("user_id")
-	if !ok {
-		return errors.New("没有找到用户ID")
+// StopWorkspace 删除访问入口并把 Deployment 缩容到0，接口与后台任务共用这段逻辑
+func StopWorkspace(ctx context.Context, kbParam *model.KubernetesParam) error {
+	return stopWorkspace(ctx, util.NewWorkspaceBackend(ctx), kbParam)
+}
+
+func stopWorkspace(ctx context.Context, backend util.WorkspaceBackend, kbParam *model.KubernetesParam) error {
+	err := backend.Unexpose(kbParam)
+	if err != nil {
+		log.Printf("删除访问入口失败: %v", err)
 	}
 
-	var laterfix string
-	if len(appParam.Deployment) >= 8 {
-		laterfix = appParam.Deployment[len(appParam.Deployment)-8:]
-	} else {
-		return errors.New("应用名称错误！")
+	err = backend.Scale(kbParam, 0)
+	if err != nil {
+		log.Printf("修改Deployment副本数失败: %v", err)
+		return err
 	}
 
-	kbParam := &model.KubernetesParam{
-		Namespace:  fmt.Sprintf("ns-%d", userId.(int64)),
-		Deployment: fmt.Sprintf("deployment-%s", laterfix),
+	NewCounterService(ctx).ClearActivity(kbParam.Namespace, kbParam.Deployment)
+	return nil
+}
+
+// KubernetesParamOf 根据应用记录推导出对应的 Kubernetes 资源名称
+func KubernetesParamOf(application *model.Application) *model.KubernetesParam {
+	laterfix := application.Deployment[len(application.Deployment)-8:]
+	pvc := application.Pvc
+	if pvc == "" {
+		pvc = fmt.Sprintf("pvc-%s", laterfix)
+	}
+	return &model.KubernetesParam{
+		Namespace:  fmt.Sprintf("ns-%d", application.UserId),
+		Deployment: application.Deployment,
 		Pod:        fmt.Sprintf("pod-%s", laterfix),
 		Svc:        fmt.Sprintf("svc-%s", laterfix),
-		Pvc:        fmt.Sprintf("pvc-%s", laterfix),
+		Pvc:        pvc,
+		Secret:     fmt.Sprintf("secret-%s", laterfix),
+		Cpu:        application.Cpu,
+		Memory:     application.Memory,
 	}
+}
 
-	go func() {
-		err := util.NewKubernetesUtil(s.ctx).DeleteSvc(kbParam)
-		if err != nil {
-			log.Printf("删除Svc失败: %v", err)
-		}
+// SetIdleTimeout 设置单个应用的空闲超时（分钟），0 表示沿用用户默认值，负数表示不自动停止
+func (s *AppService) SetIdleTimeout(appParam *model.AppParam) error {
+	application, err := s.ResolveApp(appParam.ID, appParam.Deployment)
+	if err != nil {
+		return err
+	}
 
-		err = util.NewKubernetesUtil(s.ctx).ScaleDeployment(kbParam, 0)
-		if err != nil {
-			log.Printf("修改Deployment副本数失败: %v", err)
-		}
+	return config.DB.WithContext(s.ctx).Model(application).Update("idle_timeout", appParam.IdleTimeout).Error
+}
+
+func (s *AppService) RestartApp(appParam *model.AppParam) error {
+	application, err := s.ResolveApp(appParam.ID, appParam.Deployment)
+	if err != nil {
+		return err
+	}
+
+	// 启动已停止的工作空间同样要检查运行数量和资源总量
+	if err := admitStart(s.ctx, s.backend, application); err != nil {
+		return err
+	}
+
+	go func() {
+		ctx := context.Background()
+		_ = startWorkspace(ctx, s.backend.WithContext(ctx), application)
 	}()
 
 	return nil
 }
 
-func (s *AppService) RestartApp(appParam *model.AppParam) error {
-	userId, ok := s.c.Get("user_id")
-	if !ok {
-		return errors.New("没有找到用户ID")
+// StartWorkspace 把 Deployment 扩容到1并重新创建访问入口，接口与定时任务共用这段逻辑
+// 访问入口已存在时沿用原有对象，所以对运行中的应用重复调用是安全的
+func StartWorkspace(ctx context.Context, application *model.Application) error {
+	return startWorkspace(ctx, util.NewWorkspaceBackend(ctx), application)
+}
+
+func startWorkspace(ctx context.Context, backend util.WorkspaceBackend, application *model.Application) error {
+	kbParam := KubernetesParamOf(application)
+
+	template, err := NewTemplateService(ctx, nil).GetTemplate(application.TemplateId)
+	if err != nil {
+		log.Printf("获取模板失败: %v", err)
+		return err
 	}
+	kbParam.Port = template.Ports[0].ContainerPort
 
-	var laterfix string
-	if len(appParam.Deployment) >= 8 {
-		laterfix = appParam.Deployment[len(appParam.Deployment)-8:]
-	} else {
-		return errors.New("应用名称错误！")
+	// 停止期间浏览文件创建的辅助 Pod 仍挂载着数据卷，新 Pod 要等它退出才能挂载
+	if err := backend.ReleaseVolume(kbParam); err != nil {
+		log.Printf("删除辅助 Pod 失败 - Deployment: %s, Error: %v", application.Deployment, err)
+		return err
 	}
 
-	kbParam := &model.KubernetesParam{
-		Namespace:  fmt.Sprintf("ns-%d", userId.(int64)),
-		Deployment: fmt.Sprintf("deployment-%s", laterfix),
-		Pod:        fmt.Sprintf("pod-%s", laterfix),
-		Svc:        fmt.Sprintf("svc-%s", laterfix),
-		Pvc:        fmt.Sprintf("pvc-%s", laterfix),
+	// 所有启动途径（接口、启停计划、恢复快照）都要检查运行数量和资源总量，检查与扩容在用户锁内完成
+	err = withUserLock(ctx, application.UserId, func() error {
+		if err := admitStart(ctx, backend, application); err != nil {
+			return err
+		}
+		if err := syncQuota(ctx, backend, application.UserId); err != nil {
+			return err
+		}
+		return backend.Scale(kbParam, 1)
+	})
+	if err != nil {
+		log.Printf("启动工作空间失败 - Deployment: %s, Error: %v", application.Deployment, err)
+		return err
+	}
+	err = backend.Expose(kbParam, application)
+	if err != nil {
+		log.Printf("创建访问入口失败: %v", err)
+		return err
 	}
 
-	application := &model.Application{
-		Deployment: appParam.Deployment,
+	// 重新启动后从当前时间开始计算空闲时长
+	NewCounterService(ctx).RecordActivity(kbParam.Namespace, kbParam.Deployment, time.Now())
+
+	err = config.DB.WithContext(ctx).Model(&model.Application{}).Where("deployment = ?", application.Deployment).Updates(map[string]interface{}{
+		"url": application.Url,
+	}).Error
+
+	if err != nil {
+		log.Printf("更新应用URL失败: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateApp 修改应用名称与规格，运行中的应用优先原地调整Pod资源，不支持时回退为重建更新
+func (s *AppService) UpdateApp(appParam *model.AppParam) (*model.AppUpdateResult, error) {
+	application, err := s.ResolveApp(appParam.ID, appParam.Deployment)
+	if err != nil {
+		return nil, err
 	}
 
-	go func() {
-		err := util.NewKubernetesUtil(s.ctx).ScaleDeployment(kbParam, 1)
+	// 传入 tags 时整体替换，传空数组可清空标签
+	var tags []string
+	if appParam.Tags != nil {
+		if tags, err = normalizeTags(appParam.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &model.AppUpdateResult{Deployment: application.Deployment, Mode: "none"}
+
+	cpu, memory := application.Cpu, application.Memory
+	if appParam.Cpu != "" {
+		cpu = appParam.Cpu
+	}
+	if appParam.Memory != "" {
+		memory = appParam.Memory
+	}
+	if cpu != application.Cpu || memory != application.Memory {
+		if err := requireCluster(); err != nil {
+			return nil, err
+		}
+		plan, err := PlanOf(s.ctx, application.UserId)
 		if err != nil {
-			log.Printf("修改Deployment副本数失败: %v", err)
-			return
+			return nil, err
+		}
+		if err := checkPlanSpec(plan, cpu, memory, ""); err != nil {
+			return nil, err
 		}
-		err = util.NewKubernetesUtil(s.ctx).CreateSvc(kbParam, application)
+
+		// 运行中的工作空间调整规格会改变资源用量，检查、调整与记录新规格在用户锁内完成
+		err = withUserLock(s.ctx, application.UserId, func() error {
+			if err := s.resizeWorkspace(application, cpu, memory, result); err != nil {
+				return err
+			}
+			return config.DB.WithContext(s.ctx).Model(application).Updates(map[string]interface{}{
+				"cpu":    cpu,
+				"memory": memory,
+			}).Error
+		})
 		if err != nil {
-			log.Printf("创建Svc失败: %v", err)
-			return
+			return nil, err
 		}
+	}
 
-		err = config.DB.WithContext(s.ctx).Model(&model.Application{}).Where("deployment = ?", application.Deployment).Updates(map[string]interface{}{
-			"url": application.Url,
-		}).Error
+	if appParam.Name != "" {
+		err = config.DB.WithContext(s.ctx).Model(application).Update("name", appParam.Name).Error
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	if tags != nil {
+		err = config.DB.WithContext(s.ctx).Model(application).Select("tags").Updates(&model.Application{Tags: tags}).Error
 		if err != nil {
-			log.Printf("更新应用URL失败: %v", err)
-			return
+			return nil, err
 		}
-	}()
+	}
+
+	return result, nil
+}
 
+// resizeWorkspace 把工作空间调整为新规格，已停止的只修改模板，运行中的先检查限额再原地调整，不支持时回退为重建更新
+func (s *AppService) resizeWorkspace(application *model.Application, cpu, memory string, result *model.AppUpdateResult) error {
+	kbParam := &model.KubernetesParam{
+		Namespace:  fmt.Sprintf("ns-%d", application.UserId),
+		Deployment: application.Deployment,
+		Cpu:        cpu,
+		Memory:     memory,
+	}
+	kubernetesUtil := util.NewKubernetesUtil(s.ctx)
+
+	deployment, err := kubernetesUtil.GetDeployment(kbParam)
+	if err != nil {
+		return err
+	}
+
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas == 0 {
+		// 已停止的应用直接修改模板，下次启动生效，启动时再检查限额
+		if _, err := kubernetesUtil.UpdateDeploymentResources(kbParam); err != nil {
+			return err
+		}
+		result.Mode = "deferred"
+		return nil
+	}
+
+	if err := admitResize(s.ctx, s.backend, application, cpu, memory); err != nil {
+		return err
+	}
+	if err := syncQuota(s.ctx, s.backend, application.UserId); err != nil {
+		return err
+	}
+	if err = kubernetesUtil.ResizePodInPlace(kbParam); err == nil {
+		// 原地调整时模板同步修改，不会重建 Pod
+		result.Mode = "in-place"
+		return nil
+	}
+	log.Printf("原地调整资源失败，回退为重建更新 - Deployment: %s, Error: %v", application.Deployment, err)
+	if _, err := kubernetesUtil.UpdateDeploymentResources(kbParam); err != nil {
+		return err
+	}
+	result.Mode = "rollout"
+	result.Restarted = true
 	return nil
 }
 
+// GetEventsOfApp 返回应用的 Deployment、ReplicaSet、Pod 和 PVC 最近的事件
+func (s *AppService) GetEventsOfApp(param *model.EventParam) ([]model.WorkspaceEvent, error) {
+	application, err := s.ResolveApp(0, param.Deployment)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireCluster(); err != nil {
+		return nil, err
+	}
+	kbParam := KubernetesParamOf(application)
+
+	events, err := util.NewKubernetesUtil(s.ctx).ListNamespaceEvents(kbParam.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return util.WorkspaceEventsOf(events, kbParam), nil
+}
+
 func (s *AppService) GetLogOfApp(appParam *model.AppParam) (string, error) {
-	userId, ok := s.c.Get("user_id")
-	if !ok {
-		return "", errors.New("没有找到用户ID")
+	application, err := s.ResolveApp(appParam.ID, appParam.Deployment)
+	if err != nil {
+		return "", err
 	}
 
-	kbParam := &model.KubernetesParam{
-		Namespace:  fmt.Sprintf("ns-%d", userId.(int64)),
-		Pod:        appParam.PodName,
-		Deployment: appParam.Deployment,
+	stream, err := s.backend.Logs(KubernetesParamOf(application), &corev1.PodLogOptions{})
+	if err != nil {
+		return "", err
 	}
+	defer stream.Close()
 
-	logs, err := util.NewKubernetesUtil(s.ctx).GetPodLog(kbParam)
+	logs, err := io.ReadAll(stream)
 	if err != nil {
 		return "", err
 	}
+	return string(logs), nil
+}
+
+// StreamLogOfApp 按参数打开应用容器的日志流，默认读取 code-server 容器
+func (s *AppService) StreamLogOfApp(param *model.LogStreamParam) (io.ReadCloser, error) {
+	application, err := s.ResolveApp(0, param.Deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	if param.Container == "" {
+		param.Container = model.ContainerCodeServer
+	}
+	if param.Container != model.ContainerCodeServer && param.Container != model.ContainerHeartbeater {
+		return nil, fmt.Errorf("不支持的容器: %s", param.Container)
+	}
+	if param.TailLines < 0 || param.SinceSeconds < 0 {
+		return nil, errors.New("tail_lines 和 since_seconds 不能为负数")
+	}
+
+	options := &corev1.PodLogOptions{
+		Container:  param.Container,
+		Follow:     param.Follow,
+		Timestamps: param.Timestamps,
+		Previous:   param.Previous,
+	}
+	if param.TailLines > 0 {
+		options.TailLines = &param.TailLines
+	}
+	if param.SinceSeconds > 0 {
+		options.SinceSeconds = &param.SinceSeconds
+	}
 
-	return logs, nil
+	return s.backend.Logs(KubernetesParamOf(application), options)
 }
 
 func (s *AppService) GetUsageOfApp() (int64, error) {
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/testutil"
+	"learn/biz/util"
+)
+
+// waitFor 轮询直到 condition 成立，后台协程执行的操作用它等待结果
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// stateOf 通过 ListApp 查询应用当前的状态
+func stateOf(t *testing.T, s *AppService, deployment string) string {
+	t.Helper()
+	result, err := s.ListApp(&model.AppListParam{})
+	if err != nil {
+		t.Fatalf("查询应用列表失败: %v", err)
+	}
+	for _, application := range result.Items {
+		if application.Deployment == deployment {
+			return application.State
+		}
+	}
+	return ""
+}
+
+// createAndWait 创建工作空间并等待后台创建流程结束
+func createAndWait(t *testing.T, s *AppService, appParam *model.AppParam) string {
+	t.Helper()
+	deployment, err := s.CreateApp(appParam)
+	if err != nil {
+		t.Fatalf("创建应用失败: %v", err)
+	}
+	if record := waitProvision(t, s, deployment); record.State != model.ProvisionReady {
+		t.Fatalf("创建失败: %s", record.Error)
+	}
+	return deployment
+}
+
+// waitProvision 等待后台创建流程结束，返回最终的创建记录
+func waitProvision(t *testing.T, s *AppService, deployment string) *model.Provision {
+	t.Helper()
+	provisionService := NewProvisionService(context.Background(), s.c)
+	var record *model.Provision
+	var err error
+	waitFor(t, "创建完成", func() bool {
+		record, err = provisionService.GetProvisionStatus(deployment)
+		return err == nil && (record.State == model.ProvisionReady || record.State == model.ProvisionFailed)
+	})
+	return record
+}
+
+func TestAppLifecycleOnMemoryBackend(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	testutil.CreateUser(t, 1)
+	backend := util.NewMemoryBackend()
+	s := NewAppServiceWithBackend(context.Background(), testutil.NewContext(1), backend)
+
+	deployment := createAndWait(t, s, &model.AppParam{Application: model.Application{Name: "demo", Cpu: "1", Memory: "2Gi"}})
+	application, err := s.ResolveApp(0, deployment)
+	if err != nil {
+		t.Fatalf("创建完成后没有应用记录: %v", err)
+	}
+	if application.Url == "" {
+		t.Fatal("创建完成后应当有访问地址")
+	}
+	if state := stateOf(t, s, deployment); state != "running" {
+		t.Fatalf("创建后的状态为 %q", state)
+	}
+
+	if err := s.StopApp(&model.AppParam{Application: model.Application{Deployment: deployment}}); err != nil {
+		t.Fatalf("停止应用失败: %v", err)
+	}
+	waitFor(t, "停止", func() bool { return stateOf(t, s, deployment) == "stopped" })
+
+	if err := s.RestartApp(&model.AppParam{Application: model.Application{Deployment: deployment}}); err != nil {
+		t.Fatalf("启动应用失败: %v", err)
+	}
+	waitFor(t, "启动", func() bool { return stateOf(t, s, deployment) == "running" })
+
+	if err := s.DeleteApp(&model.AppParam{Application: model.Application{Deployment: deployment}}); err != nil {
+		t.Fatalf("删除应用失败: %v", err)
+	}
+	if _, err := backend.Status(KubernetesParamOf(application)); err == nil {
+		t.Fatal("删除后工作空间应当不存在")
+	}
+	if _, err := s.ResolveApp(0, deployment); !errors.Is(err, ErrAppNotFound) {
+		t.Fatalf("删除后查询应用的错误为 %v", err)
+	}
+}
+
+func TestCreateAppChecksWorkspaceLimit(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	testutil.CreateUser(t, 1)
+	maxWorkspaces := 1
+	setUserLimit(t, &model.UserLimit{UserId: 1, MaxWorkspaces: &maxWorkspaces})
+	s := NewAppServiceWithBackend(context.Background(), testutil.NewContext(1), util.NewMemoryBackend())
+
+	createAndWait(t, s, &model.AppParam{Application: model.Application{Name: "first", Cpu: "1", Memory: "2Gi"}})
+	if _, err := s.CreateApp(&model.AppParam{Application: model.Application{Name: "second", Cpu: "1", Memory: "2Gi"}}); err == nil {
+		t.Fatal("超出工作空间数量上限时创建应当失败")
+	}
+
+	var count int64
+	config.DB.Model(&model.Application{}).Where("user_id = ?", 1).Count(&count)
+	if count != 1 {
+		t.Fatalf("应用数量为 %d", count)
+	}
+}
+
+func TestRestartAppChecksRunningLimit(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	testutil.CreateUser(t, 1)
+	s := NewAppServiceWithBackend(context.Background(), testutil.NewContext(1), util.NewMemoryBackend())
+
+	first := createAndWait(t, s, &model.AppParam{Application: model.Application{Name: "first", Cpu: "1", Memory: "2Gi"}})
+	second := createAndWait(t, s, &model.AppParam{Application: model.Application{Name: "second", Cpu: "1", Memory: "2Gi"}})
+	if err := s.StopApp(&model.AppParam{Application: model.Application{Deployment: second}}); err != nil {
+		t.Fatalf("停止应用失败: %v", err)
+	}
+	waitFor(t, "停止", func() bool { return stateOf(t, s, second) == "stopped" })
+
+	maxRunning := 1
+	setUserLimit(t, &model.UserLimit{UserId: 1, MaxRunning: &maxRunning})
+	if err := s.RestartApp(&model.AppParam{Application: model.Application{Deployment: second}}); err == nil {
+		t.Fatal("超出运行数量上限时启动应当失败")
+	}
+	if state := stateOf(t, s, first); state != "running" {
+		t.Fatalf("其他工作空间的状态为 %q", state)
+	}
+}
+
+// failingWorkloadBackend 创建 Deployment 时失败，并记录回滚了哪些资源
+type failingWorkloadBackend struct {
+	*util.MemoryBackend
+	mu     sync.Mutex
+	undone []string
+}
+
+func (b *failingWorkloadBackend) WithContext(ctx context.Context) util.WorkspaceBackend {
+	return b
+}
+
+func (b *failingWorkloadBackend) CreateWorkload(*model.KubernetesParam, *model.AppParam, *model.WorkspaceTemplate) error {
+	return errors.New("节点资源不足")
+}
+
+func (b *failingWorkloadBackend) DeleteSecret(kbParam *model.KubernetesParam) error {
+	b.record("secret")
+	return b.MemoryBackend.DeleteSecret(kbParam)
+}
+
+func (b *failingWorkloadBackend) DeleteVolume(kbParam *model.KubernetesParam) error {
+	b.record("pvc")
+	return b.MemoryBackend.DeleteVolume(kbParam)
+}
+
+func (b *failingWorkloadBackend) record(resource string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.undone = append(b.undone, resource)
+}
+
+func TestCreateAppRollsBackEachStep(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	testutil.CreateUser(t, 1)
+	backend := &failingWorkloadBackend{MemoryBackend: util.NewMemoryBackend()}
+	s := NewAppServiceWithBackend(context.Background(), testutil.NewContext(1), backend)
+
+	deployment, err := s.CreateApp(&model.AppParam{Application: model.Application{Name: "demo", Cpu: "1", Memory: "2Gi"}})
+	if err != nil {
+		t.Fatalf("提交创建失败: %v", err)
+	}
+
+	provisionService := NewProvisionService(context.Background(), s.c)
+	var record *model.Provision
+	waitFor(t, "创建结束", func() bool {
+		record, err = provisionService.GetProvisionStatus(deployment)
+		return err == nil && record.State == model.ProvisionFailed
+	})
+	if record.Step != "create_deployment" || !record.RolledBack {
+		t.Fatalf("失败的步骤为 %q，已回滚 %v", record.Step, record.RolledBack)
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if len(backend.undone) != 2 || backend.undone[0] != "pvc" || backend.undone[1] != "secret" {
+		t.Fatalf("回滚顺序为 %v", backend.undone)
+	}
+}
+
+func TestStartWorkspaceDeletesHelperPods(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	application := testutil.CreateApp(t, 1, "deployment-abcd1234")
+
+	// 停止期间浏览文件留下的辅助 Pod 仍挂载着数据卷
+	helper := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "helper-abcd1234-00000",
+		Namespace: "ns-1",
+		Labels:    map[string]string{"app": "workspace-helper", "deployment": application.Deployment},
+	}}
+	client := fake.NewSimpleClientset(append(newWorkspaceObjects(application, 0), helper)...)
+	backend := util.NewKubernetesBackend(context.Background(), client)
+
+	if err := startWorkspace(context.Background(), backend, application); err != nil {
+		t.Fatalf("启动工作空间失败: %v", err)
+	}
+	pods, err := client.CoreV1().Pods("ns-1").List(context.Background(), metav1.ListOptions{LabelSelector: "app=workspace-helper"})
+	if err != nil {
+		t.Fatalf("查询辅助 Pod 失败: %v", err)
+	}
+	if len(pods.Items) != 0 {
+		t.Fatalf("启动后仍有 %d 个辅助 Pod", len(pods.Items))
+	}
+	if _, replicas := claimOf(t, client, application); replicas != 1 {
+		t.Fatalf("工作空间副本数为 %d", replicas)
+	}
+}
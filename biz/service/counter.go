@@ -8,6 +8,7 @@ import (
 	"learn/biz/model"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/go-redis/redis/v8" // 添加这行
 )
@@ -90,3 +91,45 @@ func (service *CounterService) CountTime(podUsage model.PodUsageRecord) error {
 
 	return nil
 }
+
+// activityKey 记录工作空间最后一次 code-server 活动时间的 Redis 键
+func activityKey(namespace, deployment string) string {
+	return "app_activity:" + namespace + ":" + deployment
+}
+
+// RecordActivity 记录工作空间的最后活动时间，只会向后推进
+func (service *CounterService) RecordActivity(namespace, deployment string, lastActivity time.Time) {
+	key := activityKey(namespace, deployment)
+
+	last, err := service.LastActivity(namespace, deployment)
+	if err != nil {
+		log.Printf("读取活动时间失败 - Key: %s, Error: %v", key, err)
+		return
+	}
+	if !last.IsZero() && !lastActivity.After(last) {
+		return
+	}
+
+	err = config.RedisClient.Set(service.ctx, key, lastActivity.Unix(), 7*24*time.Hour).Err()
+	if err != nil {
+		log.Printf("保存活动时间失败 - Key: %s, Error: %v", key, err)
+	}
+}
+
+// LastActivity 返回工作空间的最后活动时间，没有记录时返回零值
+func (service *CounterService) LastActivity(namespace, deployment string) (time.Time, error) {
+	result, err := config.RedisClient.Get(service.ctx, activityKey(namespace, deployment)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(result, 0), nil
+}
+
+func (service *CounterService) ClearActivity(namespace, deployment string) {
+	if err := config.RedisClient.Del(service.ctx, activityKey(namespace, deployment)).Err(); err != nil {
+		log.Printf("清除活动时间失败 - Namespace: %s, Deployment: %s, Error: %v", namespace, deployment, err)
+	}
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+type FileService struct {
+	ctx context.Context
+	c   *app.RequestContext
+}
+
+func NewFileService(ctx context.Context, c *app.RequestContext) *FileService {
+	return &FileService{ctx: ctx, c: c}
+}
+
+// fileTarget 执行文件操作的容器：工作空间运行时是 code-server 容器，停止时是挂载同一 PVC 的辅助 Pod
+type fileTarget struct {
+	kubernetesUtil *util.KubernetesUtil
+	namespace      string
+	pod            string
+	container      string
+	root           string
+	release        func()
+}
+
+func (s *FileService) ListFiles(param *model.FileParam) ([]*model.FileInfo, error) {
+	target, err := s.openTarget(param.Deployment)
+	if err != nil {
+		return nil, err
+	}
+	defer target.release()
+
+	dir := target.resolve(param.Path)
+	var stdout bytes.Buffer
+	script := `[ -d "$1" ] || { echo "目录不存在" >&2; exit 1; }
+find "$1" -mindepth 1 -maxdepth 1 -exec stat -c '%F|%s|%Y|%n' {} +`
+	if err := target.run(nil, &stdout, script, dir); err != nil {
+		return nil, err
+	}
+
+	files := make([]*model.FileInfo, 0)
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "|", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		modTime, _ := strconv.ParseInt(fields[2], 10, 64)
+		files = append(files, &model.FileInfo{
+			Name:    path.Base(fields[3]),
+			Path:    target.relative(fields[3]),
+			Type:    fileTypeOf(fields[0]),
+			Size:    size,
+			ModTime: time.Unix(modTime, 0),
+		})
+	}
+	return files, nil
+}
+
+// DownloadFile 返回文件内容流，目录会打包成 .tar.gz，调用方读完后需关闭
+func (s *FileService) DownloadFile(param *model.FileParam) (io.ReadCloser, string, error) {
+	target, err := s.openTarget(param.Deployment)
+	if err != nil {
+		return nil, "", err
+	}
+
+	filePath := target.resolve(param.Path)
+	var stdout bytes.Buffer
+	script := `if [ -d "$1" ]; then echo dir; elif [ -f "$1" ]; then echo file; else echo "文件不存在" >&2; exit 1; fi`
+	if err := target.run(nil, &stdout, script, filePath); err != nil {
+		target.release()
+		return nil, "", err
+	}
+
+	name := path.Base(filePath)
+	if filePath == target.root {
+		name = param.Deployment
+	}
+	args := []string{filePath}
+	script = `cat "$1"`
+	if strings.TrimSpace(stdout.String()) == model.FileTypeDir {
+		args = []string{path.Dir(filePath), path.Base(filePath)}
+		script = `tar -czf - -C "$1" "$2"`
+		name += ".tar.gz"
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		defer target.release()
+		writer.CloseWithError(target.run(nil, writer, script, args...))
+	}()
+	return reader, name, nil
+}
+
+// UploadFile 把上传的文件写入 param.Path 目录，目录不存在时自动创建
+func (s *FileService) UploadFile(param *model.FileParam, header *multipart.FileHeader) error {
+	if header.Size > util.MaxUploadSize() {
+		return fmt.Errorf("文件大小超过限制 %d MB", util.MaxUploadSize()>>20)
+	}
+	name := path.Base(header.Filename)
+	if name == "." || name == "/" || name == ".." {
+		return errors.New("文件名错误")
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	target, err := s.openTarget(param.Deployment)
+	if err != nil {
+		return err
+	}
+	defer target.release()
+
+	// exec 以 root 身份运行，写入后把文件和新建的各级目录的属主改成数据目录的属主，code-server 才能继续编辑
+	// top 为最上层不存在的目录，mkdir -p 创建的目录都在它下面
+	script := `owner=$(stat -c %u:%g "$3") && top= && d="$1" && while [ ! -e "$d" ]; do top="$d"; d=$(dirname "$d"); done && ` +
+		`mkdir -p "$1" && cat > "$1/$2" && chown "$owner" "$1/$2" && if [ -n "$top" ]; then chown -R "$owner" "$top"; fi`
+	return target.run(file, nil, script, target.resolve(param.Path), name, target.root)
+}
+
+func (s *FileService) DeleteFile(param *model.FileParam) error {
+	target, err := s.openTarget(param.Deployment)
+	if err != nil {
+		return err
+	}
+	defer target.release()
+
+	filePath := target.resolve(param.Path)
+	if filePath == target.root {
+		return errors.New("不能删除数据根目录")
+	}
+
+	script := `[ -e "$1" ] || [ -L "$1" ] || { echo "文件不存在" >&2; exit 1; }
+rm -rf -- "$1"`
+	return target.run(nil, nil, script, filePath)
+}
+
+// openTarget 校验应用归属并找到执行文件操作的容器，工作空间停止时会临时创建辅助 Pod
+// 文件操作可能在请求返回后继续（下载流），因此使用独立的 context
+func (s *FileService) openTarget(deployment string) (*fileTarget, error) {
+	application, err := NewAppService(s.ctx, s.c).ResolveApp(0, deployment)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireCluster(); err != nil {
+		return nil, err
+	}
+	kbParam := KubernetesParamOf(application)
+	kubernetesUtil := util.NewKubernetesUtil(context.Background())
+	deploy, err := kubernetesUtil.GetDeployment(kbParam)
+	if err != nil {
+		return nil, err
+	}
+	// 数据目录以工作空间实际挂载的路径为准，模板的挂载路径之后可能被修改
+	root, err := util.DataMountPath(deploy)
+	if err != nil {
+		return nil, err
+	}
+	target := &fileTarget{
+		kubernetesUtil: kubernetesUtil,
+		namespace:      kbParam.Namespace,
+		root:           root,
+		release:        func() {},
+	}
+
+	if pod, err := kubernetesUtil.GetPodInfo(kbParam); err == nil && pod.Status.Phase == corev1.PodRunning {
+		target.pod = pod.Name
+		target.container = model.ContainerCodeServer
+		return target, nil
+	}
+
+	if deploy.Spec.Replicas != nil && *deploy.Spec.Replicas > 0 {
+		return nil, errors.New("工作空间正在启动，请稍后再试")
+	}
+
+	name, err := kubernetesUtil.CreateHelperPod(kbParam, target.root)
+	if err != nil {
+		return nil, err
+	}
+	release := func() {
+		if err := kubernetesUtil.DeletePod(kbParam.Namespace, name); err != nil {
+			log.Printf("删除辅助 Pod 失败 - Pod: %s, Error: %v", name, err)
+		}
+	}
+	if err := kubernetesUtil.WaitPodRunning(kbParam.Namespace, name, 2*time.Minute); err != nil {
+		release()
+		return nil, fmt.Errorf("等待辅助 Pod 启动失败: %w", err)
+	}
+
+	target.pod = name
+	target.container = util.HelperContainer
+	target.release = release
+	return target, nil
+}
+
+// run 以 sh -c 执行脚本，参数通过 $1、$2... 传入以避免拼接路径；失败时返回脚本的错误输出
+func (t *fileTarget) run(stdin io.Reader, stdout io.Writer, script string, args ...string) error {
+	var stderr bytes.Buffer
+	command := append([]string{"sh", "-c", script, "sh"}, args...)
+	err := t.kubernetesUtil.ExecInNamedPod(t.namespace, t.pod, t.container, command, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return errors.New(message)
+		}
+		return err
+	}
+	return nil
+}
+
+// resolve 把用户传入的相对路径限制在数据目录之内
+func (t *fileTarget) resolve(filePath string) string {
+	return path.Join(t.root, path.Clean("/"+filePath))
+}
+
+func (t *fileTarget) relative(filePath string) string {
+	return path.Clean("/" + strings.TrimPrefix(filePath, t.root))
+}
+
+func fileTypeOf(statType string) string {
+	switch statType {
+	case "directory":
+		return model.FileTypeDir
+	case "symbolic link":
+		return model.FileTypeLink
+	default:
+		return model.FileTypeFile
+	}
+}
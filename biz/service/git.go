@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+const maxGitRepos = 10
+
+// validateRepos 校验并规范化要克隆的仓库，凭据 Secret 必须已存在于用户命名空间中且包含 username、password
+func validateRepos(kubernetesUtil *util.KubernetesUtil, namespace string, repos []model.GitRepo) error {
+	if len(repos) > maxGitRepos {
+		return fmt.Errorf("最多只能克隆 %d 个仓库", maxGitRepos)
+	}
+
+	directories := make(map[string]bool, len(repos))
+	for i := range repos {
+		repo := &repos[i]
+		repo.Url = strings.TrimSpace(repo.Url)
+		if !strings.HasPrefix(repo.Url, "https://") && !strings.HasPrefix(repo.Url, "http://") {
+			return fmt.Errorf("仓库地址只支持 http(s): %s", repo.Url)
+		}
+
+		if repo.Directory == "" {
+			repo.Directory = strings.TrimSuffix(path.Base(repo.Url), ".git")
+		}
+		repo.Directory = strings.TrimPrefix(path.Clean("/"+repo.Directory), "/")
+		if repo.Directory == "" {
+			return fmt.Errorf("仓库 %s 的目标目录错误", repo.Url)
+		}
+		if directories[repo.Directory] {
+			return fmt.Errorf("目标目录重复: %s", repo.Directory)
+		}
+		directories[repo.Directory] = true
+
+		if repo.CredentialSecret == "" {
+			continue
+		}
+		if err := requireCluster(); err != nil {
+			return err
+		}
+		secret, err := kubernetesUtil.GetSecret(namespace, repo.CredentialSecret)
+		if err != nil {
+			return err
+		}
+		if _, ok := secret.Data["username"]; !ok {
+			return errors.New("凭据 Secret 中缺少 username")
+		}
+		if _, ok := secret.Data["password"]; !ok {
+			return errors.New("凭据 Secret 中缺少 password")
+		}
+	}
+	return nil
+}
+
+// GitCredentialService 管理用户命名空间中的仓库凭据 Secret，创建工作空间时通过 credential_secret 引用
+type GitCredentialService struct {
+	ctx            context.Context
+	c              *app.RequestContext
+	backend        util.WorkspaceBackend
+	kubernetesUtil *util.KubernetesUtil
+}
+
+func NewGitCredentialService(ctx context.Context, c *app.RequestContext) *GitCredentialService {
+	return &GitCredentialService{ctx: ctx, c: c, backend: util.NewWorkspaceBackend(ctx), kubernetesUtil: util.NewKubernetesUtil(ctx)}
+}
+
+// NewGitCredentialServiceWithClient 使用指定的 Kubernetes 客户端，测试时可传入 fake 客户端
+func NewGitCredentialServiceWithClient(ctx context.Context, c *app.RequestContext, client kubernetes.Interface) *GitCredentialService {
+	return &GitCredentialService{
+		ctx:            ctx,
+		c:              c,
+		backend:        util.NewKubernetesBackend(ctx, client),
+		kubernetesUtil: util.NewKubernetesUtilWithClient(ctx, client),
+	}
+}
+
+// CreateGitCredential 创建或更新仓库凭据，用户还没有工作空间时先创建命名空间
+func (s *GitCredentialService) CreateGitCredential(credential *model.GitCredential) error {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return errors.New("没有找到用户ID")
+	}
+	if err := requireCluster(); err != nil {
+		return err
+	}
+
+	credential.Name = strings.TrimSpace(credential.Name)
+	if errs := validation.IsDNS1123Subdomain(credential.Name); len(errs) > 0 {
+		return fmt.Errorf("凭据名称格式错误: %s", strings.Join(errs, "; "))
+	}
+	if credential.Username == "" || credential.Password == "" {
+		return errors.New("用户名和密码不能为空")
+	}
+
+	if err := syncQuota(s.ctx, s.backend, uint(userId.(int64))); err != nil {
+		return err
+	}
+	return s.kubernetesUtil.ApplyGitCredential(fmt.Sprintf("ns-%d", userId.(int64)), credential)
+}
+
+// ListGitCredential 列出当前用户的仓库凭据，不返回密码
+func (s *GitCredentialService) ListGitCredential() ([]*model.GitCredential, error) {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return nil, errors.New("没有找到用户ID")
+	}
+	if err := requireCluster(); err != nil {
+		return nil, err
+	}
+	return s.kubernetesUtil.ListGitCredentials(fmt.Sprintf("ns-%d", userId.(int64)))
+}
+
+func (s *GitCredentialService) DeleteGitCredential(name string) error {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return errors.New("没有找到用户ID")
+	}
+	if err := requireCluster(); err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("凭据名称不能为空")
+	}
+	return s.kubernetesUtil.DeleteGitCredential(fmt.Sprintf("ns-%d", userId.(int64)), name)
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"learn/biz/model"
+	"learn/biz/testutil"
+)
+
+func TestGitCredentialLifecycle(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.CreateUser(t, 1)
+	// 工作空间的密码 Secret 与仓库凭据在同一命名空间，不能被列出、覆盖或删除
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-abcd1234", Namespace: "ns-1"},
+		Data:       map[string][]byte{"password": []byte("workspace")},
+	})
+	credentials := NewGitCredentialServiceWithClient(context.Background(), testutil.NewContext(1), client)
+
+	err := credentials.CreateGitCredential(&model.GitCredential{Name: "github", Username: "alice", Password: "token"})
+	if err != nil {
+		t.Fatalf("创建凭据失败: %v", err)
+	}
+	if _, err := client.CoreV1().Namespaces().Get(context.Background(), "ns-1", metav1.GetOptions{}); err != nil {
+		t.Fatalf("没有创建用户命名空间: %v", err)
+	}
+	secret, err := client.CoreV1().Secrets("ns-1").Get(context.Background(), "github", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("没有创建 Secret: %v", err)
+	}
+	if secret.StringData["username"] != "alice" || secret.StringData["password"] != "token" {
+		t.Fatalf("Secret 内容为 %v", secret.StringData)
+	}
+
+	if err := credentials.CreateGitCredential(&model.GitCredential{Name: "Bad_Name", Username: "a", Password: "b"}); err == nil {
+		t.Fatal("非法的名称应当被拒绝")
+	}
+	if err := credentials.CreateGitCredential(&model.GitCredential{Name: "secret-abcd1234", Username: "a", Password: "b"}); err == nil {
+		t.Fatal("不应覆盖工作空间的密码 Secret")
+	}
+
+	list, err := credentials.ListGitCredential()
+	if err != nil {
+		t.Fatalf("列出凭据失败: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "github" || list[0].Password != "" {
+		t.Fatalf("凭据列表为 %+v", list)
+	}
+
+	if err := credentials.DeleteGitCredential("secret-abcd1234"); err == nil {
+		t.Fatal("不应删除工作空间的密码 Secret")
+	}
+	if err := credentials.DeleteGitCredential("github"); err != nil {
+		t.Fatalf("删除凭据失败: %v", err)
+	}
+	if list, _ := credentials.ListGitCredential(); len(list) != 0 {
+		t.Fatalf("删除后仍有 %d 个凭据", len(list))
+	}
+}
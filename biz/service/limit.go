@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+// workspaceUsage 用户的工作空间用量，也用于表示一次操作将增加的用量
+type workspaceUsage struct {
+	workspaces, running  int
+	cpu, memory, storage resource.Quantity
+}
+
+// LimitsOf 返回用户的套餐和实际生效的限额，用户有单独设置的项时覆盖套餐的值
+func LimitsOf(ctx context.Context, userId uint) (*model.Plan, *model.WorkspaceLimits, error) {
+	plan, err := PlanOf(ctx, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	limits := &model.WorkspaceLimits{
+		MaxWorkspaces: plan.MaxWorkspaces,
+		MaxRunning:    plan.MaxRunning,
+		Cpu:           plan.TotalCpu,
+		Memory:        plan.TotalMemory,
+		Storage:       plan.TotalStorage,
+	}
+
+	var override model.UserLimit
+	err = config.DB.WithContext(ctx).Where("user_id = ?", userId).First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return plan, limits, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询用户限额失败: %w", err)
+	}
+
+	if override.MaxWorkspaces != nil {
+		limits.MaxWorkspaces = *override.MaxWorkspaces
+	}
+	if override.MaxRunning != nil {
+		limits.MaxRunning = *override.MaxRunning
+	}
+	if override.TotalCpu != "" {
+		limits.Cpu = override.TotalCpu
+	}
+	if override.TotalMemory != "" {
+		limits.Memory = override.TotalMemory
+	}
+	if override.TotalStorage != "" {
+		limits.Storage = override.TotalStorage
+	}
+	return plan, limits, nil
+}
+
+// withUserLock 在事务中锁住用户记录后执行 fn，同一用户的准入检查与占用用量的操作（写入创建记录、启动、调整规格）串行执行，
+// 避免并发的请求在彼此生效前都通过检查。fn 中的写入不在该事务中，锁在 fn 返回后才释放，此时写入已经提交
+func withUserLock(ctx context.Context, userId uint, fn func() error) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userId).Error
+		if err != nil {
+			return fmt.Errorf("查询用户失败: %w", err)
+		}
+		return fn()
+	})
+}
+
+// usageOf 统计用户当前的用量，副本数大于 0 的工作空间算作运行中
+// 创建中的工作空间还没有应用记录，按运行中计入数量，避免连续提交创建请求绕过限制
+func usageOf(ctx context.Context, backend util.WorkspaceBackend, userId uint) (*workspaceUsage, error) {
+	backend = util.Uncached(backend)
+
+	var applications []*model.Application
+	if err := config.DB.WithContext(ctx).Where("user_id = ?", userId).Find(&applications).Error; err != nil {
+		return nil, err
+	}
+
+	usage := &workspaceUsage{}
+	deployments := make(map[string]bool, len(applications))
+	for _, application := range applications {
+		deployments[application.Deployment] = true
+		usage.workspaces++
+		addQuantity(&usage.storage, util.StorageOf(&model.AppParam{Application: *application}))
+
+		status, err := backend.Status(KubernetesParamOf(application))
+		if err != nil || status.Replicas == 0 {
+			continue
+		}
+		usage.running++
+		addQuantity(&usage.cpu, application.Cpu)
+		addQuantity(&usage.memory, application.Memory)
+	}
+
+	var provisions []*model.Provision
+	err := config.DB.WithContext(ctx).
+		Where("user_id = ? AND state NOT IN ?", userId, []string{model.ProvisionReady, model.ProvisionFailed}).
+		Find(&provisions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, provision := range provisions {
+		if !deployments[provision.Deployment] {
+			usage.workspaces++
+			usage.running++
+		}
+	}
+	return usage, nil
+}
+
+// checkLimits 检查在当前用量上再增加 added 后是否超出限额，added 中为 0 的项不检查
+func checkLimits(limits *model.WorkspaceLimits, used, added *workspaceUsage) error {
+	if limits.MaxWorkspaces > 0 && added.workspaces > 0 && used.workspaces+added.workspaces > limits.MaxWorkspaces {
+		return fmt.Errorf("最多创建 %d 个工作空间，当前已有 %d 个", limits.MaxWorkspaces, used.workspaces)
+	}
+	if limits.MaxRunning > 0 && added.running > 0 && used.running+added.running > limits.MaxRunning {
+		return fmt.Errorf("最多同时运行 %d 个工作空间，当前已运行 %d 个，请先停止其他工作空间", limits.MaxRunning, used.running)
+	}
+
+	totals := []struct {
+		label, limit string
+		used, added  resource.Quantity
+	}{
+		{"运行中工作空间的 CPU 总量", limits.Cpu, used.cpu, added.cpu},
+		{"运行中工作空间的内存总量", limits.Memory, used.memory, added.memory},
+		{"数据卷的存储总量", limits.Storage, used.storage, added.storage},
+	}
+	for _, total := range totals {
+		if total.limit == "" || total.added.IsZero() {
+			continue
+		}
+		limit, err := resource.ParseQuantity(total.limit)
+		if err != nil {
+			return fmt.Errorf("%s上限格式错误: %s", total.label, total.limit)
+		}
+		sum := total.used.DeepCopy()
+		sum.Add(total.added)
+		if sum.Cmp(limit) > 0 {
+			return fmt.Errorf("%s将达到 %s，超出上限 %s", total.label, sum.String(), limit.String())
+		}
+	}
+	return nil
+}
+
+// admitStart 检查启动一个已停止的工作空间是否会超出运行数量和 CPU、内存总量的限额，运行中的工作空间直接放行
+func admitStart(ctx context.Context, backend util.WorkspaceBackend, application *model.Application) error {
+	status, err := backend.Status(KubernetesParamOf(application))
+	if err == nil && status.Replicas > 0 {
+		return nil
+	}
+
+	_, limits, err := LimitsOf(ctx, application.UserId)
+	if err != nil {
+		return err
+	}
+	used, err := usageOf(ctx, backend, application.UserId)
+	if err != nil {
+		return err
+	}
+	added := &workspaceUsage{running: 1}
+	addQuantity(&added.cpu, application.Cpu)
+	addQuantity(&added.memory, application.Memory)
+	return checkLimits(limits, used, added)
+}
+
+// admitResize 检查把运行中的工作空间调整为新规格后，CPU、内存总量是否超出限额，只检查增加的部分
+func admitResize(ctx context.Context, backend util.WorkspaceBackend, application *model.Application, cpu, memory string) error {
+	_, limits, err := LimitsOf(ctx, application.UserId)
+	if err != nil {
+		return err
+	}
+	used, err := usageOf(ctx, backend, application.UserId)
+	if err != nil {
+		return err
+	}
+
+	added := &workspaceUsage{}
+	for _, spec := range []struct {
+		added    *resource.Quantity
+		from, to string
+	}{
+		{&added.cpu, application.Cpu, cpu},
+		{&added.memory, application.Memory, memory},
+	} {
+		addQuantity(spec.added, spec.to)
+		var from resource.Quantity
+		addQuantity(&from, spec.from)
+		spec.added.Sub(from)
+		if spec.added.Sign() < 0 {
+			*spec.added = resource.Quantity{}
+		}
+	}
+	return checkLimits(limits, used, added)
+}
+
+// GetQuota 返回当前用户的套餐、限额与用量
+func (s *AppService) GetQuota() (*model.QuotaStatus, error) {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return nil, errors.New("没有找到用户ID")
+	}
+
+	plan, limits, err := LimitsOf(s.ctx, uint(userId.(int64)))
+	if err != nil {
+		return nil, err
+	}
+	used, err := usageOf(s.ctx, s.backend, uint(userId.(int64)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.QuotaStatus{
+		Plan:   plan.Name,
+		Limits: *limits,
+		Usage: model.WorkspaceUsage{
+			Workspaces: used.workspaces,
+			Running:    used.running,
+			Cpu:        used.cpu.String(),
+			Memory:     used.memory.String(),
+			Storage:    used.storage.String(),
+		},
+	}, nil
+}
+
+// SetUserLimit 设置用户的限额覆盖项，已有设置时整体替换
+func (s *PlanService) SetUserLimit(param *model.UserLimit) error {
+	if param.UserId == 0 {
+		return errors.New("用户ID不能为空")
+	}
+	if (param.MaxWorkspaces != nil && *param.MaxWorkspaces < 0) || (param.MaxRunning != nil && *param.MaxRunning < 0) {
+		return errors.New("数量上限不能为负数")
+	}
+	for _, spec := range [][2]string{{"CPU", param.TotalCpu}, {"内存", param.TotalMemory}, {"存储", param.TotalStorage}} {
+		if spec[1] == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(spec[1]); err != nil {
+			return fmt.Errorf("%s 格式错误: %s", spec[0], spec[1])
+		}
+	}
+
+	var count int64
+	if err := config.DB.WithContext(s.ctx).Model(&model.User{}).Where("id = ?", param.UserId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("用户 %d 不存在", param.UserId)
+	}
+
+	var existing model.UserLimit
+	err := config.DB.WithContext(s.ctx).Where("user_id = ?", param.UserId).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		param.ID = 0
+		return config.DB.WithContext(s.ctx).Create(param).Error
+	}
+	if err != nil {
+		return err
+	}
+	return config.DB.WithContext(s.ctx).Model(&existing).
+		Select("max_workspaces", "max_running", "total_cpu", "total_memory", "total_storage").
+		Updates(param).Error
+}
+
+// DeleteUserLimit 删除用户的限额覆盖项，之后完全沿用套餐的限额
+func (s *PlanService) DeleteUserLimit(userId uint) error {
+	if userId == 0 {
+		return errors.New("用户ID不能为空")
+	}
+	return config.DB.WithContext(s.ctx).Unscoped().Where("user_id = ?", userId).Delete(&model.UserLimit{}).Error
+}
+
+// addQuantity 把 value 累加到 total，格式错误的值忽略
+func addQuantity(total *resource.Quantity, value string) {
+	if quantity, err := resource.ParseQuantity(value); err == nil {
+		total.Add(quantity)
+	}
+}
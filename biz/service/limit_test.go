@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/testutil"
+	"learn/biz/util"
+)
+
+func setUserLimit(t *testing.T, limit *model.UserLimit) {
+	t.Helper()
+	if err := NewPlanService(context.Background(), nil).SetUserLimit(limit); err != nil {
+		t.Fatalf("设置用户限额失败: %v", err)
+	}
+}
+
+func TestStartWorkspaceChecksRunningLimit(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	running := testutil.CreateApp(t, 1, "deployment-aaaa1111")
+	stopped := testutil.CreateApp(t, 1, "deployment-bbbb2222")
+	maxRunning := 1
+	setUserLimit(t, &model.UserLimit{UserId: 1, MaxRunning: &maxRunning})
+
+	objects := append(newWorkspaceObjects(running, 1), newWorkspaceObjects(stopped, 0)...)
+	client := fake.NewSimpleClientset(objects...)
+	backend := util.NewKubernetesBackend(context.Background(), client)
+
+	if err := startWorkspace(context.Background(), backend, stopped); err == nil {
+		t.Fatal("超出运行数量上限时启动应当失败")
+	}
+	if _, replicas := claimOf(t, client, stopped); replicas != 0 {
+		t.Fatalf("被拒绝的工作空间副本数为 %d", replicas)
+	}
+
+	if err := stopWorkspace(context.Background(), backend, KubernetesParamOf(running)); err != nil {
+		t.Fatalf("停止工作空间失败: %v", err)
+	}
+	if err := startWorkspace(context.Background(), backend, stopped); err != nil {
+		t.Fatalf("停止其他工作空间后启动失败: %v", err)
+	}
+	if _, replicas := claimOf(t, client, stopped); replicas != 1 {
+		t.Fatalf("工作空间副本数为 %d", replicas)
+	}
+}
+
+func TestAdmitResize(t *testing.T) {
+	testutil.SetupDB(t)
+	application := testutil.CreateApp(t, 1, "deployment-aaaa1111")
+	setUserLimit(t, &model.UserLimit{UserId: 1, TotalCpu: "2", TotalMemory: "4Gi"})
+	backend := util.NewKubernetesBackend(context.Background(), fake.NewSimpleClientset(newWorkspaceObjects(application, 1)...))
+
+	tests := []struct {
+		name        string
+		cpu, memory string
+		allowed     bool
+	}{
+		{"在限额内扩大", "2", "4Gi", true},
+		{"CPU 超出总量", "3", "2Gi", false},
+		{"内存超出总量", "1", "5Gi", false},
+		{"缩小不检查", "500m", "1Gi", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := admitResize(context.Background(), backend, application, tt.cpu, tt.memory)
+			if (err == nil) != tt.allowed {
+				t.Fatalf("allowed=%v, err=%v", tt.allowed, err)
+			}
+		})
+	}
+}
+
+func TestUsageCountsPendingProvisions(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.CreateUser(t, 1)
+	maxWorkspaces := 1
+	setUserLimit(t, &model.UserLimit{UserId: 1, MaxWorkspaces: &maxWorkspaces})
+	backend := util.NewKubernetesBackend(context.Background(), fake.NewSimpleClientset())
+
+	err := withUserLock(context.Background(), 1, func() error {
+		if err := admitWorkspace(context.Background(), backend, 1, 0, "1", "2Gi", "10Gi"); err != nil {
+			return err
+		}
+		_, err := NewProvisionService(context.Background(), nil).Start(&model.KubernetesParam{
+			Namespace:  "ns-1",
+			Deployment: "deployment-aaaa1111",
+		}, 1)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("第一次创建应当通过: %v", err)
+	}
+
+	// 第一次创建的记录写入后，即使应用记录还不存在，第二次创建也会被拒绝
+	if err := admitWorkspace(context.Background(), backend, 1, 0, "1", "2Gi", "10Gi"); err == nil {
+		t.Fatal("创建中的工作空间应计入数量")
+	}
+
+	var count int64
+	config.DB.Model(&model.Provision{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("创建记录数量为 %d", count)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+const (
+	// metricsSampleInterval 采样间隔，与定时任务的周期一致
+	metricsSampleInterval = 30 * time.Second
+	// metricsHistoryLength 保留最近一小时的采样
+	metricsHistoryLength = int64(time.Hour / metricsSampleInterval)
+)
+
+type MetricsService struct {
+	ctx            context.Context
+	c              *app.RequestContext
+	fetcher        util.MetricsFetcher
+	kubernetesUtil *util.KubernetesUtil
+}
+
+func NewMetricsService(ctx context.Context, c *app.RequestContext) *MetricsService {
+	return &MetricsService{ctx: ctx, c: c, fetcher: util.Metrics, kubernetesUtil: util.NewKubernetesUtil(ctx)}
+}
+
+// NewMetricsServiceWithClient 使用指定的 Kubernetes 客户端和用量查询实现，测试时可传入 fake 客户端和假的 MetricsFetcher
+func NewMetricsServiceWithClient(ctx context.Context, c *app.RequestContext, client kubernetes.Interface, fetcher util.MetricsFetcher) *MetricsService {
+	return &MetricsService{ctx: ctx, c: c, fetcher: fetcher, kubernetesUtil: util.NewKubernetesUtilWithClient(ctx, client)}
+}
+
+// GetMetricsOfApp 查询工作空间 Pod 各容器的当前用量和限制，以及最近一小时的采样记录
+func (s *MetricsService) GetMetricsOfApp(param *model.MetricsParam) (*model.WorkspaceMetrics, error) {
+	application, err := NewAppService(s.ctx, s.c).ResolveApp(0, param.Deployment)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireCluster(); err != nil {
+		return nil, err
+	}
+	kbParam := KubernetesParamOf(application)
+
+	pod, err := s.kubernetesUtil.GetCachedPod(kbParam)
+	if err != nil || pod.Status.Phase != corev1.PodRunning {
+		return nil, errors.New("应用未运行")
+	}
+	podMetrics, err := s.fetcher.GetPodMetrics(s.ctx, pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := &model.WorkspaceMetrics{
+		Deployment: application.Deployment,
+		Pod:        pod.Name,
+		Timestamp:  podMetrics.Timestamp.Time,
+	}
+	usages := make(map[string]corev1.ResourceList, len(podMetrics.Containers))
+	for _, container := range podMetrics.Containers {
+		usages[container.Name] = container.Usage
+	}
+	for _, container := range pod.Spec.Containers {
+		usage := usages[container.Name]
+		containerMetrics := model.ContainerMetrics{
+			Name:        container.Name,
+			CpuUsage:    usage.Cpu().MilliValue(),
+			CpuLimit:    container.Resources.Limits.Cpu().MilliValue(),
+			MemoryUsage: usage.Memory().Value(),
+			MemoryLimit: container.Resources.Limits.Memory().Value(),
+		}
+		metrics.Containers = append(metrics.Containers, containerMetrics)
+		metrics.CpuUsage += containerMetrics.CpuUsage
+		metrics.CpuLimit += containerMetrics.CpuLimit
+		metrics.MemoryUsage += containerMetrics.MemoryUsage
+		metrics.MemoryLimit += containerMetrics.MemoryLimit
+	}
+
+	metrics.History, err = s.history(kbParam.Namespace, kbParam.Deployment)
+	if err != nil {
+		log.Printf("读取用量历史失败 - Deployment: %s, Error: %v", kbParam.Deployment, err)
+		metrics.History = []model.MetricsSample{}
+	}
+	return metrics, nil
+}
+
+// RecordSamples 一次查询所有工作空间 Pod 的用量，追加到各自的历史记录中
+func (s *MetricsService) RecordSamples() error {
+	if err := requireCluster(); err != nil {
+		return err
+	}
+	if s.fetcher == nil {
+		return nil
+	}
+	podMetricsList, err := s.fetcher.ListPodMetrics(s.ctx, "app=code-server")
+	if err != nil {
+		return err
+	}
+
+	for i := range podMetricsList {
+		podMetrics := &podMetricsList[i]
+		deployment := podMetrics.Labels["deployment"]
+		if deployment == "" {
+			continue
+		}
+		sample := sampleOf(podMetrics)
+		data, err := json.Marshal(sample)
+		if err != nil {
+			continue
+		}
+
+		key := metricsHistoryKey(podMetrics.Namespace, deployment)
+		pipe := config.RedisClient.TxPipeline()
+		pipe.RPush(s.ctx, key, data)
+		pipe.LTrim(s.ctx, key, -metricsHistoryLength, -1)
+		pipe.Expire(s.ctx, key, 2*time.Hour)
+		if _, err := pipe.Exec(s.ctx); err != nil {
+			log.Printf("保存用量采样失败 - Key: %s, Error: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func (s *MetricsService) history(namespace, deployment string) ([]model.MetricsSample, error) {
+	values, err := config.RedisClient.LRange(s.ctx, metricsHistoryKey(namespace, deployment), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]model.MetricsSample, 0, len(values))
+	since := time.Now().Add(-time.Hour)
+	for _, value := range values {
+		var sample model.MetricsSample
+		if err := json.Unmarshal([]byte(value), &sample); err != nil {
+			continue
+		}
+		if sample.Timestamp.After(since) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+func sampleOf(podMetrics *metricsv1beta1.PodMetrics) model.MetricsSample {
+	sample := model.MetricsSample{Timestamp: podMetrics.Timestamp.Time}
+	for _, container := range podMetrics.Containers {
+		sample.Cpu += container.Usage.Cpu().MilliValue()
+		sample.Memory += container.Usage.Memory().Value()
+	}
+	return sample
+}
+
+func metricsHistoryKey(namespace, deployment string) string {
+	return fmt.Sprintf("metrics:%s:%s", namespace, deployment)
+}
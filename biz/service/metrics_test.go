@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/testutil"
+)
+
+// fakeMetricsFetcher 返回固定的 Pod 用量，list 为 nil 时 ListPodMetrics 返回 pod
+type fakeMetricsFetcher struct {
+	pod  *metricsv1beta1.PodMetrics
+	list []metricsv1beta1.PodMetrics
+}
+
+func (f *fakeMetricsFetcher) GetPodMetrics(ctx context.Context, namespace, name string) (*metricsv1beta1.PodMetrics, error) {
+	return f.pod, nil
+}
+
+func (f *fakeMetricsFetcher) ListPodMetrics(ctx context.Context, labelSelector string) ([]metricsv1beta1.PodMetrics, error) {
+	if f.list == nil {
+		return []metricsv1beta1.PodMetrics{*f.pod}, nil
+	}
+	return f.list, nil
+}
+
+func usageOfContainer(name, cpu, memory string) metricsv1beta1.ContainerMetrics {
+	return metricsv1beta1.ContainerMetrics{
+		Name: name,
+		Usage: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		},
+	}
+}
+
+func podMetricsOf(kbParam *model.KubernetesParam, timestamp time.Time, containers ...metricsv1beta1.ContainerMetrics) *metricsv1beta1.PodMetrics {
+	return &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kbParam.Pod,
+			Namespace: kbParam.Namespace,
+			Labels:    map[string]string{"app": "code-server", "deployment": kbParam.Deployment},
+		},
+		Timestamp:  metav1.NewTime(timestamp),
+		Containers: containers,
+	}
+}
+
+func TestGetMetricsOfAppSumsContainers(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	application := testutil.CreateApp(t, 1, "deployment-aaaa1111")
+	kbParam := KubernetesParamOf(application)
+
+	limits := func(cpu, memory string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}}
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kbParam.Pod,
+			Namespace: kbParam.Namespace,
+			Labels:    map[string]string{"app": "code-server", "deployment": kbParam.Deployment},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: model.ContainerCodeServer, Resources: limits("1", "2Gi")},
+			{Name: "heartbeat", Resources: limits("100m", "64Mi")},
+			// 没有设置限制的容器按 0 计入限制，用量照常累加
+			{Name: "unlimited"},
+		}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	fetcher := &fakeMetricsFetcher{pod: podMetricsOf(kbParam, time.Now(),
+		usageOfContainer(model.ContainerCodeServer, "250m", "1Gi"),
+		usageOfContainer("heartbeat", "5m", "16Mi"),
+		usageOfContainer("unlimited", "10m", "32Mi"),
+	)}
+	s := NewMetricsServiceWithClient(context.Background(), testutil.NewContext(1), fake.NewSimpleClientset(pod), fetcher)
+
+	metrics, err := s.GetMetricsOfApp(&model.MetricsParam{Deployment: application.Deployment})
+	if err != nil {
+		t.Fatalf("查询用量失败: %v", err)
+	}
+	if len(metrics.Containers) != 3 {
+		t.Fatalf("容器数量为 %d", len(metrics.Containers))
+	}
+	if metrics.CpuUsage != 265 || metrics.CpuLimit != 1100 {
+		t.Fatalf("CPU 用量 %d、限制 %d", metrics.CpuUsage, metrics.CpuLimit)
+	}
+	wantMemory := int64(1<<30 + 16<<20 + 32<<20)
+	wantMemoryLimit := int64(2<<30 + 64<<20)
+	if metrics.MemoryUsage != wantMemory || metrics.MemoryLimit != wantMemoryLimit {
+		t.Fatalf("内存用量 %d、限制 %d", metrics.MemoryUsage, metrics.MemoryLimit)
+	}
+	if metrics.History == nil || len(metrics.History) != 0 {
+		t.Fatalf("没有采样时历史应为空列表: %v", metrics.History)
+	}
+}
+
+func TestGetMetricsOfAppNotRunning(t *testing.T) {
+	testutil.SetupDB(t)
+	application := testutil.CreateApp(t, 1, "deployment-aaaa1111")
+	s := NewMetricsServiceWithClient(context.Background(), testutil.NewContext(1), fake.NewSimpleClientset(), &fakeMetricsFetcher{})
+
+	if _, err := s.GetMetricsOfApp(&model.MetricsParam{Deployment: application.Deployment}); err == nil {
+		t.Fatal("没有 Pod 时应当返回错误")
+	}
+}
+
+func TestRecordSamplesTrimsHistory(t *testing.T) {
+	testutil.SetupRedis(t)
+	kbParam := &model.KubernetesParam{Namespace: "ns-1", Deployment: "deployment-aaaa1111", Pod: "pod-aaaa1111"}
+	key := metricsHistoryKey(kbParam.Namespace, kbParam.Deployment)
+	ctx := context.Background()
+
+	// 超过一小时的采样在读取时被过滤
+	stale, _ := json.Marshal(model.MetricsSample{Timestamp: time.Now().Add(-2 * time.Hour), Cpu: 1})
+	config.RedisClient.RPush(ctx, key, stale)
+
+	fetcher := &fakeMetricsFetcher{}
+	s := NewMetricsServiceWithClient(ctx, nil, fake.NewSimpleClientset(), fetcher)
+	start := time.Now().Add(-30 * time.Minute)
+	total := int(metricsHistoryLength) + 5
+	for i := 0; i < total; i++ {
+		fetcher.pod = podMetricsOf(kbParam, start.Add(time.Duration(i)*time.Second),
+			usageOfContainer(model.ContainerCodeServer, "100m", "1Gi"),
+			usageOfContainer("heartbeat", "10m", "1Mi"),
+		)
+		if err := s.RecordSamples(); err != nil {
+			t.Fatalf("采样失败: %v", err)
+		}
+	}
+
+	length, err := config.RedisClient.LLen(ctx, key).Result()
+	if err != nil {
+		t.Fatalf("读取历史长度失败: %v", err)
+	}
+	if length != metricsHistoryLength {
+		t.Fatalf("历史长度为 %d，期望 %d", length, metricsHistoryLength)
+	}
+	if ttl := config.RedisClient.TTL(ctx, key).Val(); ttl <= 0 {
+		t.Fatalf("历史记录没有设置过期时间: %v", ttl)
+	}
+
+	samples, err := s.history(kbParam.Namespace, kbParam.Deployment)
+	if err != nil {
+		t.Fatalf("读取历史失败: %v", err)
+	}
+	if int64(len(samples)) != metricsHistoryLength {
+		t.Fatalf("历史采样数为 %d", len(samples))
+	}
+	// 保留的是最新的采样，最早的几次已被裁掉
+	if first := samples[0].Timestamp; !first.Equal(start.Add(5 * time.Second)) {
+		t.Fatalf("最早的采样时间为 %v", first)
+	}
+	if samples[0].Cpu != 110 || samples[0].Memory != 1<<30+1<<20 {
+		t.Fatalf("采样值为 %+v", samples[0])
+	}
+
+	// 被 LTRIM 保留下来但已过期的采样同样不返回
+	config.RedisClient.RPush(ctx, key, stale)
+	if samples, _ := s.history(kbParam.Namespace, kbParam.Deployment); int64(len(samples)) != metricsHistoryLength {
+		t.Fatalf("过期的采样不应返回，采样数为 %d", len(samples))
+	}
+}
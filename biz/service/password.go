@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+const (
+	generatedPasswordLength = 20
+	minPasswordLength       = 8
+)
+
+// preparePassword 校验用户指定的工作空间密码，未指定时生成随机密码
+func preparePassword(appParam *model.AppParam) error {
+	if appParam.PodPassword == "" {
+		password, err := util.GeneratePassword(generatedPasswordLength)
+		if err != nil {
+			return err
+		}
+		appParam.PodPassword = password
+		return nil
+	}
+	if len(appParam.PodPassword) < minPasswordLength {
+		return errors.New("密码长度不能少于8位")
+	}
+	return nil
+}
+
+// GetPassword 查询工作空间当前的登录密码
+func (s *AppService) GetPassword(appParam *model.AppParam) (string, error) {
+	application, err := s.ResolveApp(appParam.ID, appParam.Deployment)
+	if err != nil {
+		return "", err
+	}
+	if err := requireCluster(); err != nil {
+		return "", err
+	}
+
+	password, err := util.NewKubernetesUtil(s.ctx).GetPassword(KubernetesParamOf(application))
+	if apierrors.IsNotFound(err) {
+		return "", errors.New("该应用的密码未托管，请先重置密码")
+	}
+	return password, err
+}
+
+// RotatePassword 重置工作空间密码并滚动重启 Pod，未指定新密码时随机生成，返回新密码
+func (s *AppService) RotatePassword(appParam *model.AppParam) (string, error) {
+	application, err := s.ResolveApp(appParam.ID, appParam.Deployment)
+	if err != nil {
+		return "", err
+	}
+	if err := requireCluster(); err != nil {
+		return "", err
+	}
+	if err := preparePassword(appParam); err != nil {
+		return "", err
+	}
+
+	kbParam := KubernetesParamOf(application)
+	kubernetesUtil := util.NewKubernetesUtil(s.ctx)
+	if err := kubernetesUtil.ApplyPasswordSecret(kbParam, appParam.PodPassword); err != nil {
+		return "", err
+	}
+	if err := kubernetesUtil.RolloutPassword(kbParam); err != nil {
+		return "", err
+	}
+	return appParam.PodPassword, nil
+}
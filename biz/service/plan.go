@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"gorm.io/gorm"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+// DefaultPlan 没有分配套餐的用户使用的套餐，规格由环境变量 PLAN_* 配置
+func DefaultPlan() *model.Plan {
+	return &model.Plan{
+		Name:          "default",
+		Description:   "默认套餐",
+		Cpu:           util.GetEnvOrDefault("PLAN_CPU", "4"),
+		Memory:        util.GetEnvOrDefault("PLAN_MEMORY", "8Gi"),
+		Storage:       util.GetEnvOrDefault("PLAN_STORAGE", "50Gi"),
+		MaxWorkspaces: int(envInt64("PLAN_MAX_WORKSPACES", 10)),
+	}
+}
+
+type PlanService struct {
+	ctx context.Context
+	c   *app.RequestContext
+}
+
+func NewPlanService(ctx context.Context, c *app.RequestContext) *PlanService {
+	return &PlanService{ctx: ctx, c: c}
+}
+
+func (s *PlanService) ListPlan() ([]*model.Plan, error) {
+	var plans []*model.Plan
+	err := config.DB.WithContext(s.ctx).Order("id").Find(&plans).Error
+	if err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// GetPlan 根据ID获取套餐，ID为0时返回默认套餐
+func (s *PlanService) GetPlan(id uint) (*model.Plan, error) {
+	if id == 0 {
+		return DefaultPlan(), nil
+	}
+
+	var plan model.Plan
+	err := config.DB.WithContext(s.ctx).First(&plan, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("套餐 %d 不存在", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (s *PlanService) CreatePlan(plan *model.Plan) (uint, error) {
+	if err := validatePlan(plan); err != nil {
+		return 0, err
+	}
+
+	plan.ID = 0
+	if err := config.DB.WithContext(s.ctx).Create(plan).Error; err != nil {
+		return 0, err
+	}
+	return plan.ID, nil
+}
+
+// UpdatePlan 修改套餐，已有的工作空间不受影响，新的规格从下一次创建或修改规格时开始生效
+func (s *PlanService) UpdatePlan(plan *model.Plan) error {
+	if plan.ID == 0 {
+		return errors.New("套餐ID不能为空")
+	}
+	if err := validatePlan(plan); err != nil {
+		return err
+	}
+
+	result := config.DB.WithContext(s.ctx).Model(&model.Plan{Model: gorm.Model{ID: plan.ID}}).
+		Select("name", "description", "cpu", "memory", "storage", "max_workspaces", "max_running",
+			"total_cpu", "total_memory", "total_storage", "template_ids").
+		Updates(plan)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("套餐 %d 不存在", plan.ID)
+	}
+	return nil
+}
+
+func (s *PlanService) DeletePlan(id uint) error {
+	if id == 0 {
+		return errors.New("套餐ID不能为空")
+	}
+
+	var count int64
+	err := config.DB.WithContext(s.ctx).Model(&model.User{}).Where("plan_id = ?", id).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("套餐仍分配给 %d 个用户，无法删除", count)
+	}
+
+	return config.DB.WithContext(s.ctx).Delete(&model.Plan{}, id).Error
+}
+
+// AssignPlan 为用户分配套餐
+func (s *PlanService) AssignPlan(param *model.PlanAssignParam) error {
+	if param.UserId == 0 {
+		return errors.New("用户ID不能为空")
+	}
+	if _, err := s.GetPlan(param.PlanId); err != nil {
+		return err
+	}
+
+	result := config.DB.WithContext(s.ctx).Model(&model.User{}).Where("id = ?", param.UserId).Update("plan_id", param.PlanId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("用户 %d 不存在", param.UserId)
+	}
+	return nil
+}
+
+// PlanOf 返回用户当前的套餐
+func PlanOf(ctx context.Context, userId uint) (*model.Plan, error) {
+	var user model.User
+	err := config.DB.WithContext(ctx).Select("id", "plan_id").First(&user, userId).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+	return NewPlanService(ctx, nil).GetPlan(user.PlanId)
+}
+
+// applyPlan 按选择的套餐和模板补全新工作空间的规格，再检查用户的套餐是否允许创建
+func applyPlan(ctx context.Context, backend util.WorkspaceBackend, appParam *model.AppParam, template *model.WorkspaceTemplate) error {
+	if appParam.PlanId != 0 {
+		picked, err := NewPlanService(ctx, nil).GetPlan(appParam.PlanId)
+		if err != nil {
+			return err
+		}
+		if appParam.Cpu == "" {
+			appParam.Cpu = picked.Cpu
+		}
+		if appParam.Memory == "" {
+			appParam.Memory = picked.Memory
+		}
+		if appParam.Storage == "" {
+			appParam.Storage = picked.Storage
+		}
+	}
+
+	// 未指定资源时使用模板的默认值
+	if appParam.Cpu == "" {
+		appParam.Cpu = template.Cpu
+	}
+	if appParam.Memory == "" {
+		appParam.Memory = template.Memory
+	}
+	appParam.Storage = util.StorageOf(appParam)
+
+	return admitWorkspace(ctx, backend, appParam.UserId, appParam.TemplateId, appParam.Cpu, appParam.Memory, appParam.Storage)
+}
+
+// admitWorkspace 检查用户的套餐是否允许再用指定的模板和规格创建一个工作空间，新工作空间创建后即运行
+func admitWorkspace(ctx context.Context, backend util.WorkspaceBackend, userId, templateId uint, cpu, memory, storage string) error {
+	plan, limits, err := LimitsOf(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if !plan.AllowsTemplate(templateId) {
+		return fmt.Errorf("当前套餐 %s 不能使用模板 %d", plan.Name, templateId)
+	}
+	if err := checkPlanSpec(plan, cpu, memory, storage); err != nil {
+		return err
+	}
+
+	used, err := usageOf(ctx, backend, userId)
+	if err != nil {
+		return err
+	}
+	added := &workspaceUsage{workspaces: 1, running: 1}
+	addQuantity(&added.cpu, cpu)
+	addQuantity(&added.memory, memory)
+	addQuantity(&added.storage, storage)
+	return checkLimits(limits, used, added)
+}
+
+// checkPlanSpec 检查工作空间的规格是否合法且不超过套餐的上限，storage 为空时不检查存储
+func checkPlanSpec(plan *model.Plan, cpu, memory, storage string) error {
+	specs := []struct {
+		label, value, limit string
+		optional            bool
+	}{
+		{"CPU", cpu, plan.Cpu, false},
+		{"内存", memory, plan.Memory, false},
+		{"存储", storage, plan.Storage, true},
+	}
+	for _, spec := range specs {
+		if spec.optional && spec.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(spec.value)
+		if err != nil || quantity.Sign() <= 0 {
+			return fmt.Errorf("%s 格式错误: %s", spec.label, spec.value)
+		}
+		if spec.limit == "" {
+			continue
+		}
+		limit, err := resource.ParseQuantity(spec.limit)
+		if err != nil {
+			return fmt.Errorf("套餐 %s 的%s上限格式错误: %s", plan.Name, spec.label, spec.limit)
+		}
+		if quantity.Cmp(limit) > 0 {
+			return fmt.Errorf("%s %s 超出套餐 %s 的上限 %s", spec.label, spec.value, plan.Name, spec.limit)
+		}
+	}
+	return nil
+}
+
+// validatePlan 校验套餐字段
+func validatePlan(plan *model.Plan) error {
+	if plan.Name == "" {
+		return errors.New("套餐名称不能为空")
+	}
+	for _, spec := range [][2]string{{"CPU", plan.Cpu}, {"内存", plan.Memory}, {"存储", plan.Storage}} {
+		quantity, err := resource.ParseQuantity(spec[1])
+		if err != nil || quantity.Sign() <= 0 {
+			return fmt.Errorf("%s 格式错误: %s", spec[0], spec[1])
+		}
+	}
+	if plan.MaxWorkspaces < 0 || plan.MaxRunning < 0 {
+		return errors.New("数量上限不能为负数")
+	}
+	for _, spec := range [][2]string{{"CPU", plan.TotalCpu}, {"内存", plan.TotalMemory}, {"存储", plan.TotalStorage}} {
+		if spec[1] == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(spec[1]); err != nil {
+			return fmt.Errorf("%s 总量格式错误: %s", spec[0], spec[1])
+		}
+	}
+	return nil
+}
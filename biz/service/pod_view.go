@@ -0,0 +1,71 @@
+package service
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"learn/biz/model"
+)
+
+// podViewOf 把 Pod 转换成对外返回的 WorkspacePodView，application 为所属应用的名称
+func podViewOf(pod *corev1.Pod, application string) *model.WorkspacePodView {
+	view := &model.WorkspacePodView{
+		Name:        pod.Name,
+		Namespace:   pod.Namespace,
+		Deployment:  pod.Labels["deployment"],
+		Application: application,
+		Phase:       pod.Status.Phase,
+		Conditions:  pod.Status.Conditions,
+		NodeName:    pod.Spec.NodeName,
+	}
+	if pod.Status.StartTime != nil {
+		startTime := pod.Status.StartTime.Time
+		view.StartTime = &startTime
+	}
+	view.Ready = podReady(pod)
+
+	statuses := make(map[string]corev1.ContainerStatus, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses[status.Name] = status
+	}
+	for _, container := range pod.Spec.Containers {
+		containerView := model.ContainerView{
+			Name:     container.Name,
+			Image:    container.Image,
+			Requests: resourceMapOf(container.Resources.Requests),
+			Limits:   resourceMapOf(container.Resources.Limits),
+		}
+		if status, ok := statuses[container.Name]; ok {
+			containerView.Ready = status.Ready
+			containerView.RestartCount = status.RestartCount
+			switch {
+			case status.State.Running != nil:
+				containerView.State = "running"
+			case status.State.Waiting != nil:
+				containerView.State = "waiting"
+				containerView.Reason = status.State.Waiting.Reason
+			case status.State.Terminated != nil:
+				containerView.State = "terminated"
+				containerView.Reason = status.State.Terminated.Reason
+			}
+		}
+		view.Containers = append(view.Containers, containerView)
+	}
+	return view
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func resourceMapOf(resources corev1.ResourceList) map[string]string {
+	result := make(map[string]string, len(resources))
+	for name, quantity := range resources {
+		result[string(name)] = quantity.String()
+	}
+	return result
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+// provisionStaleAfter 心跳超过该时长未刷新的流程视为所在实例已退出
+const provisionStaleAfter = 2 * time.Minute
+
+// provisionHeartbeatInterval 执行创建流程期间刷新心跳的间隔，测试中可以缩短
+var provisionHeartbeatInterval = 30 * time.Second
+
+// provisionOwner 当前服务实例的标识，多副本部署时区分各实例执行的创建流程
+var provisionOwner = instanceName()
+
+func instanceName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8])
+}
+
+// provisionStep 创建流程中的一步，run 成功后记录 state，失败时按逆序执行已开始步骤的 undo
+type provisionStep struct {
+	name  string
+	state string
+	run   func() error
+	undo  func() error
+}
+
+type ProvisionService struct {
+	ctx context.Context
+	c   *app.RequestContext
+}
+
+func NewProvisionService(ctx context.Context, c *app.RequestContext) *ProvisionService {
+	return &ProvisionService{ctx: ctx, c: c}
+}
+
+// Start 持久化一条 pending 状态的创建记录，记录由当前实例执行
+func (s *ProvisionService) Start(kbParam *model.KubernetesParam, userId uint) (*model.Provision, error) {
+	now := time.Now()
+	record := &model.Provision{
+		UserId:      userId,
+		Deployment:  kbParam.Deployment,
+		Namespace:   kbParam.Namespace,
+		Pvc:         kbParam.Pvc,
+		Svc:         kbParam.Svc,
+		Secret:      kbParam.Secret,
+		State:       model.ProvisionPending,
+		Owner:       provisionOwner,
+		HeartbeatAt: &now,
+	}
+	if err := config.DB.WithContext(s.ctx).Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Run 依次执行各步骤并持久化进度，任一步骤失败时逆序回滚并把记录标记为 failed
+func (s *ProvisionService) Run(record *model.Provision, steps []provisionStep) error {
+	stop := s.keepAlive(record)
+	defer stop()
+
+	for i, step := range steps {
+		s.update(record, map[string]interface{}{"step": step.name})
+
+		if err := step.run(); err != nil {
+			log.Printf("创建流程失败 - Deployment: %s, Step: %s, Error: %v", record.Deployment, step.name, err)
+
+			// 失败的步骤可能已经创建了部分资源，一并回滚
+			rolledBack := true
+			for j := i; j >= 0; j-- {
+				if steps[j].undo == nil {
+					continue
+				}
+				if undoErr := steps[j].undo(); undoErr != nil && !apierrors.IsNotFound(undoErr) {
+					log.Printf("回滚失败 - Deployment: %s, Step: %s, Error: %v", record.Deployment, steps[j].name, undoErr)
+					rolledBack = false
+				}
+			}
+
+			s.update(record, map[string]interface{}{
+				"state":       model.ProvisionFailed,
+				"error":       err.Error(),
+				"rolled_back": rolledBack,
+			})
+			return err
+		}
+
+		s.update(record, map[string]interface{}{"state": step.state})
+	}
+
+	s.update(record, map[string]interface{}{"state": model.ProvisionReady, "step": ""})
+	return nil
+}
+
+// GetProvisionStatus 查询当前用户某个应用的创建进度
+func (s *ProvisionService) GetProvisionStatus(deployment string) (*model.Provision, error) {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return nil, errors.New("没有找到用户ID")
+	}
+
+	var record model.Provision
+	err := config.DB.WithContext(s.ctx).
+		Where("deployment = ? AND user_id = ?", deployment, userId).
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAppNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// keepAlive 在流程执行期间定期刷新心跳，让其他实例知道流程仍在进行，返回的函数停止刷新
+func (s *ProvisionService) keepAlive(record *model.Provision) func() {
+	db := config.DB.WithContext(s.ctx)
+	done := make(chan struct{})
+	ticker := time.NewTicker(provisionHeartbeatInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := db.Model(&model.Provision{}).
+					Where("id = ? AND owner = ?", record.ID, provisionOwner).
+					Update("heartbeat_at", time.Now()).Error
+				if err != nil {
+					log.Printf("刷新创建流程心跳失败 - Deployment: %s, Error: %v", record.Deployment, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// resumeSource 重新启动克隆流程停止的源工作空间，未停止或源工作空间已被删除时跳过
+func (s *ProvisionService) resumeSource(backend util.WorkspaceBackend, record *model.Provision) error {
+	if !record.SourceStopped {
+		return nil
+	}
+	var source model.Application
+	err := config.DB.WithContext(s.ctx).
+		Where("deployment = ? AND user_id = ?", record.Source, record.UserId).
+		First(&source).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := startWorkspace(s.ctx, backend, &source); err != nil {
+		return err
+	}
+	return s.markSource(record, record.Source, false)
+}
+
+// markSource 记录克隆流程是否停止了源工作空间，流程中断后由恢复流程重新启动
+func (s *ProvisionService) markSource(record *model.Provision, source string, stopped bool) error {
+	err := config.DB.WithContext(s.ctx).Model(record).Updates(map[string]interface{}{
+		"source":         source,
+		"source_stopped": stopped,
+	}).Error
+	if err != nil {
+		return err
+	}
+	record.Source, record.SourceStopped = source, stopped
+	return nil
+}
+
+func (s *ProvisionService) update(record *model.Provision, updates map[string]interface{}) {
+	if err := config.DB.WithContext(s.ctx).Model(record).Updates(updates).Error; err != nil {
+		log.Printf("更新创建记录失败 - Deployment: %s, Error: %v", record.Deployment, err)
+	}
+}
+
+// RecoverInterruptedProvisions 把所在实例已退出、中断在中间状态的创建流程回滚并标记为失败
+// 启动时和之后定期执行，多个实例同时执行时每条记录只会被一个实例接管，仍有心跳的流程不受影响
+func RecoverInterruptedProvisions(ctx context.Context) {
+	recoverStaleProvisions(ctx, util.NewWorkspaceBackend(ctx))
+}
+
+func recoverStaleProvisions(ctx context.Context, backend util.WorkspaceBackend) {
+	finished := []string{model.ProvisionReady, model.ProvisionFailed}
+	staleBefore := time.Now().Add(-provisionStaleAfter)
+
+	var records []*model.Provision
+	err := config.DB.WithContext(ctx).
+		Where("state NOT IN ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", finished, staleBefore).
+		Find(&records).Error
+	if err != nil {
+		log.Printf("查询未完成的创建记录失败: %v", err)
+		return
+	}
+
+	s := NewProvisionService(ctx, nil)
+	for _, record := range records {
+		// 条件更新接管记录，查询之后心跳被刷新或已被其他实例接管时跳过
+		now := time.Now()
+		result := config.DB.WithContext(ctx).Model(&model.Provision{}).
+			Where("id = ? AND state NOT IN ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", record.ID, finished, staleBefore).
+			Updates(map[string]interface{}{"owner": provisionOwner, "heartbeat_at": now})
+		if result.Error != nil {
+			log.Printf("接管创建记录失败 - Deployment: %s, Error: %v", record.Deployment, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		kbParam := &model.KubernetesParam{
+			Namespace:  record.Namespace,
+			Deployment: record.Deployment,
+			Svc:        record.Svc,
+			Pvc:        record.Pvc,
+			Secret:     record.Secret,
+		}
+
+		rolledBack := true
+		if err := backend.Destroy(kbParam); err != nil {
+			rolledBack = false
+		}
+		err := config.DB.WithContext(ctx).
+			Delete(&model.Application{}, "deployment = ? AND user_id = ?", record.Deployment, record.UserId).Error
+		if err != nil {
+			rolledBack = false
+		}
+		// 克隆流程在复制期间停止了源工作空间，新工作空间删除后再重新启动
+		if err := s.resumeSource(backend, record); err != nil {
+			log.Printf("重新启动源工作空间失败 - Deployment: %s, Error: %v", record.Source, err)
+			rolledBack = false
+		}
+
+		s.update(record, map[string]interface{}{
+			"state":       model.ProvisionFailed,
+			"error":       fmt.Sprintf("执行创建的服务实例退出，创建在 %s 步骤中断", record.Step),
+			"rolled_back": rolledBack,
+		})
+		log.Printf("已回滚中断的创建流程 - Deployment: %s", record.Deployment)
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/testutil"
+	"learn/biz/util"
+)
+
+func TestRecoverStaleProvisionsSkipsLiveOwners(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.CreateUser(t, 1)
+	backend := util.NewMemoryBackend()
+
+	fresh := time.Now()
+	stale := fresh.Add(-2 * provisionStaleAfter)
+	records := map[string]*model.Provision{
+		// 其他实例仍在执行的流程
+		"deployment-live0001": {Owner: "other", HeartbeatAt: &fresh},
+		// 所在实例已退出的流程
+		"deployment-dead0001": {Owner: "other", HeartbeatAt: &stale},
+		// 升级前创建、没有心跳的记录
+		"deployment-old00001": {},
+	}
+	for deployment, record := range records {
+		record.UserId = 1
+		record.Deployment = deployment
+		record.Namespace = "ns-1"
+		record.State = model.ProvisionDeploymentCreated
+		if err := config.DB.Create(record).Error; err != nil {
+			t.Fatalf("写入创建记录失败: %v", err)
+		}
+		testutil.CreateApp(t, 1, deployment)
+	}
+
+	recoverStaleProvisions(context.Background(), backend)
+
+	want := map[string]string{
+		"deployment-live0001": model.ProvisionDeploymentCreated,
+		"deployment-dead0001": model.ProvisionFailed,
+		"deployment-old00001": model.ProvisionFailed,
+	}
+	for deployment, state := range want {
+		var record model.Provision
+		if err := config.DB.Where("deployment = ?", deployment).First(&record).Error; err != nil {
+			t.Fatalf("查询创建记录失败: %v", err)
+		}
+		if record.State != state {
+			t.Fatalf("%s 的状态为 %s，期望 %s", deployment, record.State, state)
+		}
+
+		var count int64
+		config.DB.Model(&model.Application{}).Where("deployment = ?", deployment).Count(&count)
+		if kept := state != model.ProvisionFailed; kept != (count == 1) {
+			t.Fatalf("%s 的应用记录数量为 %d", deployment, count)
+		}
+	}
+
+	// 已接管并回滚的记录不会被再次处理
+	recoverStaleProvisions(context.Background(), backend)
+	var live model.Provision
+	config.DB.Where("deployment = ?", "deployment-live0001").First(&live)
+	if live.Owner != "other" {
+		t.Fatalf("仍有心跳的记录被接管: %s", live.Owner)
+	}
+}
+
+func TestRunRefreshesHeartbeat(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.CreateUser(t, 1)
+
+	record, err := NewProvisionService(context.Background(), nil).Start(&model.KubernetesParam{
+		Namespace:  "ns-1",
+		Deployment: "deployment-aaaa1111",
+	}, 1)
+	if err != nil {
+		t.Fatalf("写入创建记录失败: %v", err)
+	}
+	if record.Owner != provisionOwner || record.HeartbeatAt == nil {
+		t.Fatalf("创建记录没有写入执行实例: %+v", record)
+	}
+
+	// 心跳刷新后，流程即使超过过期时长也不会被回滚
+	stale := time.Now().Add(-2 * provisionStaleAfter)
+	config.DB.Model(record).Update("heartbeat_at", stale)
+	interval := provisionHeartbeatInterval
+	provisionHeartbeatInterval = 10 * time.Millisecond
+	t.Cleanup(func() { provisionHeartbeatInterval = interval })
+
+	stop := NewProvisionService(context.Background(), nil).keepAlive(record)
+	defer stop()
+	waitFor(t, "刷新心跳", func() bool {
+		var current model.Provision
+		config.DB.First(&current, record.ID)
+		return current.HeartbeatAt != nil && current.HeartbeatAt.After(stale.Add(provisionStaleAfter))
+	})
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+// QuotaOf 按用户的套餐和限额推导命名空间的配额，套餐没有限制的项沿用环境变量 QUOTA_* 的值
+// 配额是集群侧的兜底，准入由 checkLimits 完成，所以按限额允许的最大用量计算，并留出 sidecar 的用量
+// 和恢复快照时新旧数据卷同时存在所需的一个数据卷，无论如何配置都至少能运行一个套餐上限规格的工作空间
+func QuotaOf(ctx context.Context, userId uint) (*model.NamespaceQuota, error) {
+	plan, limits, err := LimitsOf(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	quota := &model.NamespaceQuota{
+		Pvcs:          envInt64("QUOTA_PVCS", 10),
+		Pods:          envInt64("QUOTA_PODS", 20),
+		DefaultCpu:    util.GetEnvOrDefault("QUOTA_DEFAULT_CPU", "100m"),
+		DefaultMemory: util.GetEnvOrDefault("QUOTA_DEFAULT_MEMORY", "128Mi"),
+	}
+	defaultCpu, err := resource.ParseQuantity(quota.DefaultCpu)
+	if err != nil {
+		return nil, fmt.Errorf("QUOTA_DEFAULT_CPU 格式错误: %s", quota.DefaultCpu)
+	}
+	defaultMemory, err := resource.ParseQuantity(quota.DefaultMemory)
+	if err != nil {
+		return nil, fmt.Errorf("QUOTA_DEFAULT_MEMORY 格式错误: %s", quota.DefaultMemory)
+	}
+	storage, err := resource.ParseQuantity(plan.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("套餐 %s 的存储格式错误: %s", plan.Name, plan.Storage)
+	}
+
+	// 可能同时运行的工作空间数量，没有数量限制时按 Pod 数量上限估计 sidecar 的个数
+	running := int64(limits.MaxRunning)
+	if running == 0 {
+		running = int64(limits.MaxWorkspaces)
+	}
+	sidecars := running
+	if sidecars == 0 {
+		sidecars = quota.Pods
+	}
+
+	quota.Cpu, err = quotaOf(util.GetEnvOrDefault("QUOTA_CPU", "8"), limits.Cpu, plan.Cpu, running, defaultCpu, sidecars)
+	if err != nil {
+		return nil, err
+	}
+	quota.Memory, err = quotaOf(util.GetEnvOrDefault("QUOTA_MEMORY", "16Gi"), limits.Memory, plan.Memory, running, defaultMemory, sidecars)
+	if err != nil {
+		return nil, err
+	}
+	quota.Storage, err = quotaOf(util.GetEnvOrDefault("QUOTA_STORAGE", "200Gi"), limits.Storage, plan.Storage, int64(limits.MaxWorkspaces), storage, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if limits.MaxWorkspaces > 0 {
+		quota.Pvcs = int64(limits.MaxWorkspaces) + 1
+	}
+	if quota.Pvcs < 2 {
+		quota.Pvcs = 2
+	}
+	if quota.Pods < running+1 {
+		quota.Pods = running + 1
+	}
+	return quota, nil
+}
+
+// quotaOf 计算一项配额：有总量限制时取总量，否则有数量限制时取 count 个套餐上限规格的工作空间，这两种情况再加上 extraCount 份 extra；
+// 都没有限制时沿用 fallback。结果不小于一个套餐上限规格的工作空间加一份 extra
+func quotaOf(fallback, total, perWorkspace string, count int64, extra resource.Quantity, extraCount int64) (string, error) {
+	per, err := resource.ParseQuantity(perWorkspace)
+	if err != nil {
+		return "", fmt.Errorf("套餐规格格式错误: %s", perWorkspace)
+	}
+
+	var value resource.Quantity
+	switch {
+	case total != "":
+		if value, err = resource.ParseQuantity(total); err != nil {
+			return "", fmt.Errorf("总量限额格式错误: %s", total)
+		}
+	case count > 0:
+		value = per.DeepCopy()
+		value.Mul(count)
+	default:
+		if value, err = resource.ParseQuantity(fallback); err != nil {
+			return "", fmt.Errorf("配额格式错误: %s", fallback)
+		}
+		extraCount = 0
+	}
+	reserved := extra.DeepCopy()
+	reserved.Mul(extraCount)
+	value.Add(reserved)
+
+	minimum := per.DeepCopy()
+	minimum.Add(extra)
+	if value.Cmp(minimum) < 0 {
+		value = minimum
+	}
+	return value.String(), nil
+}
+
+// checkWorkspaceQuota 同步用户命名空间的配额，并检查再创建一个指定规格的工作空间是否会超出配额
+func checkWorkspaceQuota(ctx context.Context, backend util.WorkspaceBackend, userId uint, namespace, cpu, memory, storage string) error {
+	quota, err := QuotaOf(ctx, userId)
+	if err != nil {
+		return err
+	}
+	request, err := util.WorkspaceRequest(cpu, memory, storage, quota)
+	if err != nil {
+		return err
+	}
+	return backend.EnsureTenant(namespace, quota, request)
+}
+
+// syncQuota 按用户当前的套餐同步命名空间的配额，套餐调整后在启动或调整规格前调用，避免旧配额挡住新套餐允许的用量
+func syncQuota(ctx context.Context, backend util.WorkspaceBackend, userId uint) error {
+	quota, err := QuotaOf(ctx, userId)
+	if err != nil {
+		return err
+	}
+	return backend.EnsureTenant(fmt.Sprintf("ns-%d", userId), quota, nil)
+}
+
+func envInt64(key string, defaultValue int64) int64 {
+	value, err := strconv.ParseInt(util.GetEnvOrDefault(key, ""), 10, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
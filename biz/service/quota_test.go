@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"learn/biz/model"
+	"learn/biz/testutil"
+	"learn/biz/util"
+)
+
+// assignTestPlan 创建套餐并分配给用户
+func assignTestPlan(t *testing.T, userId uint, plan *model.Plan) {
+	t.Helper()
+	s := NewPlanService(context.Background(), nil)
+	id, err := s.CreatePlan(plan)
+	if err != nil {
+		t.Fatalf("创建套餐失败: %v", err)
+	}
+	if err := s.AssignPlan(&model.PlanAssignParam{UserId: userId, PlanId: id}); err != nil {
+		t.Fatalf("分配套餐失败: %v", err)
+	}
+}
+
+func TestQuotaOf(t *testing.T) {
+	t.Setenv("QUOTA_CPU", "8")
+	t.Setenv("QUOTA_MEMORY", "16Gi")
+	t.Setenv("QUOTA_STORAGE", "200Gi")
+	t.Setenv("QUOTA_PVCS", "10")
+	t.Setenv("QUOTA_PODS", "20")
+	t.Setenv("QUOTA_DEFAULT_CPU", "100m")
+	t.Setenv("QUOTA_DEFAULT_MEMORY", "128Mi")
+
+	tests := []struct {
+		name                 string
+		plan                 *model.Plan
+		cpu, memory, storage string
+		pvcs                 int64
+	}{
+		{
+			name:    "没有任何限制时沿用环境变量",
+			plan:    &model.Plan{Name: "unlimited", Cpu: "2", Memory: "4Gi", Storage: "10Gi"},
+			cpu:     "8",
+			memory:  "16Gi",
+			storage: "200Gi",
+			pvcs:    10,
+		},
+		{
+			name:    "按总量限额加上 sidecar 和恢复快照的余量",
+			plan:    &model.Plan{Name: "total", Cpu: "2", Memory: "4Gi", Storage: "10Gi", MaxWorkspaces: 3, MaxRunning: 2, TotalCpu: "3", TotalMemory: "6Gi", TotalStorage: "20Gi"},
+			cpu:     "3200m",
+			memory:  "6400Mi",
+			storage: "30Gi",
+			pvcs:    4,
+		},
+		{
+			name:    "没有总量时按数量乘以单个规格",
+			plan:    &model.Plan{Name: "count", Cpu: "2", Memory: "4Gi", Storage: "10Gi", MaxWorkspaces: 3},
+			cpu:     "6300m",
+			memory:  "12672Mi",
+			storage: "40Gi",
+			pvcs:    4,
+		},
+		{
+			name:    "套餐规格超过环境变量时仍能创建一个工作空间",
+			plan:    &model.Plan{Name: "large", Cpu: "16", Memory: "64Gi", Storage: "500Gi"},
+			cpu:     "16100m",
+			memory:  "65664Mi",
+			storage: "1000Gi",
+			pvcs:    10,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.SetupDB(t)
+			userId := uint(i + 1)
+			testutil.CreateUser(t, userId)
+			assignTestPlan(t, userId, tt.plan)
+
+			quota, err := QuotaOf(context.Background(), userId)
+			if err != nil {
+				t.Fatalf("计算配额失败: %v", err)
+			}
+			for _, spec := range [][3]string{{"CPU", quota.Cpu, tt.cpu}, {"内存", quota.Memory, tt.memory}, {"存储", quota.Storage, tt.storage}} {
+				got, want := resource.MustParse(spec[1]), resource.MustParse(spec[2])
+				if got.Cmp(want) != 0 {
+					t.Errorf("%s 配额为 %s，期望 %s", spec[0], spec[1], spec[2])
+				}
+			}
+			if quota.Pvcs != tt.pvcs {
+				t.Errorf("数据卷数量为 %d，期望 %d", quota.Pvcs, tt.pvcs)
+			}
+
+			// 套餐上限规格的工作空间一定能通过配额检查
+			backend := util.NewMemoryBackend()
+			if err := checkWorkspaceQuota(context.Background(), backend, userId, "ns-test", tt.plan.Cpu, tt.plan.Memory, tt.plan.Storage); err != nil {
+				t.Fatalf("套餐上限规格的工作空间被配额拒绝: %v", err)
+			}
+		})
+	}
+}
+
+func TestQuotaOfUsesUserLimit(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.CreateUser(t, 1)
+	testutil.CreateUser(t, 2)
+	maxRunning := 1
+	setUserLimit(t, &model.UserLimit{UserId: 1, MaxRunning: &maxRunning, TotalCpu: "1"})
+
+	first, err := QuotaOf(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("计算配额失败: %v", err)
+	}
+	second, err := QuotaOf(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("计算配额失败: %v", err)
+	}
+	if first.Cpu == second.Cpu {
+		t.Fatalf("单独设置了限额的用户配额应当不同: %s", first.Cpu)
+	}
+}
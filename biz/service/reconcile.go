@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+const (
+	// reconcileReportKey 最近一次对账结果
+	reconcileReportKey = "reconcile:report"
+	// orphanFirstSeenKey 孤儿资源第一次被发现的时间，字段为 <namespace>/<kind>/<name>
+	orphanFirstSeenKey = "reconcile:orphans"
+)
+
+// reconcileMu 保证定时任务与管理员手动触发的对账不会同时执行
+var reconcileMu sync.Mutex
+
+// ReconcileService 对比 applications 表与集群中的工作空间资源
+// 报告缺少资源的应用和没有应用记录的孤儿资源，为运行中的应用补建 Service，
+// 开启 RECONCILE_GC 后删除超过宽限期（RECONCILE_GC_GRACE_MINUTES，默认 1440 分钟）的孤儿资源
+type ReconcileService struct {
+	ctx context.Context
+}
+
+func NewReconcileService(ctx context.Context) *ReconcileService {
+	return &ReconcileService{ctx: ctx}
+}
+
+// Reconcile 执行一次对账，dryRun 为 true 时只报告不修改集群，也不记录孤儿资源的发现时间
+func (s *ReconcileService) Reconcile(dryRun bool) (*model.DriftReport, error) {
+	if err := requireCluster(); err != nil {
+		return nil, err
+	}
+	if !reconcileMu.TryLock() {
+		return nil, errors.New("对账正在进行中，请稍后再试")
+	}
+	defer reconcileMu.Unlock()
+
+	report := &model.DriftReport{DryRun: dryRun, StartedAt: time.Now(), Items: []*model.DriftItem{}}
+
+	var applications []*model.Application
+	if err := config.DB.WithContext(s.ctx).Find(&applications).Error; err != nil {
+		return nil, fmt.Errorf("查询应用列表失败: %w", err)
+	}
+	busy, err := s.busyWorkspaces()
+	if err != nil {
+		return nil, err
+	}
+	// 恢复快照时保留的原 PVC 在新 PVC 绑定后回收，回收前不作为孤儿资源
+	retained, err := NewSnapshotService(s.ctx, nil).CollectReplacedPvcs(dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	kubernetesUtil := util.NewKubernetesUtil(s.ctx)
+	namespaces, err := kubernetesUtil.ListUserNamespaces()
+	if err != nil {
+		return nil, fmt.Errorf("查询用户命名空间失败: %w", err)
+	}
+	if !dryRun {
+		// 已有的命名空间同样按当前配置更新网络策略
+		for _, namespace := range namespaces {
+			if err := kubernetesUtil.EnsureNetworkPolicies(namespace); err != nil {
+				log.Printf("同步命名空间 %s 的网络策略失败: %v", namespace, err)
+			}
+		}
+	}
+
+	// 按命名空间分组，命名空间已被删除的应用同样需要报告
+	appsByNamespace := make(map[string]map[string]*model.Application)
+	for _, application := range applications {
+		if len(application.Deployment) < 8 {
+			continue
+		}
+		namespace := fmt.Sprintf("ns-%d", application.UserId)
+		if appsByNamespace[namespace] == nil {
+			appsByNamespace[namespace] = make(map[string]*model.Application)
+			namespaces = append(namespaces, namespace)
+		}
+		appsByNamespace[namespace][suffixOfName(application.Deployment)] = application
+	}
+
+	firstSeen, err := s.orphanFirstSeen()
+	if err != nil {
+		return nil, err
+	}
+	orphans := make(map[string]bool)
+
+	visited := make(map[string]bool)
+	for _, namespace := range namespaces {
+		if visited[namespace] {
+			continue
+		}
+		visited[namespace] = true
+
+		resources, err := kubernetesUtil.ListWorkspaceResources(namespace)
+		if err != nil {
+			log.Printf("查询命名空间 %s 中的资源失败: %v", namespace, err)
+			continue
+		}
+
+		for suffix, application := range appsByNamespace[namespace] {
+			if busy[namespace+"/"+suffix] {
+				continue
+			}
+			report.Items = append(report.Items, s.checkApplication(application, resources, dryRun)...)
+		}
+
+		for kind, names := range resources.Names {
+			for name := range names {
+				suffix := suffixOfName(name)
+				if s.ownedBy(appsByNamespace[namespace][suffix], kind, name) || busy[namespace+"/"+suffix] ||
+					(kind == util.KindPvc && retained[namespace+"/"+name]) {
+					continue
+				}
+				field := fmt.Sprintf("%s/%s/%s", namespace, kind, name)
+				orphans[field] = true
+				report.Items = append(report.Items, s.handleOrphan(namespace, kind, name, field, firstSeen, dryRun))
+			}
+		}
+	}
+
+	if !dryRun {
+		// 已经不再是孤儿的资源清除发现时间，之后再次出现时重新计算宽限期
+		for field := range firstSeen {
+			if !orphans[field] {
+				config.RedisClient.HDel(s.ctx, orphanFirstSeenKey, field)
+			}
+		}
+	}
+
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Deployment != b.Deployment {
+			return a.Deployment < b.Deployment
+		}
+		return a.Kind < b.Kind
+	})
+	report.FinishedAt = time.Now()
+
+	if data, err := json.Marshal(report); err == nil {
+		if err := config.RedisClient.Set(s.ctx, reconcileReportKey, data, 0).Err(); err != nil {
+			log.Printf("保存对账结果失败: %v", err)
+		}
+	}
+	log.Printf("对账完成，发现 %d 处不一致 (dry_run=%v)", len(report.Items), dryRun)
+	return report, nil
+}
+
+// LastReport 返回最近一次对账的结果，还没有执行过时返回 nil
+func (s *ReconcileService) LastReport() (*model.DriftReport, error) {
+	data, err := config.RedisClient.Get(s.ctx, reconcileReportKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取对账结果失败: %w", err)
+	}
+
+	var report model.DriftReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, fmt.Errorf("解析对账结果失败: %w", err)
+	}
+	return &report, nil
+}
+
+// checkApplication 检查应用的 Deployment、PVC 和 Service 是否存在，运行中的应用缺少 Service 时重新创建访问入口
+// 旧版本创建的应用没有密码 Secret，这里不检查 Secret
+func (s *ReconcileService) checkApplication(application *model.Application, resources *util.WorkspaceResources, dryRun bool) []*model.DriftItem {
+	kbParam := KubernetesParamOf(application)
+	var items []*model.DriftItem
+	missing := func(kind, name string) *model.DriftItem {
+		item := &model.DriftItem{
+			Type:       model.DriftMissing,
+			Namespace:  kbParam.Namespace,
+			Kind:       kind,
+			Name:       name,
+			Deployment: application.Deployment,
+			Action:     model.DriftActionNone,
+		}
+		items = append(items, item)
+		return item
+	}
+
+	if !resources.Has(util.KindDeployment, kbParam.Deployment) {
+		missing(util.KindDeployment, kbParam.Deployment)
+	}
+	if !resources.Has(util.KindPvc, kbParam.Pvc) {
+		missing(util.KindPvc, kbParam.Pvc)
+	}
+	if resources.Running[kbParam.Deployment] && !resources.Has(util.KindService, kbParam.Svc) {
+		item := missing(util.KindService, kbParam.Svc)
+		if !dryRun {
+			if err := s.recreateService(application, kbParam); err != nil {
+				item.Action = model.DriftActionFailed
+				item.Message = err.Error()
+			} else {
+				item.Action = model.DriftActionRecreated
+			}
+		}
+	}
+	return items
+}
+
+func (s *ReconcileService) recreateService(application *model.Application, kbParam *model.KubernetesParam) error {
+	template, err := NewTemplateService(s.ctx, nil).GetTemplate(application.TemplateId)
+	if err != nil {
+		return err
+	}
+	kbParam.Port = template.Ports[0].ContainerPort
+
+	if err := util.NewExposer(s.ctx).Expose(kbParam, application); err != nil {
+		return err
+	}
+	log.Printf("已为运行中的应用补建访问入口 - Deployment: %s", application.Deployment)
+	return config.DB.WithContext(s.ctx).Model(&model.Application{}).
+		Where("deployment = ?", application.Deployment).
+		Update("url", application.Url).Error
+}
+
+// ownedBy 资源是否属于应用，恢复快照后应用的 PVC 不再是 pvc-<后缀>，同后缀的其他 PVC 视为孤儿
+func (s *ReconcileService) ownedBy(application *model.Application, kind, name string) bool {
+	if application == nil {
+		return false
+	}
+	return kind != util.KindPvc || name == KubernetesParamOf(application).Pvc
+}
+
+// handleOrphan 记录孤儿资源第一次被发现的时间，开启回收且超过宽限期后删除
+func (s *ReconcileService) handleOrphan(namespace, kind, name, field string, firstSeen map[string]time.Time, dryRun bool) *model.DriftItem {
+	suffix := suffixOfName(name)
+	item := &model.DriftItem{
+		Type:       model.DriftOrphan,
+		Namespace:  namespace,
+		Kind:       kind,
+		Name:       name,
+		Deployment: util.WorkspaceNamePrefixes[util.KindDeployment] + suffix,
+		Action:     model.DriftActionNone,
+	}
+
+	seen, ok := firstSeen[field]
+	if !ok {
+		seen = time.Now()
+		if !dryRun {
+			config.RedisClient.HSetNX(s.ctx, orphanFirstSeenKey, field, seen.Format(time.RFC3339))
+		}
+	}
+	item.FirstSeen = &seen
+
+	if dryRun || util.GetEnvOrDefault("RECONCILE_GC", "false") != "true" {
+		return item
+	}
+	grace := time.Duration(envInt64("RECONCILE_GC_GRACE_MINUTES", 1440)) * time.Minute
+	if time.Since(seen) < grace {
+		return item
+	}
+
+	if err := s.deleteOrphan(namespace, kind, name); err != nil && !apierrors.IsNotFound(err) {
+		item.Action = model.DriftActionFailed
+		item.Message = err.Error()
+		return item
+	}
+	item.Action = model.DriftActionCollected
+	config.RedisClient.HDel(s.ctx, orphanFirstSeenKey, field)
+	log.Printf("已回收孤儿资源 - %s", field)
+	return item
+}
+
+func (s *ReconcileService) deleteOrphan(namespace, kind, name string) error {
+	suffix := suffixOfName(name)
+	kbParam := &model.KubernetesParam{
+		Namespace:  namespace,
+		Deployment: fmt.Sprintf("deployment-%s", suffix),
+		Svc:        fmt.Sprintf("svc-%s", suffix),
+		Pvc:        name,
+		Secret:     fmt.Sprintf("secret-%s", suffix),
+	}
+
+	kubernetesUtil := util.NewKubernetesUtil(s.ctx)
+	switch kind {
+	case util.KindDeployment:
+		return kubernetesUtil.DeleteDeployment(kbParam)
+	case util.KindService:
+		// 同时删除 Ingress 或 HTTPRoute
+		return util.NewExposer(s.ctx).Unexpose(kbParam)
+	case util.KindPvc:
+		return kubernetesUtil.DeletePvc(kbParam)
+	case util.KindSecret:
+		return kubernetesUtil.DeleteSecret(kbParam)
+	}
+	return nil
+}
+
+// busyWorkspaces 正在创建或恢复快照的工作空间，资源暂时不完整，对账时跳过，键为 <namespace>/<suffix>
+func (s *ReconcileService) busyWorkspaces() (map[string]bool, error) {
+	busy := make(map[string]bool)
+
+	var provisions []*model.Provision
+	err := config.DB.WithContext(s.ctx).
+		Where("state NOT IN ?", []string{model.ProvisionReady, model.ProvisionFailed}).
+		Find(&provisions).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询创建记录失败: %w", err)
+	}
+	for _, provision := range provisions {
+		busy[provision.Namespace+"/"+suffixOfName(provision.Deployment)] = true
+	}
+
+	var snapshots []*model.WorkspaceSnapshot
+	err = config.DB.WithContext(s.ctx).Where("restore_state = ?", model.RestoreRunning).Find(&snapshots).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询快照记录失败: %w", err)
+	}
+	for _, snapshot := range snapshots {
+		busy[snapshot.Namespace+"/"+suffixOfName(snapshot.Deployment)] = true
+	}
+	return busy, nil
+}
+
+func (s *ReconcileService) orphanFirstSeen() (map[string]time.Time, error) {
+	values, err := config.RedisClient.HGetAll(s.ctx, orphanFirstSeenKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取孤儿资源记录失败: %w", err)
+	}
+
+	firstSeen := make(map[string]time.Time, len(values))
+	for field, value := range values {
+		if seen, err := time.Parse(time.RFC3339, value); err == nil {
+			firstSeen[field] = seen
+		}
+	}
+	return firstSeen, nil
+}
+
+// suffixOfName 资源名称的后 8 位，同一个工作空间的各个资源共用这一后缀
+func suffixOfName(name string) string {
+	if len(name) < 8 {
+		return name
+	}
+	return name[len(name)-8:]
+}
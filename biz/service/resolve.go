@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"learn/biz/config"
+	"learn/biz/model"
+)
+
+// ErrAppNotFound 应用不存在或不属于当前用户，两种情况返回同一个错误，避免暴露其他用户的应用
+// 快照和启停计划同理
+var (
+	ErrAppNotFound      = errors.New("应用不存在")
+	ErrSnapshotNotFound = errors.New("快照不存在")
+	ErrScheduleNotFound = errors.New("计划不存在")
+)
+
+// ResolveApp 按 ID 或 Deployment 查询属于当前用户的应用，两者都传时以 ID 为准
+// 所有针对单个应用的操作都需要先经过这里，之后的 Kubernetes 资源名称一律从查到的记录推导，不使用客户端传入的名称
+func (s *AppService) ResolveApp(id uint, deployment string) (*model.Application, error) {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return nil, errors.New("没有找到用户ID")
+	}
+	if id == 0 && deployment == "" {
+		return nil, ErrAppNotFound
+	}
+
+	query := config.DB.WithContext(s.ctx).Where("user_id = ?", userId)
+	if id != 0 {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("deployment = ?", deployment)
+	}
+
+	var application model.Application
+	err := query.First(&application).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAppNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &application, nil
+}
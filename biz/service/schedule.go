@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+
+	"learn/biz/config"
+	"learn/biz/model"
+)
+
+// ScheduleRegistry 负责把启停计划挂到运行中的 cron 调度器上，由定时任务在启动时注入
+type ScheduleRegistry interface {
+	Register(schedule *model.AppSchedule) error
+	Unregister(scheduleId uint)
+}
+
+var Schedules ScheduleRegistry
+
+// scheduleParser 与 TimerService 的 cron.WithSeconds 保持一致
+var scheduleParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+type ScheduleService struct {
+	ctx context.Context
+	c   *app.RequestContext
+}
+
+func NewScheduleService(ctx context.Context, c *app.RequestContext) *ScheduleService {
+	return &ScheduleService{ctx: ctx, c: c}
+}
+
+func (s *ScheduleService) CreateSchedule(schedule *model.AppSchedule) (uint, error) {
+	if schedule.Action != model.ScheduleActionStart && schedule.Action != model.ScheduleActionStop {
+		return 0, fmt.Errorf("不支持的操作: %s", schedule.Action)
+	}
+	if _, err := scheduleParser.Parse(schedule.Cron); err != nil {
+		return 0, fmt.Errorf("cron 表达式错误: %w", err)
+	}
+
+	application, err := NewAppService(s.ctx, s.c).ResolveApp(0, schedule.Deployment)
+	if err != nil {
+		return 0, err
+	}
+
+	record := &model.AppSchedule{
+		UserId:        application.UserId,
+		ApplicationId: application.ID,
+		Deployment:    application.Deployment,
+		Action:        schedule.Action,
+		Cron:          schedule.Cron,
+	}
+	if err := config.DB.WithContext(s.ctx).Create(record).Error; err != nil {
+		return 0, err
+	}
+
+	if Schedules != nil {
+		if err := Schedules.Register(record); err != nil {
+			config.DB.WithContext(s.ctx).Unscoped().Delete(record)
+			return 0, err
+		}
+	}
+
+	return record.ID, nil
+}
+
+// ListSchedule 列出当前用户的启停计划，deployment 为空时返回全部
+func (s *ScheduleService) ListSchedule(deployment string) ([]*model.AppSchedule, error) {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return nil, errors.New("没有找到用户ID")
+	}
+
+	query := config.DB.WithContext(s.ctx).Where("user_id = ?", userId)
+	if deployment != "" {
+		// 指定的应用不属于当前用户时与其他接口一样返回应用不存在，而不是空列表
+		if _, err := NewAppService(s.ctx, s.c).ResolveApp(0, deployment); err != nil {
+			return nil, err
+		}
+		query = query.Where("deployment = ?", deployment)
+	}
+
+	var schedules []*model.AppSchedule
+	if err := query.Order("id").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (s *ScheduleService) DeleteSchedule(id uint) error {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return errors.New("没有找到用户ID")
+	}
+
+	result := config.DB.WithContext(s.ctx).Delete(&model.AppSchedule{}, "id = ? AND user_id = ?", id, userId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrScheduleNotFound
+	}
+
+	if Schedules != nil {
+		Schedules.Unregister(id)
+	}
+	return nil
+}
+
+// deleteByApplication 删除应用时一并清理它的启停计划
+func (s *ScheduleService) deleteByApplication(applicationId uint) error {
+	var schedules []*model.AppSchedule
+	if err := config.DB.WithContext(s.ctx).Where("application_id = ?", applicationId).Find(&schedules).Error; err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		if Schedules != nil {
+			Schedules.Unregister(schedule.ID)
+		}
+	}
+	return config.DB.WithContext(s.ctx).Delete(&model.AppSchedule{}, "application_id = ?", applicationId).Error
+}
+
+// ParseSchedule 解析计划中的 cron 表达式
+func ParseSchedule(schedule *model.AppSchedule) (cron.Schedule, error) {
+	return scheduleParser.Parse(schedule.Cron)
+}
+
+// RunSchedule 执行一次启停计划，执行前重新读取计划与应用，避免使用已删除的数据
+func RunSchedule(ctx context.Context, scheduleId uint) {
+	var schedule model.AppSchedule
+	err := config.DB.WithContext(ctx).First(&schedule, scheduleId).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if Schedules != nil {
+			Schedules.Unregister(scheduleId)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("读取启停计划失败 - ID: %d, Error: %v", scheduleId, err)
+		return
+	}
+
+	var application model.Application
+	err = config.DB.WithContext(ctx).First(&application, schedule.ApplicationId).Error
+	if err != nil {
+		log.Printf("读取计划对应的应用失败 - ID: %d, Error: %v", scheduleId, err)
+		return
+	}
+
+	log.Printf("执行启停计划 - ID: %d, Deployment: %s, Action: %s", schedule.ID, application.Deployment, schedule.Action)
+
+	switch schedule.Action {
+	case model.ScheduleActionStart:
+		err = StartWorkspace(ctx, &application)
+	case model.ScheduleActionStop:
+		err = StopWorkspace(ctx, KubernetesParamOf(&application))
+	}
+	if err != nil {
+		log.Printf("执行启停计划失败 - ID: %d, Error: %v", scheduleId, err)
+	}
+}
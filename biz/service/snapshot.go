@@ -0,0 +1,429 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+type SnapshotService struct {
+	ctx            context.Context
+	c              *app.RequestContext
+	dynamicClient  dynamic.Interface
+	snapshots      *util.SnapshotUtil
+	kubernetesUtil *util.KubernetesUtil
+	backend        util.WorkspaceBackend
+}
+
+func NewSnapshotService(ctx context.Context, c *app.RequestContext) *SnapshotService {
+	return &SnapshotService{
+		ctx:            ctx,
+		c:              c,
+		dynamicClient:  util.DynamicClient,
+		snapshots:      util.NewSnapshotUtil(ctx, util.DynamicClient),
+		kubernetesUtil: util.NewKubernetesUtil(ctx),
+		backend:        util.NewWorkspaceBackend(ctx),
+	}
+}
+
+// NewSnapshotServiceWithClient 使用指定的 Kubernetes 客户端和动态客户端，测试时可传入 client-go 的 fake 客户端
+func NewSnapshotServiceWithClient(ctx context.Context, c *app.RequestContext, client kubernetes.Interface, dynamicClient dynamic.Interface) *SnapshotService {
+	return &SnapshotService{
+		ctx:            ctx,
+		c:              c,
+		dynamicClient:  dynamicClient,
+		snapshots:      util.NewSnapshotUtil(ctx, dynamicClient),
+		kubernetesUtil: util.NewKubernetesUtilWithClient(ctx, client),
+		backend:        util.NewKubernetesBackend(ctx, client),
+	}
+}
+
+// withContext 返回使用指定 context 的副本，供请求结束后继续执行的恢复流程使用
+func (s *SnapshotService) withContext(ctx context.Context) *SnapshotService {
+	return &SnapshotService{
+		ctx:            ctx,
+		dynamicClient:  s.dynamicClient,
+		snapshots:      util.NewSnapshotUtil(ctx, s.dynamicClient),
+		kubernetesUtil: s.kubernetesUtil.WithContext(ctx),
+		backend:        s.backend.WithContext(ctx),
+	}
+}
+
+func (s *SnapshotService) CreateSnapshot(param *model.WorkspaceSnapshot) (*model.WorkspaceSnapshot, error) {
+	application, err := NewAppService(s.ctx, s.c).ResolveApp(0, param.Deployment)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireCluster(); err != nil {
+		return nil, err
+	}
+	kbParam := KubernetesParamOf(application)
+
+	record := &model.WorkspaceSnapshot{
+		UserId:        application.UserId,
+		ApplicationId: application.ID,
+		Deployment:    application.Deployment,
+		Name:          fmt.Sprintf("snap-%s-%s", kbParam.Pvc[len(kbParam.Pvc)-8:], uuid.NewString()[:8]),
+		Description:   param.Description,
+		Namespace:     kbParam.Namespace,
+		SourcePvc:     kbParam.Pvc,
+		State:         model.SnapshotPending,
+	}
+
+	snapshotClass := util.GetEnvOrDefault("SNAPSHOT_CLASS", "")
+	if err := s.snapshots.CreateSnapshot(record.Namespace, record.Name, record.SourcePvc, snapshotClass); err != nil {
+		return nil, err
+	}
+
+	if err := config.DB.WithContext(s.ctx).Create(record).Error; err != nil {
+		_ = s.snapshots.DeleteSnapshot(record.Namespace, record.Name)
+		return nil, err
+	}
+	return record, nil
+}
+
+// ListSnapshot 列出当前用户的快照，顺带刷新尚未就绪快照的状态
+func (s *SnapshotService) ListSnapshot(deployment string) ([]*model.WorkspaceSnapshot, error) {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return nil, errors.New("没有找到用户ID")
+	}
+
+	query := config.DB.WithContext(s.ctx).Where("user_id = ?", userId)
+	if deployment != "" {
+		// 指定的应用不属于当前用户时与其他接口一样返回应用不存在，而不是空列表
+		if _, err := NewAppService(s.ctx, s.c).ResolveApp(0, deployment); err != nil {
+			return nil, err
+		}
+		query = query.Where("deployment = ?", deployment)
+	}
+
+	var records []*model.WorkspaceSnapshot
+	if err := query.Order("id desc").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if record.State == model.SnapshotPending {
+			s.refresh(record)
+		}
+	}
+	return records, nil
+}
+
+func (s *SnapshotService) DeleteSnapshot(id uint) error {
+	record, err := s.getOwnedSnapshot(id)
+	if err != nil {
+		return err
+	}
+	if err := requireCluster(); err != nil {
+		return err
+	}
+	if record.RestoreState == model.RestoreRunning {
+		return errors.New("快照正在恢复中，无法删除")
+	}
+
+	if err := s.snapshots.DeleteSnapshot(record.Namespace, record.Name); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return config.DB.WithContext(s.ctx).Delete(record).Error
+}
+
+// RestoreSnapshot 用快照重建工作空间的数据卷，恢复在后台进行，进度见快照的 restore_state
+func (s *SnapshotService) RestoreSnapshot(id uint) error {
+	record, err := s.getOwnedSnapshot(id)
+	if err != nil {
+		return err
+	}
+	if err := requireCluster(); err != nil {
+		return err
+	}
+
+	s.refresh(record)
+	if record.State != model.SnapshotReady {
+		return errors.New("快照尚未就绪，无法恢复")
+	}
+
+	// 锁住应用记录后检查并标记恢复状态，同一应用的多个恢复请求（无论是否为同一快照）只有一个能通过
+	var application model.Application
+	err = config.DB.WithContext(s.ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&application, record.ApplicationId).Error
+		if err != nil {
+			return err
+		}
+
+		var restoring int64
+		err = tx.Model(&model.WorkspaceSnapshot{}).
+			Where("application_id = ? AND restore_state = ?", record.ApplicationId, model.RestoreRunning).
+			Count(&restoring).Error
+		if err != nil {
+			return err
+		}
+		if restoring > 0 {
+			return errors.New("工作空间正在恢复快照，请稍后再试")
+		}
+
+		result := tx.Model(&model.WorkspaceSnapshot{}).
+			Where("id = ? AND (restore_state IS NULL OR restore_state <> ?)", record.ID, model.RestoreRunning).
+			Updates(map[string]interface{}{"restore_state": model.RestoreRunning, "error": ""})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("快照正在恢复中")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	go s.withContext(context.Background()).restore(record, &application)
+	return nil
+}
+
+// restore 从快照创建新的 PVC，停止工作空间后把 Deployment 切换到新 PVC，新 PVC 可用后才删除原 PVC
+// 任何一步失败时都切回原 PVC，原来在运行的工作空间会重新启动
+func (s *SnapshotService) restore(record *model.WorkspaceSnapshot, application *model.Application) {
+	kbParam := KubernetesParamOf(application)
+
+	fail := func(err error) {
+		log.Printf("恢复快照失败 - Snapshot: %s, Error: %v", record.Name, err)
+		config.DB.WithContext(s.ctx).Model(record).Updates(map[string]interface{}{
+			"restore_state": model.RestoreFailed,
+			"error":         err.Error(),
+		})
+	}
+
+	deployment, err := s.kubernetesUtil.GetDeployment(kbParam)
+	if err != nil {
+		fail(err)
+		return
+	}
+	running := deployment.Spec.Replicas != nil && *deployment.Spec.Replicas > 0
+
+	// 新 PVC 的容量不能小于快照的恢复容量，也不应小于原来的容量
+	size, err := resource.ParseQuantity(record.RestoreSize)
+	if err != nil {
+		size = resource.MustParse(util.DefaultWorkspaceStorage)
+	}
+	if pvc, err := s.kubernetesUtil.GetPvc(kbParam); err == nil {
+		if current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; current.Cmp(size) > 0 {
+			size = current
+		}
+	}
+
+	// 新 PVC 以时间戳区分，名称仍以应用的后缀结尾
+	restored := *kbParam
+	restored.Pvc = fmt.Sprintf("pvc-%d-%s", time.Now().Unix(), suffixOfName(application.Deployment))
+	apiGroup := "snapshot.storage.k8s.io"
+	err = s.kubernetesUtil.CreatePvcFromSource(&restored, size.String(), &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     record.Name,
+	})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	// rollback 切回原 PVC 并删除新 PVC，原来在运行的工作空间重新启动
+	switched := false
+	rollback := func(cause error) {
+		if switched {
+			if running {
+				if err := stopWorkspace(s.ctx, s.backend, kbParam); err != nil {
+					log.Printf("停止工作空间失败 - Deployment: %s, Error: %v", application.Deployment, err)
+				}
+			}
+			if err := s.switchPvc(application, kbParam.Pvc); err != nil {
+				// 无法切回时保留新 PVC，由管理员处理
+				fail(fmt.Errorf("%v，且切回原 PVC 失败: %w", cause, err))
+				return
+			}
+		}
+		if err := s.kubernetesUtil.DeletePvc(&restored); err != nil && !apierrors.IsNotFound(err) {
+			log.Printf("删除新 PVC 失败 - PVC: %s, Error: %v", restored.Pvc, err)
+		}
+		if running {
+			if err := startWorkspace(s.ctx, s.backend, application); err != nil {
+				log.Printf("重新启动工作空间失败 - Deployment: %s, Error: %v", application.Deployment, err)
+			}
+		}
+		fail(cause)
+	}
+
+	if running {
+		if err := stopWorkspace(s.ctx, s.backend, kbParam); err != nil {
+			rollback(err)
+			return
+		}
+	}
+	if err := s.switchPvc(application, restored.Pvc); err != nil {
+		rollback(err)
+		return
+	}
+	switched = true
+	if running {
+		err := startWorkspace(s.ctx, s.backend, application)
+		if err == nil {
+			// 使用 WaitForFirstConsumer 的存储类要等新 Pod 调度后才开始恢复数据
+			err = s.kubernetesUtil.WaitPvcBound(&restored, 10*time.Minute)
+		}
+		if err != nil {
+			rollback(err)
+			return
+		}
+	}
+
+	if !running {
+		// 已停止的工作空间要到下次启动时新 PVC 才会恢复数据，存储类为 WaitForFirstConsumer 或恢复出错时新 PVC 可能一直无法绑定，
+		// 原 PVC 在此之前保留，由对账在新 PVC 绑定后删除
+		config.DB.WithContext(s.ctx).Model(record).Updates(map[string]interface{}{
+			"restore_state": model.RestoreFinished,
+			"replaced_pvc":  kbParam.Pvc,
+		})
+		log.Printf("快照恢复完成，原 PVC 在新 PVC 绑定后删除 - Snapshot: %s, Deployment: %s, PVC: %s", record.Name, application.Deployment, restored.Pvc)
+		return
+	}
+
+	// 运行中的工作空间已经在新 PVC 上启动，原 PVC 不再被引用
+	if err := s.kubernetesUtil.DeletePvc(kbParam); err != nil && !apierrors.IsNotFound(err) {
+		// 删除失败的原 PVC 会在对账时作为孤儿资源报告
+		log.Printf("删除原 PVC 失败 - PVC: %s, Error: %v", kbParam.Pvc, err)
+	}
+
+	config.DB.WithContext(s.ctx).Model(record).Update("restore_state", model.RestoreFinished)
+	log.Printf("快照恢复完成 - Snapshot: %s, Deployment: %s, PVC: %s", record.Name, application.Deployment, restored.Pvc)
+}
+
+// CollectReplacedPvcs 删除恢复快照时保留的原 PVC，应用当前的 PVC 已绑定后才删除
+// 返回仍需保留的原 PVC（<namespace>/<name>），对账时不作为孤儿资源；dryRun 为 true 时只返回不删除
+func (s *SnapshotService) CollectReplacedPvcs(dryRun bool) (map[string]bool, error) {
+	var records []*model.WorkspaceSnapshot
+	err := config.DB.WithContext(s.ctx).
+		Where("replaced_pvc <> '' AND restore_state <> ?", model.RestoreRunning).
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询快照记录失败: %w", err)
+	}
+
+	retained := make(map[string]bool)
+	forget := func(record *model.WorkspaceSnapshot) {
+		config.DB.WithContext(s.ctx).Model(record).Update("replaced_pvc", "")
+	}
+	for _, record := range records {
+		replaced := &model.KubernetesParam{Namespace: record.Namespace, Pvc: record.ReplacedPvc}
+
+		var application model.Application
+		if err := config.DB.WithContext(s.ctx).First(&application, record.ApplicationId).Error; err != nil {
+			continue
+		}
+		current := KubernetesParamOf(&application)
+		if current.Pvc == record.ReplacedPvc {
+			if !dryRun {
+				forget(record)
+			}
+			continue
+		}
+
+		pvc, err := s.kubernetesUtil.GetPvc(current)
+		if err != nil || pvc.Status.Phase != corev1.ClaimBound || dryRun {
+			retained[record.Namespace+"/"+record.ReplacedPvc] = true
+			continue
+		}
+		if err := s.kubernetesUtil.DeletePvc(replaced); err != nil && !apierrors.IsNotFound(err) {
+			log.Printf("删除原 PVC 失败 - PVC: %s, Error: %v", record.ReplacedPvc, err)
+			retained[record.Namespace+"/"+record.ReplacedPvc] = true
+			continue
+		}
+		forget(record)
+		log.Printf("新 PVC 已绑定，删除恢复前的原 PVC - Deployment: %s, PVC: %s", application.Deployment, record.ReplacedPvc)
+	}
+	return retained, nil
+}
+
+// switchPvc 把工作空间的 Deployment 切换到指定的 PVC，并记录到应用中
+func (s *SnapshotService) switchPvc(application *model.Application, pvc string) error {
+	if err := s.kubernetesUtil.SetDeploymentPvc(KubernetesParamOf(application), pvc); err != nil {
+		return err
+	}
+	application.Pvc = pvc
+	return config.DB.WithContext(s.ctx).Model(application).Update("pvc", pvc).Error
+}
+
+// refresh 从集群同步快照状态
+func (s *SnapshotService) refresh(record *model.WorkspaceSnapshot) {
+	status, err := s.snapshots.GetSnapshotStatus(record.Namespace, record.Name)
+	if err != nil {
+		log.Printf("获取快照状态失败 - Snapshot: %s, Error: %v", record.Name, err)
+		return
+	}
+
+	switch {
+	case status.ReadyToUse:
+		record.State = model.SnapshotReady
+	case status.Error != "":
+		record.State = model.SnapshotFailed
+		record.Error = status.Error
+	default:
+		return
+	}
+	record.RestoreSize = status.RestoreSize
+
+	err = config.DB.WithContext(s.ctx).Model(record).Updates(map[string]interface{}{
+		"state":        record.State,
+		"restore_size": record.RestoreSize,
+		"error":        record.Error,
+	}).Error
+	if err != nil {
+		log.Printf("更新快照状态失败 - Snapshot: %s, Error: %v", record.Name, err)
+	}
+}
+
+func (s *SnapshotService) getOwnedSnapshot(id uint) (*model.WorkspaceSnapshot, error) {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return nil, errors.New("没有找到用户ID")
+	}
+
+	var record model.WorkspaceSnapshot
+	err := config.DB.WithContext(s.ctx).Where("id = ? AND user_id = ?", id, userId).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// deleteByApplication 删除应用时一并清理它的快照
+func (s *SnapshotService) deleteByApplication(applicationId uint) error {
+	var records []*model.WorkspaceSnapshot
+	if err := config.DB.WithContext(s.ctx).Where("application_id = ?", applicationId).Find(&records).Error; err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := s.snapshots.DeleteSnapshot(record.Namespace, record.Name); err != nil && !apierrors.IsNotFound(err) {
+			log.Printf("删除快照失败 - Snapshot: %s, Error: %v", record.Name, err)
+		}
+	}
+	return config.DB.WithContext(s.ctx).Delete(&model.WorkspaceSnapshot{}, "application_id = ?", applicationId).Error
+}
@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/testutil"
+)
+
+var testSnapshotGVR = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+
+func newFakeDynamicClient() *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{testSnapshotGVR: "VolumeSnapshotList"})
+}
+
+// newWorkspaceObjects 工作空间已有的 Deployment 和数据卷
+func newWorkspaceObjects(application *model.Application, replicas int32) []runtime.Object {
+	kbParam := KubernetesParamOf(application)
+	return []runtime.Object{
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: kbParam.Deployment, Namespace: kbParam.Namespace},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "code-server"}},
+					Volumes: []corev1.Volume{{
+						Name: "data",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: kbParam.Pvc},
+						},
+					}},
+				}},
+			},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: kbParam.Pvc, Namespace: kbParam.Namespace},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("20Gi")},
+				},
+			},
+		},
+	}
+}
+
+// markSnapshotReady 模拟快照控制器完成快照
+func markSnapshotReady(t *testing.T, client *dynamicfake.FakeDynamicClient, namespace, name, restoreSize string) {
+	t.Helper()
+	snapshots := client.Resource(testSnapshotGVR).Namespace(namespace)
+	snapshot, err := snapshots.Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("获取快照失败: %v", err)
+	}
+	_ = unstructured.SetNestedField(snapshot.Object, true, "status", "readyToUse")
+	_ = unstructured.SetNestedField(snapshot.Object, restoreSize, "status", "restoreSize")
+	if _, err := snapshots.Update(context.Background(), snapshot, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("更新快照状态失败: %v", err)
+	}
+}
+
+func TestCreateSnapshotAndRefresh(t *testing.T) {
+	testutil.SetupDB(t)
+	application := testutil.CreateApp(t, 1, "deployment-abcd1234")
+	dynamicClient := newFakeDynamicClient()
+	service := NewSnapshotServiceWithClient(context.Background(), testutil.NewContext(1), fake.NewSimpleClientset(), dynamicClient)
+
+	record, err := service.CreateSnapshot(&model.WorkspaceSnapshot{Deployment: application.Deployment})
+	if err != nil {
+		t.Fatalf("CreateSnapshot 失败: %v", err)
+	}
+	if record.SourcePvc != "pvc-abcd1234" || record.State != model.SnapshotPending {
+		t.Fatalf("快照记录错误: %+v", record)
+	}
+
+	snapshot, err := dynamicClient.Resource(testSnapshotGVR).Namespace("ns-1").Get(context.Background(), record.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("集群中没有创建 VolumeSnapshot: %v", err)
+	}
+	source, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+	if source != "pvc-abcd1234" {
+		t.Fatalf("快照的源 PVC 为 %q", source)
+	}
+
+	markSnapshotReady(t, dynamicClient, "ns-1", record.Name, "10Gi")
+	records, err := service.ListSnapshot(application.Deployment)
+	if err != nil {
+		t.Fatalf("ListSnapshot 失败: %v", err)
+	}
+	if len(records) != 1 || records[0].State != model.SnapshotReady || records[0].RestoreSize != "10Gi" {
+		t.Fatalf("快照状态没有刷新: %+v", records[0])
+	}
+}
+
+func TestCreateSnapshotUsesRestoredPvc(t *testing.T) {
+	testutil.SetupDB(t)
+	application := testutil.CreateApp(t, 1, "deployment-abcd1234")
+	config.DB.Model(application).Update("pvc", "pvc-1700000000-abcd1234")
+	service := NewSnapshotServiceWithClient(context.Background(), testutil.NewContext(1), fake.NewSimpleClientset(), newFakeDynamicClient())
+
+	record, err := service.CreateSnapshot(&model.WorkspaceSnapshot{Deployment: application.Deployment})
+	if err != nil {
+		t.Fatalf("CreateSnapshot 失败: %v", err)
+	}
+	if record.SourcePvc != "pvc-1700000000-abcd1234" {
+		t.Fatalf("快照的源 PVC 为 %q", record.SourcePvc)
+	}
+}
+
+func TestCreateSnapshotOfOtherUsersApp(t *testing.T) {
+	testutil.SetupDB(t)
+	testutil.CreateApp(t, 2, "deployment-abcd1234")
+	dynamicClient := newFakeDynamicClient()
+	service := NewSnapshotServiceWithClient(context.Background(), testutil.NewContext(1), fake.NewSimpleClientset(), dynamicClient)
+
+	if _, err := service.CreateSnapshot(&model.WorkspaceSnapshot{Deployment: "deployment-abcd1234"}); err == nil {
+		t.Fatal("为其他用户的应用创建快照应当失败")
+	}
+	list, err := dynamicClient.Resource(testSnapshotGVR).Namespace("ns-2").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("列出快照失败: %v", err)
+	}
+	if len(list.Items) != 0 {
+		t.Fatalf("不应创建 VolumeSnapshot，实际有 %d 个", len(list.Items))
+	}
+}
+
+func TestDeleteSnapshot(t *testing.T) {
+	testutil.SetupDB(t)
+	application := testutil.CreateApp(t, 1, "deployment-abcd1234")
+	dynamicClient := newFakeDynamicClient()
+	service := NewSnapshotServiceWithClient(context.Background(), testutil.NewContext(1), fake.NewSimpleClientset(), dynamicClient)
+
+	record, err := service.CreateSnapshot(&model.WorkspaceSnapshot{Deployment: application.Deployment})
+	if err != nil {
+		t.Fatalf("CreateSnapshot 失败: %v", err)
+	}
+
+	config.DB.Model(record).Update("restore_state", model.RestoreRunning)
+	if err := service.DeleteSnapshot(record.ID); err == nil {
+		t.Fatal("恢复中的快照不应被删除")
+	}
+
+	config.DB.Model(record).Update("restore_state", model.RestoreFinished)
+	if err := service.DeleteSnapshot(record.ID); err != nil {
+		t.Fatalf("DeleteSnapshot 失败: %v", err)
+	}
+	_, err = dynamicClient.Resource(testSnapshotGVR).Namespace("ns-1").Get(context.Background(), record.Name, metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("VolumeSnapshot 没有被删除: %v", err)
+	}
+	var count int64
+	config.DB.Model(&model.WorkspaceSnapshot{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("快照记录没有被删除，剩余 %d 条", count)
+	}
+}
+
+// newRestoreFixture 准备一个已就绪、处于恢复中状态的快照
+func newRestoreFixture(t *testing.T, replicas int32) (*fake.Clientset, *SnapshotService, *model.Application, *model.WorkspaceSnapshot) {
+	t.Helper()
+	testutil.SetupDB(t)
+	testutil.SetupRedis(t)
+	application := testutil.CreateApp(t, 1, "deployment-abcd1234")
+
+	client := fake.NewSimpleClientset(newWorkspaceObjects(application, replicas)...)
+	service := NewSnapshotServiceWithClient(context.Background(), testutil.NewContext(1), client, newFakeDynamicClient())
+
+	record := &model.WorkspaceSnapshot{
+		UserId:        application.UserId,
+		ApplicationId: application.ID,
+		Deployment:    application.Deployment,
+		Name:          "snap-abcd1234-00000000",
+		Namespace:     "ns-1",
+		SourcePvc:     "pvc-abcd1234",
+		State:         model.SnapshotReady,
+		RestoreSize:   "10Gi",
+		RestoreState:  model.RestoreRunning,
+	}
+	if err := config.DB.Create(record).Error; err != nil {
+		t.Fatalf("写入快照记录失败: %v", err)
+	}
+	return client, service, application, record
+}
+
+func claimOf(t *testing.T, client *fake.Clientset, application *model.Application) (string, int32) {
+	t.Helper()
+	deployment, err := client.AppsV1().Deployments("ns-1").Get(context.Background(), application.Deployment, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("获取 Deployment 失败: %v", err)
+	}
+	return deployment.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName, *deployment.Spec.Replicas
+}
+
+func restoreStateOf(t *testing.T, record *model.WorkspaceSnapshot) string {
+	t.Helper()
+	var saved model.WorkspaceSnapshot
+	if err := config.DB.First(&saved, record.ID).Error; err != nil {
+		t.Fatalf("读取快照记录失败: %v", err)
+	}
+	return saved.RestoreState
+}
+
+func TestRestoreSnapshotStopped(t *testing.T) {
+	client, service, application, record := newRestoreFixture(t, 0)
+
+	service.restore(record, application)
+
+	if state := restoreStateOf(t, record); state != model.RestoreFinished {
+		t.Fatalf("恢复状态为 %q", state)
+	}
+
+	var saved model.Application
+	config.DB.First(&saved, application.ID)
+	if saved.Pvc == "" || saved.Pvc == "pvc-abcd1234" {
+		t.Fatalf("应用没有记录新的 PVC: %q", saved.Pvc)
+	}
+	claim, replicas := claimOf(t, client, application)
+	if claim != saved.Pvc || replicas != 0 {
+		t.Fatalf("Deployment 使用 %q，副本数 %d", claim, replicas)
+	}
+
+	pvc, err := client.CoreV1().PersistentVolumeClaims("ns-1").Get(context.Background(), saved.Pvc, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("没有创建新的 PVC: %v", err)
+	}
+	if pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Name != record.Name {
+		t.Fatalf("新 PVC 的数据源错误: %+v", pvc.Spec.DataSource)
+	}
+	// 容量取快照恢复容量和原容量中较大的一个
+	if size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; size.String() != "20Gi" {
+		t.Fatalf("新 PVC 的容量为 %s", size.String())
+	}
+
+	// 新 PVC 绑定之前保留原 PVC，对账时不回收
+	if saved := replacedPvcOf(t, record); saved != "pvc-abcd1234" {
+		t.Fatalf("没有记录保留的原 PVC: %q", saved)
+	}
+	retained, err := service.CollectReplacedPvcs(false)
+	if err != nil {
+		t.Fatalf("回收原 PVC 失败: %v", err)
+	}
+	if !retained["ns-1/pvc-abcd1234"] {
+		t.Fatalf("新 PVC 未绑定时原 PVC 应当保留: %v", retained)
+	}
+	if _, err := client.CoreV1().PersistentVolumeClaims("ns-1").Get(context.Background(), "pvc-abcd1234", metav1.GetOptions{}); err != nil {
+		t.Fatalf("新 PVC 未绑定时原 PVC 被删除: %v", err)
+	}
+
+	// 下次启动后新 PVC 绑定，原 PVC 被回收
+	pvc.Status.Phase = corev1.ClaimBound
+	if _, err := client.CoreV1().PersistentVolumeClaims("ns-1").UpdateStatus(context.Background(), pvc, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("更新 PVC 状态失败: %v", err)
+	}
+	if retained, err = service.CollectReplacedPvcs(false); err != nil || len(retained) != 0 {
+		t.Fatalf("回收原 PVC 返回 %v, %v", retained, err)
+	}
+	_, err = client.CoreV1().PersistentVolumeClaims("ns-1").Get(context.Background(), "pvc-abcd1234", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("原 PVC 没有被删除: %v", err)
+	}
+	if saved := replacedPvcOf(t, record); saved != "" {
+		t.Fatalf("回收后仍记录原 PVC: %q", saved)
+	}
+}
+
+func replacedPvcOf(t *testing.T, record *model.WorkspaceSnapshot) string {
+	t.Helper()
+	var saved model.WorkspaceSnapshot
+	if err := config.DB.First(&saved, record.ID).Error; err != nil {
+		t.Fatalf("读取快照记录失败: %v", err)
+	}
+	return saved.ReplacedPvc
+}
+
+func TestRestoreSnapshotRejectsConcurrentRestore(t *testing.T) {
+	_, service, application, running := newRestoreFixture(t, 0)
+	other := &model.WorkspaceSnapshot{
+		UserId:        application.UserId,
+		ApplicationId: application.ID,
+		Deployment:    application.Deployment,
+		Name:          "snap-abcd1234-11111111",
+		Namespace:     "ns-1",
+		SourcePvc:     "pvc-abcd1234",
+		State:         model.SnapshotReady,
+	}
+	if err := config.DB.Create(other).Error; err != nil {
+		t.Fatalf("写入快照记录失败: %v", err)
+	}
+
+	// 同一快照和同一应用的其他快照都不能在恢复进行时再次恢复
+	if err := service.RestoreSnapshot(running.ID); err == nil || !strings.Contains(err.Error(), "正在恢复") {
+		t.Fatalf("恢复中的快照不应再次恢复: %v", err)
+	}
+	if err := service.RestoreSnapshot(other.ID); err == nil || !strings.Contains(err.Error(), "正在恢复") {
+		t.Fatalf("应用正在恢复快照时不应恢复其他快照: %v", err)
+	}
+	if state := restoreStateOf(t, other); state != "" {
+		t.Fatalf("被拒绝的快照恢复状态为 %q", state)
+	}
+}
+
+func TestRestoreSnapshotRunning(t *testing.T) {
+	client, service, application, record := newRestoreFixture(t, 1)
+	// 模拟存储插件立即完成恢复
+	client.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pvc := action.(k8stesting.CreateAction).GetObject().(*corev1.PersistentVolumeClaim)
+		pvc.Status.Phase = corev1.ClaimBound
+		return false, nil, nil
+	})
+
+	service.restore(record, application)
+
+	if state := restoreStateOf(t, record); state != model.RestoreFinished {
+		t.Fatalf("恢复状态为 %q", state)
+	}
+	claim, replicas := claimOf(t, client, application)
+	if claim == "pvc-abcd1234" || replicas != 1 {
+		t.Fatalf("Deployment 使用 %q，副本数 %d", claim, replicas)
+	}
+	_, err := client.CoreV1().PersistentVolumeClaims("ns-1").Get(context.Background(), "pvc-abcd1234", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("原 PVC 没有被删除: %v", err)
+	}
+}
+
+func TestRestoreSnapshotKeepsOriginalPvcOnFailure(t *testing.T) {
+	client, service, application, record := newRestoreFixture(t, 1)
+	client.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("快照不可用")
+	})
+
+	service.restore(record, application)
+
+	if state := restoreStateOf(t, record); state != model.RestoreFailed {
+		t.Fatalf("恢复状态为 %q", state)
+	}
+	claim, replicas := claimOf(t, client, application)
+	if claim != "pvc-abcd1234" || replicas != 1 {
+		t.Fatalf("Deployment 使用 %q，副本数 %d", claim, replicas)
+	}
+	if _, err := client.CoreV1().PersistentVolumeClaims("ns-1").Get(context.Background(), "pvc-abcd1234", metav1.GetOptions{}); err != nil {
+		t.Fatalf("原 PVC 不应被删除: %v", err)
+	}
+	var saved model.Application
+	config.DB.First(&saved, application.ID)
+	if saved.Pvc != "" {
+		t.Fatalf("应用的 PVC 不应改变: %q", saved.Pvc)
+	}
+}
+
+func TestRestoreSnapshotSwitchesBackWhenSwitchFails(t *testing.T) {
+	client, service, application, record := newRestoreFixture(t, 0)
+	client.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("更新被拒绝")
+	})
+
+	service.restore(record, application)
+
+	if state := restoreStateOf(t, record); state != model.RestoreFailed {
+		t.Fatalf("恢复状态为 %q", state)
+	}
+	pvcs, err := client.CoreV1().PersistentVolumeClaims("ns-1").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("列出 PVC 失败: %v", err)
+	}
+	if len(pvcs.Items) != 1 || pvcs.Items[0].Name != "pvc-abcd1234" {
+		t.Fatalf("应只保留原 PVC，实际为 %v", pvcs.Items)
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"gorm.io/gorm"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"learn/biz/config"
+	"learn/biz/model"
+)
+
+// defaultTemplate 未指定模板时使用的内置 code-server 环境
+var defaultTemplate = model.WorkspaceTemplate{
+	Name:            "default",
+	Description:     "内置 code-server 环境",
+	Image:           "docker.1ms.run/linuxserver/code-server:4.103.0",
+	ImagePullPolicy: string(corev1.PullNever),
+	Env: []model.TemplateEnv{
+		{Name: "PUID", Value: "1000"},
+		{Name: "PGID", Value: "1000"},
+		{Name: "TZ", Value: "Etc/UTC"},
+		{Name: "PWA_APPNAME", Value: "code-server"},
+		{Name: "HTTP_PROXY", Value: "http://223.2.19.172:3128"},
+		{Name: "http_proxy", Value: "http://223.2.19.172:3128"},
+		{Name: "HTTPS_PROXY", Value: "http://223.2.19.172:3128"},
+		{Name: "https_proxy", Value: "http://223.2.19.172:3128"},
+	},
+	Ports: []model.TemplatePort{
+		{Name: "https", ContainerPort: 8443, Protocol: string(corev1.ProtocolTCP)},
+	},
+	MountPath: "/config",
+	Cpu:       "1",
+	Memory:    "2Gi",
+}
+
+type TemplateService struct {
+	ctx context.Context
+	c   *app.RequestContext
+}
+
+func NewTemplateService(ctx context.Context, c *app.RequestContext) *TemplateService {
+	return &TemplateService{ctx: ctx, c: c}
+}
+
+func (s *TemplateService) ListTemplate() ([]*model.WorkspaceTemplate, error) {
+	var templates []*model.WorkspaceTemplate
+	err := config.DB.WithContext(s.ctx).Order("id").Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GetTemplate 根据ID获取模板，ID为0时返回内置默认模板
+func (s *TemplateService) GetTemplate(id uint) (*model.WorkspaceTemplate, error) {
+	if id == 0 {
+		template := defaultTemplate
+		return &template, nil
+	}
+
+	var template model.WorkspaceTemplate
+	err := config.DB.WithContext(s.ctx).First(&template, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("模板 %d 不存在", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (s *TemplateService) CreateTemplate(template *model.WorkspaceTemplate) (uint, error) {
+	if err := validateTemplate(template); err != nil {
+		return 0, err
+	}
+
+	template.ID = 0
+	if err := config.DB.WithContext(s.ctx).Create(template).Error; err != nil {
+		return 0, err
+	}
+	return template.ID, nil
+}
+
+func (s *TemplateService) UpdateTemplate(template *model.WorkspaceTemplate) error {
+	if template.ID == 0 {
+		return errors.New("模板ID不能为空")
+	}
+	if err := validateTemplate(template); err != nil {
+		return err
+	}
+
+	result := config.DB.WithContext(s.ctx).Model(&model.WorkspaceTemplate{Model: gorm.Model{ID: template.ID}}).
+		Select("name", "description", "image", "image_pull_policy", "env", "ports", "mount_path", "cpu", "memory").
+		Updates(template)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("模板 %d 不存在", template.ID)
+	}
+	return nil
+}
+
+func (s *TemplateService) DeleteTemplate(id uint) error {
+	if id == 0 {
+		return errors.New("模板ID不能为空")
+	}
+
+	var count int64
+	err := config.DB.WithContext(s.ctx).Model(&model.Application{}).Where("template_id = ?", id).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("模板仍被 %d 个应用使用，无法删除", count)
+	}
+
+	return config.DB.WithContext(s.ctx).Delete(&model.WorkspaceTemplate{}, id).Error
+}
+
+// validateTemplate 校验模板字段并补全默认值
+func validateTemplate(template *model.WorkspaceTemplate) error {
+	if template.Name == "" {
+		return errors.New("模板名称不能为空")
+	}
+	if template.Image == "" {
+		return errors.New("镜像不能为空")
+	}
+
+	switch corev1.PullPolicy(template.ImagePullPolicy) {
+	case "":
+		template.ImagePullPolicy = string(corev1.PullIfNotPresent)
+	case corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+	default:
+		return fmt.Errorf("不支持的镜像拉取策略: %s", template.ImagePullPolicy)
+	}
+
+	for _, e := range template.Env {
+		if e.Name == "" {
+			return errors.New("环境变量名称不能为空")
+		}
+		if e.Name == "PASSWORD" || e.Name == "SUDO_PASSWORD" {
+			return fmt.Errorf("环境变量 %s 由平台注入，不能在模板中设置", e.Name)
+		}
+	}
+
+	if len(template.Ports) == 0 {
+		template.Ports = defaultTemplate.Ports
+	}
+	for _, p := range template.Ports {
+		if p.ContainerPort <= 0 || p.ContainerPort > 65535 {
+			return fmt.Errorf("端口 %d 不合法", p.ContainerPort)
+		}
+	}
+
+	if template.MountPath == "" {
+		template.MountPath = defaultTemplate.MountPath
+	}
+	if template.Cpu == "" {
+		template.Cpu = defaultTemplate.Cpu
+	}
+	if template.Memory == "" {
+		template.Memory = defaultTemplate.Memory
+	}
+	if _, err := resource.ParseQuantity(template.Cpu); err != nil {
+		return fmt.Errorf("CPU 格式错误: %s", template.Cpu)
+	}
+	if _, err := resource.ParseQuantity(template.Memory); err != nil {
+		return fmt.Errorf("内存格式错误: %s", template.Memory)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"learn/biz/model"
+	"learn/biz/util"
+)
+
+// terminalCommand 优先使用 bash，镜像中没有时退回 sh
+var terminalCommand = []string{"/bin/sh", "-c", "command -v bash >/dev/null 2>&1 && exec bash -l || exec sh"}
+
+// TerminalStream 终端会话的输入输出，由接入层（WebSocket）实现
+type TerminalStream interface {
+	io.Reader
+	io.Writer
+	remotecommand.TerminalSizeQueue
+}
+
+type TerminalService struct {
+	ctx context.Context
+	c   *app.RequestContext
+}
+
+func NewTerminalService(ctx context.Context, c *app.RequestContext) *TerminalService {
+	return &TerminalService{ctx: ctx, c: c}
+}
+
+// GetTerminalTarget 校验当前用户拥有该应用，返回要连接的资源，需在升级 WebSocket 之前调用
+func (s *TerminalService) GetTerminalTarget(deployment string) (*model.KubernetesParam, error) {
+	application, err := NewAppService(s.ctx, s.c).ResolveApp(0, deployment)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireCluster(); err != nil {
+		return nil, err
+	}
+	return KubernetesParamOf(application), nil
+}
+
+// Attach 在 code-server 容器中打开交互式 shell，直到 shell 退出或 ctx 取消
+func (s *TerminalService) Attach(ctx context.Context, kbParam *model.KubernetesParam, stream TerminalStream) error {
+	return util.NewKubernetesUtil(ctx).ExecInPod(kbParam, model.ContainerCodeServer, terminalCommand, remotecommand.StreamOptions{
+		Stdin:             stream,
+		Stdout:            stream,
+		Tty:               true,
+		TerminalSizeQueue: stream,
+	})
+}
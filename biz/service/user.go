@@ -124,3 +124,19 @@ func (s *UserService) GetUserInfo() (model.User, error) {
 	}
 	return user, nil
 }
+
+// SetIdleTimeout 设置当前用户工作空间的默认空闲超时（分钟），0 表示不自动停止
+func (s *UserService) SetIdleTimeout(userParam model.UserParam) error {
+	userId, ok := s.c.Get("user_id")
+	if !ok {
+		return errors.New("没有找到用户ID")
+	}
+
+	if userParam.IdleTimeout < 0 {
+		return errors.New("空闲超时不能为负数")
+	}
+
+	return config.DB.WithContext(s.ctx).Model(&model.User{}).
+		Where("id = ?", userId).
+		Update("idle_timeout", userParam.IdleTimeout).Error
+}
@@ -17,6 +17,7 @@ import (
 
 	"learn/biz/config"
 	"learn/biz/model"
+	"learn/biz/service"
 	"learn/biz/util"
 )
 
@@ -26,6 +27,10 @@ type TimerService struct {
 	redis    *redis.Client
 	stopChan chan struct{}
 	wg       sync.WaitGroup
+
+	// 用户启停计划ID到 cron 任务的映射
+	scheduleMu      sync.Mutex
+	scheduleEntries map[uint]cron.EntryID
 }
 
 type PodUsageInfo struct {
@@ -40,22 +45,47 @@ type PodUsageInfo struct {
 func NewTimerService(ctx context.Context) *TimerService {
 	c := cron.New(cron.WithSeconds())
 	return &TimerService{
-		ctx:      ctx,
-		cron:     c,
-		redis:    config.RedisClient,
-		stopChan: make(chan struct{}),
+		ctx:             ctx,
+		cron:            c,
+		redis:           config.RedisClient,
+		stopChan:        make(chan struct{}),
+		scheduleEntries: make(map[uint]cron.EntryID),
 	}
 }
 
+// usageAccountingEnabled 是否启用按 Pod 运行时间计量的任务（更新使用时间、同步到数据库、清理过期数据）
+// 这些任务在定时服务启动之前从未运行过，启用后会开始写入使用记录，需要显式设置 USAGE_ACCOUNTING=true
+func usageAccountingEnabled() bool {
+	return util.GetEnvOrDefault("USAGE_ACCOUNTING", "false") == "true"
+}
+
 func (s *TimerService) Start() {
-	// 每30秒更新一次Pod使用时间
-	_, err := s.cron.AddFunc("*/30 * * * * *", s.updatePodUsageTime)
-	if err != nil {
-		log.Fatalf("添加更新Pod使用时间任务失败: %v", err)
+	if usageAccountingEnabled() {
+		s.addUsageJobs()
+	}
+
+	// 以下任务直接读取集群，开发模式下没有集群，不启动
+	if !util.DevMode() {
+		s.addClusterJobs()
+	}
+
+	// 每分钟回滚一次心跳过期的创建流程
+	if _, err := s.cron.AddFunc("0 * * * * *", s.recoverProvisions); err != nil {
+		log.Fatalf("添加创建流程恢复任务失败: %v", err)
 	}
 
+	// 恢复用户的定时启停计划，并接管之后新增或删除的计划
+	s.loadSchedules()
+	service.Schedules = s
+
+	s.cron.Start()
+	log.Println("计时服务已启动，计量单位：秒")
+}
+
+// addUsageJobs 添加计量相关的定时任务
+func (s *TimerService) addUsageJobs() {
 	// 每5分钟同步一次数据到MySQL
-	_, err = s.cron.AddFunc("0 */5 * * * *", s.syncToDatabase)
+	_, err := s.cron.AddFunc("0 */5 * * * *", s.syncToDatabase)
 	if err != nil {
 		log.Fatalf("添加同步数据任务失败: %v", err)
 	}
@@ -66,8 +96,34 @@ func (s *TimerService) Start() {
 		log.Fatalf("添加清理过期数据任务失败: %v", err)
 	}
 
-	s.cron.Start()
-	log.Println("计时服务已启动，计量单位：秒")
+	// 每30秒更新一次Pod使用时间，需要读取集群
+	if !util.DevMode() {
+		_, err = s.cron.AddFunc("*/30 * * * * *", s.updatePodUsageTime)
+		if err != nil {
+			log.Fatalf("添加更新Pod使用时间任务失败: %v", err)
+		}
+	}
+}
+
+// addClusterJobs 添加需要访问集群的定时任务
+func (s *TimerService) addClusterJobs() {
+	// 每30秒采集一次工作空间用量
+	_, err := s.cron.AddFunc("*/30 * * * * *", s.recordMetrics)
+	if err != nil {
+		log.Fatalf("添加用量采集任务失败: %v", err)
+	}
+
+	// 每分钟检查一次空闲的工作空间，上一轮还未结束时跳过本轮
+	_, err = s.cron.AddJob("0 * * * * *", cron.NewChain(cron.SkipIfStillRunning(cron.DefaultLogger)).Then(cron.FuncJob(s.stopIdleApps)))
+	if err != nil {
+		log.Fatalf("添加空闲检查任务失败: %v", err)
+	}
+
+	// 每10分钟对比一次应用记录与集群资源
+	_, err = s.cron.AddFunc("0 */10 * * * *", s.reconcileApps)
+	if err != nil {
+		log.Fatalf("添加对账任务失败: %v", err)
+	}
 }
 
 func (s *TimerService) Stop() {
@@ -90,7 +146,9 @@ func (s *TimerService) Stop() {
 	}
 
 	// 最后同步一次数据
-	s.syncToDatabase()
+	if usageAccountingEnabled() {
+		s.syncToDatabase()
+	}
 	log.Println("计时服务已安全停止")
 }
 
@@ -131,11 +189,7 @@ func (s *TimerService) updatePodUsageTime() {
 }
 
 func (s *TimerService) updateUserPods(userID int64, namespace string) {
-	kbParam := &model.KubernetesParam{
-		Namespace: namespace,
-	}
-
-	podList, err := util.NewKubernetesUtil(s.ctx).GetPodList(kbParam)
+	pods, err := util.NewKubernetesUtil(s.ctx).GetCachedPodList(namespace)
 	if err != nil {
 		log.Printf("获取Pod列表失败 - Namespace: %s, Error: %v", namespace, err)
 		return
@@ -143,7 +197,7 @@ func (s *TimerService) updateUserPods(userID int64, namespace string) {
 
 	now := time.Now()
 
-	for _, pod := range podList.Items {
+	for _, pod := range pods {
 		// 只处理运行中的Pod
 		if pod.Status.Phase != corev1.PodRunning {
 			continue
@@ -0,0 +1,162 @@
+package task
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/service"
+	"learn/biz/util"
+)
+
+// healthzTimeout 读取单个工作空间活动时间的超时，避免个别 Pod 拖住整轮检查
+const healthzTimeout = 10 * time.Second
+
+// healthzConcurrency 同时读取活动时间的工作空间数量上限
+const healthzConcurrency = 8
+
+// idleCandidate 配置了空闲超时、需要检查是否空闲的工作空间
+type idleCandidate struct {
+	application *model.Application
+	kbParam     *model.KubernetesParam
+	timeout     time.Duration
+}
+
+// effectiveIdleTimeout 计算应用实际生效的空闲超时，应用配置优先，0 时沿用用户默认值
+func effectiveIdleTimeout(application *model.Application, userTimeouts map[uint]int) time.Duration {
+	minutes := application.IdleTimeout
+	if minutes == 0 {
+		minutes = userTimeouts[application.UserId]
+	}
+	if minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// stopIdleApps 停止 code-server 长时间没有浏览器连接的工作空间，活动时间由服务端从 code-server 的 /healthz 读取
+func (s *TimerService) stopIdleApps() {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	var applications []*model.Application
+	err := config.DB.WithContext(s.ctx).Where("idle_timeout >= 0").Find(&applications).Error
+	if err != nil {
+		log.Printf("获取应用列表失败: %v", err)
+		return
+	}
+
+	var users []*model.User
+	err = config.DB.WithContext(s.ctx).Select("id", "idle_timeout").Where("idle_timeout > 0").Find(&users).Error
+	if err != nil {
+		log.Printf("获取用户空闲超时配置失败: %v", err)
+		return
+	}
+	userTimeouts := make(map[uint]int, len(users))
+	for _, user := range users {
+		userTimeouts[user.ID] = user.IdleTimeout
+	}
+
+	var candidates []*idleCandidate
+	for _, application := range applications {
+		timeout := effectiveIdleTimeout(application, userTimeouts)
+		if timeout == 0 {
+			continue
+		}
+		candidates = append(candidates, &idleCandidate{
+			application: application,
+			kbParam:     service.KubernetesParamOf(application),
+			timeout:     timeout,
+		})
+	}
+
+	counterService := service.NewCounterService(s.ctx)
+	s.refreshActivities(counterService, candidates)
+	now := time.Now()
+
+	for _, candidate := range candidates {
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+
+		application, kbParam, timeout := candidate.application, candidate.kbParam, candidate.timeout
+		lastActivity, err := counterService.LastActivity(kbParam.Namespace, kbParam.Deployment)
+		if err != nil {
+			log.Printf("读取活动时间失败 - Deployment: %s, Error: %v", application.Deployment, err)
+			continue
+		}
+		// 没有活动记录说明应用未运行
+		if lastActivity.IsZero() || now.Sub(lastActivity) < timeout {
+			continue
+		}
+
+		deployment, err := util.NewKubernetesUtil(s.ctx).GetCachedDeployment(kbParam)
+		if err != nil {
+			log.Printf("获取Deployment失败 - Deployment: %s, Error: %v", application.Deployment, err)
+			continue
+		}
+		if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas == 0 {
+			counterService.ClearActivity(kbParam.Namespace, kbParam.Deployment)
+			continue
+		}
+
+		log.Printf("工作空间空闲超时，自动停止 - Deployment: %s, 最后活动: %s", application.Deployment, lastActivity.Format(time.RFC3339))
+		if err := service.StopWorkspace(s.ctx, kbParam); err != nil {
+			log.Printf("自动停止工作空间失败 - Deployment: %s, Error: %v", application.Deployment, err)
+		}
+	}
+}
+
+// refreshActivities 并发读取各工作空间的活动时间，同时进行的读取不超过 healthzConcurrency 个
+func (s *TimerService) refreshActivities(counterService *service.CounterService, candidates []*idleCandidate) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, healthzConcurrency)
+	for _, candidate := range candidates {
+		select {
+		case <-s.stopChan:
+			wg.Wait()
+			return
+		case slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(kbParam *model.KubernetesParam) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			s.refreshActivity(counterService, kbParam)
+		}(candidate.kbParam)
+	}
+	wg.Wait()
+}
+
+// refreshActivity 读取 code-server 最后一次有浏览器连接的时间并记录，Pod 启动时间作为下限，
+// 启动后一直没有人连接的工作空间同样会在超时后停止；读取失败时只记录 Pod 启动时间，
+// 已有的活动记录不会回退，持续读取失败的工作空间按最后一次成功读取的时间计算空闲
+func (s *TimerService) refreshActivity(counterService *service.CounterService, kbParam *model.KubernetesParam) {
+	pod, err := util.NewKubernetesUtil(s.ctx).GetCachedPod(kbParam)
+	if err != nil || pod.Status.Phase != corev1.PodRunning {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, healthzTimeout)
+	defer cancel()
+	activity, err := util.NewKubernetesUtil(ctx).CodeServerLastHeartbeat(pod)
+	if err != nil {
+		log.Printf("读取 code-server 活动时间失败 - Deployment: %s, Error: %v", kbParam.Deployment, err)
+		activity = time.Time{}
+	}
+	if pod.Status.StartTime != nil && pod.Status.StartTime.Time.After(activity) {
+		activity = pod.Status.StartTime.Time
+	}
+	if activity.IsZero() {
+		return
+	}
+	counterService.RecordActivity(kbParam.Namespace, kbParam.Deployment, activity)
+}
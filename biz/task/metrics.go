@@ -0,0 +1,23 @@
+package task
+
+import (
+	"log"
+
+	"learn/biz/service"
+)
+
+// recordMetrics 采集所有运行中工作空间的 CPU 和内存用量
+func (s *TimerService) recordMetrics() {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	select {
+	case <-s.stopChan:
+		return
+	default:
+	}
+
+	if err := service.NewMetricsService(s.ctx, nil).RecordSamples(); err != nil {
+		log.Printf("采集工作空间用量失败: %v", err)
+	}
+}
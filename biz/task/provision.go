@@ -0,0 +1,19 @@
+package task
+
+import (
+	"learn/biz/service"
+)
+
+// recoverProvisions 定期回滚所在实例已退出的创建流程，其他副本退出后留下的记录不必等到本实例重启
+func (s *TimerService) recoverProvisions() {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	select {
+	case <-s.stopChan:
+		return
+	default:
+	}
+
+	service.RecoverInterruptedProvisions(s.ctx)
+}
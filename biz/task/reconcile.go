@@ -0,0 +1,23 @@
+package task
+
+import (
+	"log"
+
+	"learn/biz/service"
+)
+
+// reconcileApps 定期对比应用记录与集群资源
+func (s *TimerService) reconcileApps() {
+	s.wg.Add(1)
+	defer s.wg.Done()
+
+	select {
+	case <-s.stopChan:
+		return
+	default:
+	}
+
+	if _, err := service.NewReconcileService(s.ctx).Reconcile(false); err != nil {
+		log.Printf("对账失败: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package task
+
+import (
+	"log"
+
+	"github.com/robfig/cron/v3"
+
+	"learn/biz/config"
+	"learn/biz/model"
+	"learn/biz/service"
+)
+
+// loadSchedules 启动时从数据库恢复所有启停计划
+func (s *TimerService) loadSchedules() {
+	var schedules []*model.AppSchedule
+	if err := config.DB.WithContext(s.ctx).Find(&schedules).Error; err != nil {
+		log.Printf("加载启停计划失败: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		if err := s.Register(schedule); err != nil {
+			log.Printf("注册启停计划失败 - ID: %d, Error: %v", schedule.ID, err)
+		}
+	}
+	log.Printf("已加载 %d 个启停计划", len(schedules))
+}
+
+// Register 实现 service.ScheduleRegistry
+func (s *TimerService) Register(schedule *model.AppSchedule) error {
+	parsed, err := service.ParseSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	scheduleId := schedule.ID
+	entryId := s.cron.Schedule(parsed, cron.FuncJob(func() {
+		s.wg.Add(1)
+		defer s.wg.Done()
+		service.RunSchedule(s.ctx, scheduleId)
+	}))
+
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	if old, ok := s.scheduleEntries[scheduleId]; ok {
+		s.cron.Remove(old)
+	}
+	s.scheduleEntries[scheduleId] = entryId
+	return nil
+}
+
+// Unregister 实现 service.ScheduleRegistry
+func (s *TimerService) Unregister(scheduleId uint) {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	if entryId, ok := s.scheduleEntries[scheduleId]; ok {
+		s.cron.Remove(entryId)
+		delete(s.scheduleEntries, scheduleId)
+	}
+}
@@ -0,0 +1,95 @@
+// Package testutil 提供 service 与 handler 测试共用的数据库、Redis 和请求上下文
+package testutil
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"learn/biz/config"
+	"learn/biz/model"
+)
+
+// SetupDB 使用内存中的 SQLite 替换 config.DB，每个测试独立一个数据库
+func SetupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := model.AutoMigrate(db); err != nil {
+		t.Fatalf("同步测试数据表失败: %v", err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// SetupRedis 使用 miniredis 替换 config.RedisClient
+func SetupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	previous := config.RedisClient
+	config.RedisClient = client
+	t.Cleanup(func() {
+		config.RedisClient = previous
+		_ = client.Close()
+	})
+	return server
+}
+
+// NewContext 模拟通过 JWT 认证后的请求上下文
+func NewContext(userId int64) *app.RequestContext {
+	c := app.NewContext(0)
+	c.Set("user_id", userId)
+	return c
+}
+
+// CreateUser 写入一个使用默认套餐的用户，已存在时直接返回
+func CreateUser(t *testing.T, userId uint) *model.User {
+	t.Helper()
+	user := &model.User{
+		Username: fmt.Sprintf("user%d", userId),
+		Email:    fmt.Sprintf("user%d@example.com", userId),
+		Password: "-",
+	}
+	user.ID = userId
+	if err := config.DB.FirstOrCreate(user, userId).Error; err != nil {
+		t.Fatalf("写入用户失败: %v", err)
+	}
+	return user
+}
+
+// CreateApp 为用户写入用户记录和一条应用记录，deployment 的后 8 位作为资源名称的后缀
+func CreateApp(t *testing.T, userId uint, deployment string) *model.Application {
+	t.Helper()
+	CreateUser(t, userId)
+	application := &model.Application{
+		Name:       deployment,
+		PodName:    "pod-" + deployment[len(deployment)-8:],
+		UserId:     userId,
+		Cpu:        "1",
+		Memory:     "2Gi",
+		Deployment: deployment,
+	}
+	if err := config.DB.Create(application).Error; err != nil {
+		t.Fatalf("写入应用记录失败: %v", err)
+	}
+	return application
+}
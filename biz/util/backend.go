@@ -0,0 +1,255 @@
+package util
+
+import (
+	"context"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+
+	"learn/biz/model"
+)
+
+// WorkspaceBackend 工作空间的运行环境，AppService 通过它完成工作空间从创建到删除的整个生命周期
+// 默认使用 Kubernetes 实现，以 --dev 启动时使用进程内的 MemoryBackend
+type WorkspaceBackend interface {
+	// WithContext 返回使用指定 context 的后端，请求结束后仍在运行的后台流程需要换成独立的 context
+	WithContext(ctx context.Context) WorkspaceBackend
+	// EnsureTenant 准备用户的隔离环境并同步配额，request 不为空时检查再申请这些资源是否会超出配额
+	EnsureTenant(namespace string, quota *model.NamespaceQuota, request corev1.ResourceList) error
+	// CreateSecret 创建或更新工作空间的密码
+	CreateSecret(kbParam *model.KubernetesParam, password string) error
+	DeleteSecret(kbParam *model.KubernetesParam) error
+	// CreateVolume 创建工作空间的数据卷，已存在时沿用
+	CreateVolume(kbParam *model.KubernetesParam, appParam *model.AppParam) error
+	DeleteVolume(kbParam *model.KubernetesParam) error
+	// CloneVolume 以 source 的数据卷为数据源创建工作空间的数据卷，容量为 storage
+	CloneVolume(kbParam, source *model.KubernetesParam, storage string) error
+	// VolumeSize 查询工作空间数据卷的容量
+	VolumeSize(kbParam *model.KubernetesParam) (string, error)
+	// WaitVolumeReady 等待数据卷可用，克隆卷在数据复制结束后才可用
+	WaitVolumeReady(kbParam *model.KubernetesParam, timeout time.Duration) error
+	// ReleaseVolume 删除临时挂载数据卷的辅助 Pod，启动工作空间前调用
+	ReleaseVolume(kbParam *model.KubernetesParam) error
+	// Password 读取工作空间当前的密码
+	Password(kbParam *model.KubernetesParam) (string, error)
+	// CreateWorkload 创建运行工作空间的 Deployment，已存在时沿用
+	CreateWorkload(kbParam *model.KubernetesParam, appParam *model.AppParam, template *model.WorkspaceTemplate) error
+	DeleteWorkload(kbParam *model.KubernetesParam) error
+	// Scale 修改工作空间的副本数，0 表示停止
+	Scale(kbParam *model.KubernetesParam, replicas int32) error
+	// WaitStopped 等待工作空间的 Pod 全部退出，缩容到 0 后旧 Pod 仍会挂载数据卷一段时间
+	WaitStopped(kbParam *model.KubernetesParam, timeout time.Duration) error
+	// Expose 创建访问入口并把访问地址写入 application.Url
+	Expose(kbParam *model.KubernetesParam, application *model.Application) error
+	Unexpose(kbParam *model.KubernetesParam) error
+	// Destroy 删除工作空间的所有资源，不存在的资源会被忽略
+	Destroy(kbParam *model.KubernetesParam) error
+	// Status 查询工作空间的运行状态，工作空间不存在时返回错误
+	Status(kbParam *model.KubernetesParam) (*WorkspaceStatus, error)
+	// Logs 打开工作空间 Pod 的日志流，调用方负责关闭
+	Logs(kbParam *model.KubernetesParam, options *corev1.PodLogOptions) (io.ReadCloser, error)
+}
+
+// WorkspaceStatus 工作空间的运行状态，Pod 为 nil 表示当前没有 Pod
+type WorkspaceStatus struct {
+	Replicas int32
+	Pod      *corev1.Pod
+}
+
+// EventLister 能够提供 Kubernetes 事件的后端额外实现的接口，用于解释工作空间无法运行的原因
+type EventLister interface {
+	ListNamespaceEvents(namespace string) ([]corev1.Event, error)
+}
+
+// devBackend 以 --dev 启动时所有请求共用的进程内后端
+var devBackend *MemoryBackend
+
+// UseMemoryBackend 切换到进程内后端，需要在处理请求之前调用
+func UseMemoryBackend() {
+	devBackend = NewMemoryBackend()
+}
+
+// DevMode 是否以 --dev 启动，此时没有可用的集群
+func DevMode() bool {
+	return devBackend != nil
+}
+
+// NewWorkspaceBackend 返回当前启动模式下的后端
+func NewWorkspaceBackend(ctx context.Context) WorkspaceBackend {
+	if devBackend != nil {
+		return devBackend
+	}
+	kubernetesUtil := NewKubernetesUtil(ctx)
+	return &kubernetesBackend{
+		kubernetesUtil: kubernetesUtil,
+		exposer:        newExposer(ctx, kubernetesUtil),
+		cached:         true,
+	}
+}
+
+// NewKubernetesBackend 使用指定客户端的 Kubernetes 后端，不读取共享缓存，测试时可传入 client-go 的 fake 客户端
+func NewKubernetesBackend(ctx context.Context, client kubernetes.Interface) WorkspaceBackend {
+	kubernetesUtil := NewKubernetesUtilWithClient(ctx, client)
+	return &kubernetesBackend{
+		kubernetesUtil: kubernetesUtil,
+		exposer:        newExposer(ctx, kubernetesUtil),
+	}
+}
+
+// Uncached 返回查询状态时不读取 informer 缓存的后端，准入检查需要看到刚刚修改的副本数
+func Uncached(backend WorkspaceBackend) WorkspaceBackend {
+	if b, ok := backend.(*kubernetesBackend); ok && b.cached {
+		uncached := *b
+		uncached.cached = false
+		return &uncached
+	}
+	return backend
+}
+
+type kubernetesBackend struct {
+	kubernetesUtil *KubernetesUtil
+	exposer        Exposer
+	// cached 为 true 时状态查询读取 informer 缓存
+	cached bool
+}
+
+func (b *kubernetesBackend) WithContext(ctx context.Context) WorkspaceBackend {
+	kubernetesUtil := NewKubernetesUtilWithClient(ctx, b.kubernetesUtil.client)
+	return &kubernetesBackend{
+		kubernetesUtil: kubernetesUtil,
+		exposer:        newExposer(ctx, kubernetesUtil),
+		cached:         b.cached,
+	}
+}
+
+func (b *kubernetesBackend) EnsureTenant(namespace string, quota *model.NamespaceQuota, request corev1.ResourceList) error {
+	if err := b.kubernetesUtil.EnsureNamespace(namespace, quota); err != nil {
+		return err
+	}
+	if request == nil {
+		return nil
+	}
+	return b.kubernetesUtil.CheckQuota(namespace, request)
+}
+
+func (b *kubernetesBackend) CreateSecret(kbParam *model.KubernetesParam, password string) error {
+	return b.kubernetesUtil.ApplyPasswordSecret(kbParam, password)
+}
+
+func (b *kubernetesBackend) DeleteSecret(kbParam *model.KubernetesParam) error {
+	return b.kubernetesUtil.DeleteSecret(kbParam)
+}
+
+func (b *kubernetesBackend) CreateVolume(kbParam *model.KubernetesParam, appParam *model.AppParam) error {
+	return b.kubernetesUtil.CreatePvc(kbParam, appParam)
+}
+
+func (b *kubernetesBackend) DeleteVolume(kbParam *model.KubernetesParam) error {
+	return b.kubernetesUtil.DeletePvc(kbParam)
+}
+
+func (b *kubernetesBackend) CloneVolume(kbParam, source *model.KubernetesParam, storage string) error {
+	return b.kubernetesUtil.CreatePvcFromSource(kbParam, storage, &corev1.TypedLocalObjectReference{
+		Kind: "PersistentVolumeClaim",
+		Name: source.Pvc,
+	})
+}
+
+func (b *kubernetesBackend) VolumeSize(kbParam *model.KubernetesParam) (string, error) {
+	pvc, err := b.kubernetesUtil.GetPvc(kbParam)
+	if err != nil {
+		return "", err
+	}
+	storage := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	return storage.String(), nil
+}
+
+func (b *kubernetesBackend) WaitVolumeReady(kbParam *model.KubernetesParam, timeout time.Duration) error {
+	return b.kubernetesUtil.WaitPvcBound(kbParam, timeout)
+}
+
+func (b *kubernetesBackend) ReleaseVolume(kbParam *model.KubernetesParam) error {
+	return b.kubernetesUtil.DeleteHelperPods(kbParam, time.Minute)
+}
+
+// Password 旧版本应用的密码没有 Secret，直接写在环境变量中
+func (b *kubernetesBackend) Password(kbParam *model.KubernetesParam) (string, error) {
+	password, err := b.kubernetesUtil.GetPassword(kbParam)
+	if !errors.IsNotFound(err) {
+		return password, err
+	}
+	deployment, err := b.kubernetesUtil.GetDeployment(kbParam)
+	if err != nil {
+		return "", err
+	}
+	return codeServerEnv(deployment, "PASSWORD"), nil
+}
+
+func (b *kubernetesBackend) CreateWorkload(kbParam *model.KubernetesParam, appParam *model.AppParam, template *model.WorkspaceTemplate) error {
+	return b.kubernetesUtil.CreateDeployment(kbParam, appParam, template)
+}
+
+func (b *kubernetesBackend) DeleteWorkload(kbParam *model.KubernetesParam) error {
+	return b.kubernetesUtil.DeleteDeployment(kbParam)
+}
+
+func (b *kubernetesBackend) Scale(kbParam *model.KubernetesParam, replicas int32) error {
+	return b.kubernetesUtil.ScaleDeployment(kbParam, replicas)
+}
+
+func (b *kubernetesBackend) WaitStopped(kbParam *model.KubernetesParam, timeout time.Duration) error {
+	return b.kubernetesUtil.WaitPodsGone(kbParam, timeout)
+}
+
+func (b *kubernetesBackend) Expose(kbParam *model.KubernetesParam, application *model.Application) error {
+	return b.exposer.Expose(kbParam, application)
+}
+
+func (b *kubernetesBackend) Unexpose(kbParam *model.KubernetesParam) error {
+	return b.exposer.Unexpose(kbParam)
+}
+
+func (b *kubernetesBackend) Destroy(kbParam *model.KubernetesParam) error {
+	for _, remove := range []func(*model.KubernetesParam) error{
+		b.exposer.Unexpose,
+		b.kubernetesUtil.DeleteDeployment,
+		b.kubernetesUtil.DeletePvc,
+		b.kubernetesUtil.DeleteSecret,
+	} {
+		if err := remove(kbParam); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *kubernetesBackend) Status(kbParam *model.KubernetesParam) (*WorkspaceStatus, error) {
+	getDeployment, getPod := b.kubernetesUtil.GetDeployment, b.kubernetesUtil.GetPodInfo
+	if b.cached {
+		getDeployment, getPod = b.kubernetesUtil.GetCachedDeployment, b.kubernetesUtil.GetCachedPod
+	}
+
+	deployment, err := getDeployment(kbParam)
+	if err != nil {
+		return nil, err
+	}
+	status := &WorkspaceStatus{Replicas: 1}
+	if deployment.Spec.Replicas != nil {
+		status.Replicas = *deployment.Spec.Replicas
+	}
+	if pod, err := getPod(kbParam); err == nil {
+		status.Pod = pod
+	}
+	return status, nil
+}
+
+func (b *kubernetesBackend) Logs(kbParam *model.KubernetesParam, options *corev1.PodLogOptions) (io.ReadCloser, error) {
+	return b.kubernetesUtil.StreamPodLog(kbParam, options)
+}
+
+func (b *kubernetesBackend) ListNamespaceEvents(namespace string) ([]corev1.Event, error) {
+	return b.kubernetesUtil.ListNamespaceEvents(namespace)
+}
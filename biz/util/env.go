@@ -0,0 +1,32 @@
+package util
+
+import (
+	"os"
+	"strconv"
+)
+
+// GetEnvOrDefault 获取环境变量或默认值
+func GetEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// MaxUploadSize 单个上传文件的大小上限（字节），由 FILES_MAX_UPLOAD_MB 指定，默认 512MB
+func MaxUploadSize() int64 {
+	size, err := strconv.ParseInt(GetEnvOrDefault("FILES_MAX_UPLOAD_MB", "512"), 10, 64)
+	if err != nil || size <= 0 {
+		size = 512
+	}
+	return size << 20
+}
+
+// MaxRequestBodySize 文件上传以外接口的请求体上限（字节），由 MAX_REQUEST_BODY_MB 指定，默认 4MB
+func MaxRequestBodySize() int64 {
+	size, err := strconv.ParseInt(GetEnvOrDefault("MAX_REQUEST_BODY_MB", "4"), 10, 64)
+	if err != nil || size <= 0 {
+		size = 4
+	}
+	return size << 20
+}
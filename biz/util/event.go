@@ -0,0 +1,147 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"learn/biz/model"
+)
+
+// maxWorkspaceEvents 每个工作空间最多返回的事件数
+const maxWorkspaceEvents = 50
+
+// ListNamespaceEvents 列出命名空间中的所有事件，按工作空间筛选交给 WorkspaceEventsOf，
+// 这样列表接口中多个工作空间只需要查询一次
+func (s *KubernetesUtil) ListNamespaceEvents(namespace string) ([]corev1.Event, error) {
+	events, err := s.client.CoreV1().Events(namespace).List(s.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取事件列表失败: %w", err)
+	}
+	return events.Items, nil
+}
+
+// WorkspaceEventsOf 从命名空间事件中筛选出工作空间 Deployment、ReplicaSet、Pod 和 PVC 的事件，
+// 合并重复事件后按最近发生时间倒序返回
+// ReplicaSet 和 Pod 的名称都以 "<deployment>-" 开头
+func WorkspaceEventsOf(events []corev1.Event, kbParam *model.KubernetesParam) []model.WorkspaceEvent {
+	merged := make(map[string]*model.WorkspaceEvent)
+	for _, event := range events {
+		object := event.InvolvedObject
+		switch object.Kind {
+		case "Deployment":
+			if object.Name != kbParam.Deployment {
+				continue
+			}
+		case "ReplicaSet", "Pod":
+			if !strings.HasPrefix(object.Name, kbParam.Deployment+"-") {
+				continue
+			}
+		case "PersistentVolumeClaim":
+			if object.Name != kbParam.Pvc {
+				continue
+			}
+		default:
+			continue
+		}
+
+		first, last := eventTimes(&event)
+		count := event.Count
+		if count == 0 {
+			count = 1
+		}
+		if event.Series != nil && event.Series.Count > count {
+			count = event.Series.Count
+		}
+
+		key := strings.Join([]string{object.Kind, object.Name, event.Reason, event.Message}, "\x00")
+		if existing, ok := merged[key]; ok {
+			existing.Count += count
+			if first.Before(existing.FirstTimestamp) {
+				existing.FirstTimestamp = first
+			}
+			if last.After(existing.LastTimestamp) {
+				existing.LastTimestamp = last
+			}
+			continue
+		}
+		merged[key] = &model.WorkspaceEvent{
+			Type:           event.Type,
+			Reason:         event.Reason,
+			Message:        event.Message,
+			Kind:           object.Kind,
+			Name:           object.Name,
+			Count:          count,
+			FirstTimestamp: first,
+			LastTimestamp:  last,
+		}
+	}
+
+	result := make([]model.WorkspaceEvent, 0, len(merged))
+	for _, event := range merged {
+		result = append(result, *event)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastTimestamp.After(result[j].LastTimestamp)
+	})
+	if len(result) > maxWorkspaceEvents {
+		result = result[:maxWorkspaceEvents]
+	}
+	return result
+}
+
+// eventTimes 兼容 events.k8s.io 写入的只有 EventTime 的事件
+func eventTimes(event *corev1.Event) (first, last time.Time) {
+	first, last = event.FirstTimestamp.Time, event.LastTimestamp.Time
+	if first.IsZero() {
+		first = event.EventTime.Time
+	}
+	if first.IsZero() {
+		first = event.CreationTimestamp.Time
+	}
+	if event.Series != nil && !event.Series.LastObservedTime.IsZero() {
+		last = event.Series.LastObservedTime.Time
+	}
+	if last.IsZero() {
+		last = first
+	}
+	return first, last
+}
+
+// WorkspaceReason 根据最近的 Warning 事件给出工作空间无法正常运行的原因，没有 Warning 时返回空字符串
+// events 需按时间倒序排列
+func WorkspaceReason(events []model.WorkspaceEvent) string {
+	for _, event := range events {
+		if event.Type != corev1.EventTypeWarning {
+			continue
+		}
+		switch event.Reason {
+		case "ErrImageNeverPull", "ErrImagePull", "ImagePullBackOff":
+			return "镜像拉取失败，请确认镜像已导入节点: " + event.Message
+		case "Failed", "BackOff":
+			// 拉取镜像失败的事件原因同样是 Failed 或 BackOff，只能通过信息区分
+			if strings.Contains(event.Message, "image") {
+				return "镜像拉取失败，请确认镜像已导入节点: " + event.Message
+			}
+			if event.Reason == "BackOff" {
+				return "容器启动后反复退出: " + event.Message
+			}
+		case "FailedScheduling":
+			return "没有可用节点调度工作空间: " + event.Message
+		case "ProvisioningFailed", "FailedBinding":
+			return "数据卷创建失败: " + event.Message
+		case "FailedMount", "FailedAttachVolume":
+			return "数据卷挂载失败: " + event.Message
+		case "FailedCreate":
+			return "创建 Pod 失败: " + event.Message
+		case "Unhealthy":
+			return "健康检查未通过: " + event.Message
+		}
+		return event.Reason + ": " + event.Message
+	}
+	return ""
+}
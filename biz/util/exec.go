@@ -0,0 +1,161 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"learn/biz/model"
+)
+
+// HelperContainer 辅助 Pod 中容器的名称
+const HelperContainer = "helper"
+
+// ExecInPod 在 Deployment 当前 Pod 的指定容器中执行命令，streams 中为 nil 的流不会被打开
+// 命令结束、流关闭或 ctx 取消时返回，命令以非0状态退出时返回 exec.CodeExitError
+func (s *KubernetesUtil) ExecInPod(kbParam *model.KubernetesParam, container string, command []string, streams remotecommand.StreamOptions) error {
+	pod, err := s.GetPodInfo(kbParam)
+	if err != nil {
+		return err
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return fmt.Errorf("Pod %s 未处于运行状态: %s", pod.Name, pod.Status.Phase)
+	}
+	return s.ExecInNamedPod(kbParam.Namespace, pod.Name, container, command, streams)
+}
+
+// ExecInNamedPod 在指定 Pod 的容器中执行命令
+func (s *KubernetesUtil) ExecInNamedPod(namespace, pod, container string, command []string, streams remotecommand.StreamOptions) error {
+	req := s.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     streams.Stdin != nil,
+			Stdout:    streams.Stdout != nil,
+			Stderr:    streams.Stderr != nil,
+			TTY:       streams.Tty,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(RestConfig, "POST", req.URL())
+	if err != nil {
+		return fmt.Errorf("创建exec连接失败: %w", err)
+	}
+	return executor.StreamWithContext(s.ctx, streams)
+}
+
+// CreateHelperPod 创建挂载工作空间 PVC 的临时 Pod，用于工作空间停止时读写数据卷
+// Pod 最多存活一小时，调用方用完后应调用 DeletePod 删除
+func (s *KubernetesUtil) CreateHelperPod(kbParam *model.KubernetesParam, mountPath string) (string, error) {
+	name := fmt.Sprintf("helper-%s-%s", kbParam.Pvc[len(kbParam.Pvc)-8:], uuid.NewString()[:5])
+	deadline := int64(3600)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: kbParam.Namespace,
+			Labels: map[string]string{
+				"app":        "workspace-helper",
+				"deployment": kbParam.Deployment,
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:         corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			Containers: []corev1.Container{
+				{
+					Name:            HelperContainer,
+					Image:           GetEnvOrDefault("HELPER_IMAGE", "busybox:1.36"),
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Command:         []string{"sleep", "3600"},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "data",
+							MountPath: mountPath,
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "data",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: kbParam.Pvc,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	_, err := s.client.CoreV1().Pods(kbParam.Namespace).Create(s.ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		log.Printf("创建辅助 Pod 失败: %v", err)
+		return "", fmt.Errorf("创建辅助 Pod 失败: %w", err)
+	}
+	return name, nil
+}
+
+// DeleteHelperPods 删除工作空间的辅助 Pod 并等待其退出，数据卷为 ReadWriteOnce 时辅助 Pod 会让工作空间无法挂载
+func (s *KubernetesUtil) DeleteHelperPods(kbParam *model.KubernetesParam, timeout time.Duration) error {
+	labelSelector := fmt.Sprintf("app=workspace-helper,deployment=%s", kbParam.Deployment)
+	pods, err := s.client.CoreV1().Pods(kbParam.Namespace).List(s.ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return fmt.Errorf("获取辅助 Pod 列表失败: %w", err)
+	}
+	if len(pods.Items) == 0 {
+		return nil
+	}
+	for _, pod := range pods.Items {
+		if err := s.DeletePod(kbParam.Namespace, pod.Name); err != nil {
+			return err
+		}
+	}
+	return wait.PollUntilContextTimeout(s.ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := s.client.CoreV1().Pods(kbParam.Namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			return false, err
+		}
+		return len(pods.Items) == 0, nil
+	})
+}
+
+// WaitPodRunning 等待 Pod 进入 Running 状态
+func (s *KubernetesUtil) WaitPodRunning(namespace, name string, timeout time.Duration) error {
+	return wait.PollUntilContextTimeout(s.ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := s.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return false, fmt.Errorf("Pod %s 已退出: %s", name, pod.Status.Phase)
+		}
+		return false, nil
+	})
+}
+
+func (s *KubernetesUtil) DeletePod(namespace, name string) error {
+	grace := int64(0)
+	err := s.client.CoreV1().Pods(namespace).Delete(s.ctx, name, metav1.DeleteOptions{GracePeriodSeconds: &grace})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Printf("删除 Pod 失败: %v", err)
+		return fmt.Errorf("删除 Pod 失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"learn/biz/model"
+)
+
+const (
+	ExposeModeNodePort  = "nodeport"
+	ExposeModeIngress   = "ingress"
+	ExposeModeHttpRoute = "httproute"
+)
+
+var httpRouteGVR = schema.GroupVersionResource{
+	Group:    "gateway.networking.k8s.io",
+	Version:  "v1",
+	Resource: "httproutes",
+}
+
+// Exposer 工作空间的访问方式，负责创建访问入口并生成写入 Application.Url 的地址
+type Exposer interface {
+	Expose(kbParam *model.KubernetesParam, application *model.Application) error
+	Unexpose(kbParam *model.KubernetesParam) error
+}
+
+// ExposeMode 当前配置的访问方式，由环境变量 EXPOSE_MODE 指定，默认 nodeport
+func ExposeMode() string {
+	return GetEnvOrDefault("EXPOSE_MODE", ExposeModeNodePort)
+}
+
+// NewExposer 按 EXPOSE_MODE 返回对应的访问方式
+func NewExposer(ctx context.Context) Exposer {
+	return newExposer(ctx, NewKubernetesUtil(ctx))
+}
+
+func newExposer(ctx context.Context, kubernetesUtil *KubernetesUtil) Exposer {
+	switch ExposeMode() {
+	case ExposeModeIngress:
+		return &ingressExposer{ctx: ctx, kubernetesUtil: kubernetesUtil}
+	case ExposeModeHttpRoute:
+		return &httpRouteExposer{ctx: ctx, kubernetesUtil: kubernetesUtil}
+	default:
+		return &nodePortExposer{kubernetesUtil: kubernetesUtil}
+	}
+}
+
+// nodePortExposer 通过 NodePort 暴露，地址为 http://<节点地址>:<nodePort>
+type nodePortExposer struct {
+	kubernetesUtil *KubernetesUtil
+}
+
+func (e *nodePortExposer) Expose(kbParam *model.KubernetesParam, application *model.Application) error {
+	return e.kubernetesUtil.CreateSvc(kbParam, application)
+}
+
+func (e *nodePortExposer) Unexpose(kbParam *model.KubernetesParam) error {
+	return e.kubernetesUtil.DeleteSvc(kbParam)
+}
+
+// ingressExposer 通过 Ingress 按域名 <suffix>.<EXPOSE_BASE_DOMAIN> 暴露
+type ingressExposer struct {
+	ctx            context.Context
+	kubernetesUtil *KubernetesUtil
+}
+
+func (e *ingressExposer) Expose(kbParam *model.KubernetesParam, application *model.Application) error {
+	if _, err := e.kubernetesUtil.CreateServiceOfType(kbParam, corev1.ServiceTypeClusterIP); err != nil {
+		return err
+	}
+
+	host := hostOf(kbParam)
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      routeNameOf(kbParam),
+			Namespace: kbParam.Namespace,
+			Labels: map[string]string{
+				"app":        "code-server",
+				"deployment": kbParam.Deployment,
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: host,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/",
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: kbParam.Svc,
+									Port: networkingv1.ServiceBackendPort{Number: 443},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+	if ingressClass := GetEnvOrDefault("EXPOSE_INGRESS_CLASS", ""); ingressClass != "" {
+		ingress.Spec.IngressClassName = &ingressClass
+	}
+	if tlsSecret := GetEnvOrDefault("EXPOSE_TLS_SECRET", ""); tlsSecret != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{{
+			Hosts:      []string{host},
+			SecretName: tlsSecret,
+		}}
+	}
+
+	_, err := e.kubernetesUtil.client.NetworkingV1().Ingresses(kbParam.Namespace).Create(e.ctx, ingress, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		log.Printf("创建 Ingress 失败: %v", err)
+		return fmt.Errorf("创建 Ingress 失败: %w", err)
+	}
+
+	application.Url = urlOf(host)
+	return nil
+}
+
+func (e *ingressExposer) Unexpose(kbParam *model.KubernetesParam) error {
+	err := e.kubernetesUtil.client.NetworkingV1().Ingresses(kbParam.Namespace).Delete(e.ctx, routeNameOf(kbParam), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Printf("删除 Ingress 失败: %v", err)
+		return fmt.Errorf("删除 Ingress 失败: %w", err)
+	}
+	return e.kubernetesUtil.DeleteSvc(kbParam)
+}
+
+// httpRouteExposer 通过 Gateway API 的 HTTPRoute 挂到 EXPOSE_GATEWAY_NAMESPACE/EXPOSE_GATEWAY_NAME 上
+type httpRouteExposer struct {
+	ctx            context.Context
+	kubernetesUtil *KubernetesUtil
+}
+
+func (e *httpRouteExposer) Expose(kbParam *model.KubernetesParam, application *model.Application) error {
+	if _, err := e.kubernetesUtil.CreateServiceOfType(kbParam, corev1.ServiceTypeClusterIP); err != nil {
+		return err
+	}
+
+	host := hostOf(kbParam)
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "HTTPRoute",
+		"metadata": map[string]interface{}{
+			"name":      routeNameOf(kbParam),
+			"namespace": kbParam.Namespace,
+			"labels": map[string]interface{}{
+				"app":        "code-server",
+				"deployment": kbParam.Deployment,
+			},
+		},
+		"spec": map[string]interface{}{
+			"parentRefs": []interface{}{
+				map[string]interface{}{
+					"name":      GetEnvOrDefault("EXPOSE_GATEWAY_NAME", "minics-gateway"),
+					"namespace": GetEnvOrDefault("EXPOSE_GATEWAY_NAMESPACE", "minics"),
+				},
+			},
+			"hostnames": []interface{}{host},
+			"rules": []interface{}{
+				map[string]interface{}{
+					"backendRefs": []interface{}{
+						map[string]interface{}{
+							"name": kbParam.Svc,
+							"port": int64(443),
+						},
+					},
+				},
+			},
+		},
+	}}
+
+	_, err := DynamicClient.Resource(httpRouteGVR).Namespace(kbParam.Namespace).Create(e.ctx, route, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		log.Printf("创建 HTTPRoute 失败: %v", err)
+		return fmt.Errorf("创建 HTTPRoute 失败: %w", err)
+	}
+
+	application.Url = urlOf(host)
+	return nil
+}
+
+func (e *httpRouteExposer) Unexpose(kbParam *model.KubernetesParam) error {
+	err := DynamicClient.Resource(httpRouteGVR).Namespace(kbParam.Namespace).Delete(e.ctx, routeNameOf(kbParam), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Printf("删除 HTTPRoute 失败: %v", err)
+		return fmt.Errorf("删除 HTTPRoute 失败: %w", err)
+	}
+	return e.kubernetesUtil.DeleteSvc(kbParam)
+}
+
+func suffixOf(kbParam *model.KubernetesParam) string {
+	return kbParam.Deployment[len(kbParam.Deployment)-8:]
+}
+
+func routeNameOf(kbParam *model.KubernetesParam) string {
+	return "route-" + suffixOf(kbParam)
+}
+
+func hostOf(kbParam *model.KubernetesParam) string {
+	return suffixOf(kbParam) + "." + GetEnvOrDefault("EXPOSE_BASE_DOMAIN", "minics.local")
+}
+
+// urlOf 根据是否在入口处终止 TLS 生成访问地址，EXPOSE_TLS_SECRET 或 EXPOSE_TLS=true 时使用 https
+func urlOf(host string) string {
+	if GetEnvOrDefault("EXPOSE_TLS_SECRET", "") != "" || GetEnvOrDefault("EXPOSE_TLS", "false") == "true" {
+		return "https://" + host
+	}
+	return "http://" + host
+}
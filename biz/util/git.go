@@ -0,0 +1,144 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"learn/biz/model"
+)
+
+// GitCloneContainer 克隆仓库的 init 容器名称
+const GitCloneContainer = "git-clone"
+
+// gitCloneScript 依次克隆 GIT_URL_<i> 指定的仓库，已存在的目录跳过，单个仓库失败不影响工作空间启动
+// 每个仓库的结果以 "目录\t状态\t信息" 的格式写入终止信息，由 GitCloneStatusOf 解析
+const gitCloneScript = `
+owner="${PUID:-1000}:${PGID:-1000}"
+: > /dev/termination-log
+i=0
+while [ "$i" -lt "$GIT_REPO_COUNT" ]; do
+	eval url=\$GIT_URL_$i
+	eval branch=\$GIT_BRANCH_$i
+	eval dir=\$GIT_DIR_$i
+	eval username=\${GIT_USERNAME_$i:-}
+	eval password=\${GIT_PASSWORD_$i:-}
+	target="$GIT_ROOT/$dir"
+
+	if [ -e "$target/.git" ]; then
+		state=exists
+		message=""
+	else
+		# top 为最上层不存在的目录，mkdir -p 以 root 身份创建的各级目录都在它下面，结束后一起改属主
+		top=""
+		d=$(dirname "$target")
+		while [ ! -e "$d" ]; do
+			top="$d"
+			d=$(dirname "$d")
+		done
+		mkdir -p "$(dirname "$target")"
+		set -- clone
+		if [ -n "$branch" ]; then
+			set -- "$@" --branch "$branch"
+		fi
+		if [ -n "$username" ]; then
+			output=$(GIT_USERNAME="$username" GIT_PASSWORD="$password" git -c credential.helper='!f() { echo "username=$GIT_USERNAME"; echo "password=$GIT_PASSWORD"; }; f' "$@" "$url" "$target" 2>&1)
+		else
+			output=$(git "$@" "$url" "$target" 2>&1)
+		fi
+		if [ $? -eq 0 ]; then
+			chown -R "$owner" "$target"
+			state=cloned
+			message=""
+		else
+			state=failed
+			message=$(echo "$output" | tail -n 1 | tr '\t' ' ' | cut -c1-200)
+		fi
+		if [ -n "$top" ]; then
+			chown -R "$owner" "$top"
+		fi
+	fi
+
+	printf '%s\t%s\t%s\n' "$dir" "$state" "$message" >> /dev/termination-log
+	i=$((i+1))
+done
+exit 0
+`
+
+// gitCloneContainer 构造克隆仓库的 init 容器，env 为模板中的环境变量（包含代理、PUID 等配置）
+func gitCloneContainer(repos []model.GitRepo, env []corev1.EnvVar, mountPath string) corev1.Container {
+	containerEnv := append([]corev1.EnvVar{}, env...)
+	containerEnv = append(containerEnv,
+		corev1.EnvVar{Name: "GIT_ROOT", Value: mountPath},
+		corev1.EnvVar{Name: "GIT_REPO_COUNT", Value: strconv.Itoa(len(repos))},
+		corev1.EnvVar{Name: "GIT_TERMINAL_PROMPT", Value: "0"},
+	)
+	for i, repo := range repos {
+		containerEnv = append(containerEnv,
+			corev1.EnvVar{Name: fmt.Sprintf("GIT_URL_%d", i), Value: repo.Url},
+			corev1.EnvVar{Name: fmt.Sprintf("GIT_BRANCH_%d", i), Value: repo.Branch},
+			corev1.EnvVar{Name: fmt.Sprintf("GIT_DIR_%d", i), Value: repo.Directory},
+		)
+		if repo.CredentialSecret != "" {
+			containerEnv = append(containerEnv,
+				secretEnv(fmt.Sprintf("GIT_USERNAME_%d", i), repo.CredentialSecret, "username"),
+				secretEnv(fmt.Sprintf("GIT_PASSWORD_%d", i), repo.CredentialSecret, "password"),
+			)
+		}
+	}
+
+	return corev1.Container{
+		Name:                     GitCloneContainer,
+		Image:                    GetEnvOrDefault("GIT_CLONE_IMAGE", "alpine/git:2.45.2"),
+		ImagePullPolicy:          corev1.PullIfNotPresent,
+		Command:                  []string{"sh", "-c", gitCloneScript},
+		Env:                      containerEnv,
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      "data",
+			MountPath: mountPath,
+		}},
+	}
+}
+
+func secretEnv(name, secret, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+// GitCloneStatusOf 从 Pod 的 init 容器状态中读取克隆结果，cloning 表示克隆仍在进行
+func GitCloneStatusOf(pod *corev1.Pod) (statuses []model.GitRepoStatus, cloning bool) {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name != GitCloneContainer {
+			continue
+		}
+		if status.State.Running != nil {
+			return nil, true
+		}
+		if status.State.Terminated == nil {
+			return nil, status.State.Waiting != nil
+		}
+
+		for _, line := range strings.Split(status.State.Terminated.Message, "\n") {
+			fields := strings.SplitN(line, "\t", 3)
+			if len(fields) < 2 {
+				continue
+			}
+			repoStatus := model.GitRepoStatus{Directory: fields[0], State: fields[1]}
+			if len(fields) == 3 {
+				repoStatus.Message = fields[2]
+			}
+			statuses = append(statuses, repoStatus)
+		}
+	}
+	return statuses, false
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"learn/biz/model"
+)
+
+// codeServerHealth code-server /healthz 的返回值，lastHeartbeat 为毫秒时间戳，
+// 有浏览器连接时 code-server 每分钟更新一次，从未连接过时为 0
+type codeServerHealth struct {
+	Status        string `json:"status"`
+	LastHeartbeat int64  `json:"lastHeartbeat"`
+}
+
+// CodeServerLastHeartbeat 读取工作空间 code-server 最后一次有浏览器连接的时间，从未连接过时返回零值
+// 通过 exec 在容器内访问 localhost，不经过 API Server 代理，不受只放行访问入口的 NetworkPolicy 影响
+func (s *KubernetesUtil) CodeServerLastHeartbeat(pod *corev1.Pod) (time.Time, error) {
+	port := int32(8080)
+	for _, container := range pod.Spec.Containers {
+		if container.Name == model.ContainerCodeServer && len(container.Ports) > 0 {
+			port = container.Ports[0].ContainerPort
+		}
+	}
+
+	// 模板镜像不一定带 curl，依次尝试 curl 和 wget
+	url := "http://127.0.0.1:" + strconv.Itoa(int(port)) + "/healthz"
+	command := []string{"sh", "-c", "curl -fsS " + url + " 2>/dev/null || wget -qO- " + url}
+
+	var stdout, stderr bytes.Buffer
+	err := s.ExecInNamedPod(pod.Namespace, pod.Name, model.ContainerCodeServer, command, remotecommand.StreamOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("访问 code-server /healthz 失败: %w, %s", err, stderr.String())
+	}
+
+	var health codeServerHealth
+	if err := json.Unmarshal(stdout.Bytes(), &health); err != nil {
+		return time.Time{}, fmt.Errorf("解析 code-server /healthz 失败: %w", err)
+	}
+	if health.LastHeartbeat <= 0 {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(health.LastHeartbeat), nil
+}
@@ -0,0 +1,124 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+
+	"learn/biz/config"
+	"learn/biz/model"
+)
+
+// WorkspaceCache 监听所有带 app=code-server 标签的 Pod、Deployment 和 Service 的本地缓存
+// 列表、状态查询和计时任务从这里读取，避免每次请求都访问 API Server
+type WorkspaceCache struct {
+	factory     informers.SharedInformerFactory
+	Pods        corelisters.PodLister
+	Deployments appslisters.DeploymentLister
+	Services    corelisters.ServiceLister
+}
+
+// Workspaces 全局共享的工作空间缓存，未初始化时相关查询会直接访问 API Server
+var Workspaces *WorkspaceCache
+
+// InitWorkspaceCache 启动 informer 并等待首次同步完成
+func InitWorkspaceCache() {
+	log.Printf("初始化工作空间缓存...")
+
+	factory := informers.NewSharedInformerFactoryWithOptions(config.KubernetesClient, 10*time.Minute,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = "app=code-server"
+		}))
+
+	workspaceCache := &WorkspaceCache{
+		factory:     factory,
+		Pods:        factory.Core().V1().Pods().Lister(),
+		Deployments: factory.Apps().V1().Deployments().Lister(),
+		Services:    factory.Core().V1().Services().Lister(),
+	}
+
+	factory.Start(context.Background().Done())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for informerType, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			log.Fatalf("工作空间缓存同步失败: %v", informerType)
+		}
+	}
+
+	Workspaces = workspaceCache
+	log.Printf("初始化工作空间缓存完毕")
+}
+
+// Shutdown 停止所有 informer
+func (w *WorkspaceCache) Shutdown() {
+	w.factory.Shutdown()
+}
+
+// GetCachedPod 从缓存中读取 Deployment 的 Pod，滚动更新期间优先返回运行中的 Pod
+// 缓存中的对象是共享的，调用方不能修改；需要更新 Pod 时应使用 GetPodInfo
+func (s *KubernetesUtil) GetCachedPod(kbParam *model.KubernetesParam) (*corev1.Pod, error) {
+	if Workspaces == nil {
+		return s.GetPodInfo(kbParam)
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{"app": "code-server", "deployment": kbParam.Deployment})
+	pods, err := Workspaces.Pods.Pods(kbParam.Namespace).List(selector)
+	if err != nil {
+		return nil, fmt.Errorf("获取Deployment的Pod列表失败: %w", err)
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("未找到Deployment %s 对应的Pod", kbParam.Deployment)
+	}
+
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			return pod, nil
+		}
+	}
+	return pods[0], nil
+}
+
+// GetCachedPodList 从缓存中读取命名空间下的工作空间 Pod
+func (s *KubernetesUtil) GetCachedPodList(namespace string) ([]corev1.Pod, error) {
+	if Workspaces == nil {
+		podList, err := s.GetPodList(&model.KubernetesParam{Namespace: namespace})
+		if err != nil {
+			return nil, err
+		}
+		return podList.Items, nil
+	}
+
+	pods, err := Workspaces.Pods.Pods(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	items := make([]corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		items = append(items, *pod)
+	}
+	return items, nil
+}
+
+// GetCachedDeployment 从缓存中读取 Deployment，返回的对象不能修改
+func (s *KubernetesUtil) GetCachedDeployment(kbParam *model.KubernetesParam) (*appsv1.Deployment, error) {
+	if Workspaces == nil {
+		return s.GetDeployment(kbParam)
+	}
+
+	deployment, err := Workspaces.Deployments.Deployments(kbParam.Namespace).Get(kbParam.Deployment)
+	if err != nil {
+		return nil, fmt.Errorf("获取Deployment信息失败: %w", err)
+	}
+	return deployment, nil
+}
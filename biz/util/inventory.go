@@ -0,0 +1,107 @@
+package util
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 工作空间资源的种类，与 Kubernetes 的 Kind 一致
+const (
+	KindDeployment = "Deployment"
+	KindService    = "Service"
+	KindPvc        = "PersistentVolumeClaim"
+	KindSecret     = "Secret"
+)
+
+// WorkspaceNamePrefixes 各种类工作空间资源的名称前缀，名称的后 8 位与 Deployment 相同
+var WorkspaceNamePrefixes = map[string]string{
+	KindDeployment: "deployment-",
+	KindService:    "svc-",
+	KindPvc:        "pvc-",
+	KindSecret:     "secret-",
+}
+
+// WorkspaceResources 命名空间中按名称前缀识别出的工作空间资源，键为资源种类，值为资源名称集合
+// Running 记录副本数大于 0 的 Deployment
+type WorkspaceResources struct {
+	Names   map[string]map[string]bool
+	Running map[string]bool
+}
+
+// ListUserNamespaces 列出所有 ns- 开头的用户命名空间
+func (s *KubernetesUtil) ListUserNamespaces() ([]string, error) {
+	namespaces, err := s.client.CoreV1().Namespaces().List(s.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, namespace := range namespaces.Items {
+		if strings.HasPrefix(namespace.Name, "ns-") {
+			names = append(names, namespace.Name)
+		}
+	}
+	return names, nil
+}
+
+// ListWorkspaceResources 列出命名空间中的工作空间 Deployment、Service、PVC 和密码 Secret
+// PVC 创建时没有打标签，只能按名称前缀识别
+func (s *KubernetesUtil) ListWorkspaceResources(namespace string) (*WorkspaceResources, error) {
+	resources := &WorkspaceResources{
+		Names:   make(map[string]map[string]bool),
+		Running: make(map[string]bool),
+	}
+	add := func(kind, name string) {
+		if !strings.HasPrefix(name, WorkspaceNamePrefixes[kind]) {
+			return
+		}
+		if resources.Names[kind] == nil {
+			resources.Names[kind] = make(map[string]bool)
+		}
+		resources.Names[kind][name] = true
+	}
+	options := metav1.ListOptions{LabelSelector: "app=code-server"}
+
+	deployments, err := s.client.AppsV1().Deployments(namespace).List(s.ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		add(KindDeployment, deployment.Name)
+		if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas > 0 {
+			resources.Running[deployment.Name] = true
+		}
+	}
+
+	services, err := s.client.CoreV1().Services(namespace).List(s.ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range services.Items {
+		add(KindService, service.Name)
+	}
+
+	pvcs, err := s.client.CoreV1().PersistentVolumeClaims(namespace).List(s.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pvc := range pvcs.Items {
+		add(KindPvc, pvc.Name)
+	}
+
+	secrets, err := s.client.CoreV1().Secrets(namespace).List(s.ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets.Items {
+		add(KindSecret, secret.Name)
+	}
+
+	return resources, nil
+}
+
+// Has 判断资源是否存在
+func (r *WorkspaceResources) Has(kind, name string) bool {
+	return r.Names[kind][name]
+}
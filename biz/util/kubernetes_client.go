@@ -14,53 +14,74 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 
 	"learn/biz/config"
 	"learn/biz/model"
 )
 
 type KubernetesUtil struct {
-	ctx context.Context
+	ctx    context.Context
+	client kubernetes.Interface
 }
 
 func NewKubernetesUtil(ctx context.Context) *KubernetesUtil {
-	return &KubernetesUtil{ctx: ctx}
+	return &KubernetesUtil{ctx: ctx, client: config.KubernetesClient}
 }
 
-func (s *KubernetesUtil) EnsureNamespace(namespace string) error {
+// NewKubernetesUtilWithClient 使用指定的客户端，测试时可传入 client-go 的 fake 客户端
+func NewKubernetesUtilWithClient(ctx context.Context, client kubernetes.Interface) *KubernetesUtil {
+	return &KubernetesUtil{ctx: ctx, client: client}
+}
+
+// WithContext 返回使用同一客户端和指定 context 的 KubernetesUtil
+func (s *KubernetesUtil) WithContext(ctx context.Context) *KubernetesUtil {
+	return &KubernetesUtil{ctx: ctx, client: s.client}
+}
+
+// EnsureNamespace 确保用户命名空间存在并同步网络隔离策略，quota 不为空时同步其 ResourceQuota 与 LimitRange
+func (s *KubernetesUtil) EnsureNamespace(namespace string, quota *model.NamespaceQuota) error {
 	// 先检查命名空间是否存在
-	_, err := config.KubernetesClient.CoreV1().Namespaces().Get(s.ctx, namespace, metav1.GetOptions{})
+	_, err := s.client.CoreV1().Namespaces().Get(s.ctx, namespace, metav1.GetOptions{})
 	if err != nil {
-		if errors.IsNotFound(err) {
-			// 命名空间不存在，创建它
-			ns := &corev1.Namespace{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: namespace,
-					Labels: map[string]string{
-						"created-by": "hertz",
-						"purpose":    "code-server",
-					},
-				},
-			}
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("检查命名空间失败: %w", err)
+		}
 
-			_, createErr := config.KubernetesClient.CoreV1().Namespaces().Create(s.ctx, ns, metav1.CreateOptions{})
-			if createErr != nil && !errors.IsAlreadyExists(createErr) {
-				return fmt.Errorf("创建命名空间失败: %w", createErr)
-			}
+		// 命名空间不存在，创建它
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+				Labels: map[string]string{
+					"created-by": "hertz",
+					"purpose":    "code-server",
+				},
+			},
+		}
 
-			log.Printf("命名空间 %s 创建成功\n", namespace)
-			return nil
+		_, createErr := s.client.CoreV1().Namespaces().Create(s.ctx, ns, metav1.CreateOptions{})
+		if createErr != nil && !errors.IsAlreadyExists(createErr) {
+			return fmt.Errorf("创建命名空间失败: %w", createErr)
 		}
-		return fmt.Errorf("检查命名空间失败: %w", err)
+
+		log.Printf("命名空间 %s 创建成功\n", namespace)
+	} else {
+		log.Printf("命名空间 %s 已存在\n", namespace)
 	}
 
-	log.Printf("命名空间 %s 已存在\n", namespace)
-	return nil
+	if err := s.EnsureNetworkPolicies(namespace); err != nil {
+		return err
+	}
+	if quota == nil {
+		return nil
+	}
+	return s.EnsureQuota(namespace, quota)
 }
 
 func (s *KubernetesUtil) GetPodList(param *model.KubernetesParam) (*corev1.PodList, error) {
 	// 获取 namespace 下的所有 Pod
-	pods, err := config.KubernetesClient.CoreV1().Pods(param.Namespace).List(s.ctx, metav1.ListOptions{})
+	pods, err := s.client.CoreV1().Pods(param.Namespace).List(s.ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -70,9 +91,34 @@ func (s *KubernetesUtil) GetPodList(param *model.KubernetesParam) (*corev1.PodLi
 	return pods, nil
 }
 
-func (s *KubernetesUtil) CreateDeployment(kbParam *model.KubernetesParam, appParam *model.AppParam) error {
+func (s *KubernetesUtil) CreateDeployment(kbParam *model.KubernetesParam, appParam *model.AppParam, template *model.WorkspaceTemplate) error {
 	replicas := int32(1) // 默认1个副本，您可以根据需要调整
 
+	resources, err := codeServerResources(appParam.Cpu, appParam.Memory)
+	if err != nil {
+		return err
+	}
+
+	// 模板中的环境变量在前，平台注入的密码放在最后，避免被模板覆盖；密码从 Secret 读取，不出现在 Deployment 中
+	templateEnv := make([]corev1.EnvVar, 0, len(template.Env))
+	for _, e := range template.Env {
+		templateEnv = append(templateEnv, corev1.EnvVar{Name: e.Name, Value: e.Value})
+	}
+	env := append(append([]corev1.EnvVar{}, templateEnv...), passwordEnv(kbParam)...)
+
+	ports := make([]corev1.ContainerPort, 0, len(template.Ports))
+	for _, p := range template.Ports {
+		protocol := corev1.Protocol(p.Protocol)
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		ports = append(ports, corev1.ContainerPort{
+			Name:          p.Name,
+			ContainerPort: p.ContainerPort,
+			Protocol:      protocol,
+		})
+	}
+
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      kbParam.Deployment, // 这里可能需要改为deploymentName之类的参数
@@ -84,6 +130,7 @@ func (s *KubernetesUtil) CreateDeployment(kbParam *model.KubernetesParam, appPar
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &replicas,
+			Strategy: recreateStrategy(),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app":        "code-server",
@@ -101,38 +148,17 @@ func (s *KubernetesUtil) CreateDeployment(kbParam *model.KubernetesParam, appPar
 					Containers: []corev1.Container{
 						{
 							Name:            "code-server",
-							Image:           "docker.1ms.run/linuxserver/code-server:4.103.0",
-							ImagePullPolicy: corev1.PullNever,
-							Env: []corev1.EnvVar{
-								{Name: "PUID", Value: "1000"},
-								{Name: "PGID", Value: "1000"},
-								{Name: "TZ", Value: "Etc/UTC"},
-								{Name: "PASSWORD", Value: appParam.PodPassword},
-								{Name: "SUDO_PASSWORD", Value: appParam.PodPassword},
-								{Name: "PWA_APPNAME", Value: "code-server"},
-								{Name: "HTTP_PROXY", Value: "http://223.2.19.172:3128"},
-								{Name: "http_proxy", Value: "http://223.2.19.172:3128"},
-								{Name: "HTTPS_PROXY", Value: "http://223.2.19.172:3128"},
-								{Name: "https_proxy", Value: "http://223.2.19.172:3128"},
-							},
-							Ports: []corev1.ContainerPort{{
-								ContainerPort: 8443,
-								Protocol:      corev1.ProtocolTCP,
-								Name:          "https",
-							}},
+							Image:           template.Image,
+							ImagePullPolicy: corev1.PullPolicy(template.ImagePullPolicy),
+							Env:             env,
+							Ports:           ports,
 							VolumeMounts: []corev1.VolumeMount{{
 								Name:      "data",
-								MountPath: "/config",
+								MountPath: template.MountPath,
 							}},
 							Resources: corev1.ResourceRequirements{
-								Requests: corev1.ResourceList{
-									corev1.ResourceCPU:    resource.MustParse(appParam.Cpu),
-									corev1.ResourceMemory: resource.MustParse(appParam.Memory),
-								},
-								Limits: corev1.ResourceList{
-									corev1.ResourceCPU:    resource.MustParse(appParam.Cpu),
-									corev1.ResourceMemory: resource.MustParse(appParam.Memory),
-								},
+								Requests: resources,
+								Limits:   resources.DeepCopy(),
 							},
 						},
 						{
@@ -177,7 +203,14 @@ func (s *KubernetesUtil) CreateDeployment(kbParam *model.KubernetesParam, appPar
 		},
 	}
 
-	_, err := config.KubernetesClient.AppsV1().Deployments(kbParam.Namespace).Create(s.ctx, deployment, metav1.CreateOptions{})
+	// 需要克隆仓库时，在 code-server 启动前先把仓库克隆到数据卷
+	if len(appParam.Repos) > 0 {
+		deployment.Spec.Template.Spec.InitContainers = []corev1.Container{
+			gitCloneContainer(appParam.Repos, templateEnv, template.MountPath),
+		}
+	}
+
+	_, err = s.client.AppsV1().Deployments(kbParam.Namespace).Create(s.ctx, deployment, metav1.CreateOptions{})
 	if errors.IsAlreadyExists(err) {
 		return nil
 	}
@@ -186,7 +219,7 @@ func (s *KubernetesUtil) CreateDeployment(kbParam *model.KubernetesParam, appPar
 
 func (s *KubernetesUtil) DeleteDeployment(kbParam *model.KubernetesParam) error {
 	// 4. 删除 Deployment
-	err := config.KubernetesClient.AppsV1().Deployments(kbParam.Namespace).Delete(s.ctx, kbParam.Deployment, metav1.DeleteOptions{})
+	err := s.client.AppsV1().Deployments(kbParam.Namespace).Delete(s.ctx, kbParam.Deployment, metav1.DeleteOptions{})
 	if errors.IsNotFound(err) {
 		log.Printf("删除 Deployment %s 失败: %v", kbParam.Deployment, err)
 		return nil
@@ -196,16 +229,23 @@ func (s *KubernetesUtil) DeleteDeployment(kbParam *model.KubernetesParam) error
 
 func (s *KubernetesUtil) ScaleDeployment(kbParam *model.KubernetesParam, replicas int32) error {
 	// 获取现有的Deployment
-	deployment, err := config.KubernetesClient.AppsV1().Deployments(kbParam.Namespace).Get(s.ctx, kbParam.Deployment, metav1.GetOptions{})
+	deployment, err := s.client.AppsV1().Deployments(kbParam.Namespace).Get(s.ctx, kbParam.Deployment, metav1.GetOptions{})
 	if err != nil {
 		return fmt.Errorf("获取Deployment信息失败: %w", err)
 	}
 
+	// 从停止状态启动时，顺带把数据库中记录的最新规格写回模板，此时没有运行中的Pod，不会造成额外重启
+	if replicas > 0 && kbParam.Cpu != "" && kbParam.Memory != "" {
+		if err := setCodeServerResources(&deployment.Spec.Template.Spec, kbParam); err != nil {
+			return err
+		}
+	}
+
 	// 修改副本数
 	deployment.Spec.Replicas = &replicas
 
 	// 更新Deployment
-	_, err = config.KubernetesClient.AppsV1().Deployments(kbParam.Namespace).Update(s.ctx, deployment, metav1.UpdateOptions{})
+	_, err = s.client.AppsV1().Deployments(kbParam.Namespace).Update(s.ctx, deployment, metav1.UpdateOptions{})
 	if err != nil {
 		return fmt.Errorf("更新Deployment副本数失败: %w", err)
 	}
@@ -215,6 +255,11 @@ func (s *KubernetesUtil) ScaleDeployment(kbParam *model.KubernetesParam, replica
 }
 
 func (s *KubernetesUtil) CreatePod(kbParam *model.KubernetesParam, appParam *model.AppParam) error {
+	resources, err := codeServerResources(appParam.Cpu, appParam.Memory)
+	if err != nil {
+		return err
+	}
+
 	// 3. 创建 Pod
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -248,14 +293,8 @@ func (s *KubernetesUtil) CreatePod(kbParam *model.KubernetesParam, appParam *mod
 						MountPath: "/config",
 					}},
 					Resources: corev1.ResourceRequirements{
-						Requests: corev1.ResourceList{
-							corev1.ResourceCPU:    resource.MustParse(appParam.Cpu),
-							corev1.ResourceMemory: resource.MustParse(appParam.Memory),
-						},
-						Limits: corev1.ResourceList{
-							corev1.ResourceCPU:    resource.MustParse(appParam.Cpu),
-							corev1.ResourceMemory: resource.MustParse(appParam.Memory),
-						},
+						Requests: resources,
+						Limits:   resources.DeepCopy(),
 					},
 				},
 				// 2. 新增的 heartbeater
@@ -299,15 +338,40 @@ func (s *KubernetesUtil) CreatePod(kbParam *model.KubernetesParam, appParam *mod
 		},
 	}
 
-	_, err := config.KubernetesClient.CoreV1().Pods(kbParam.Namespace).Create(s.ctx, pod, metav1.CreateOptions{})
+	_, err = s.client.CoreV1().Pods(kbParam.Namespace).Create(s.ctx, pod, metav1.CreateOptions{})
 	if errors.IsAlreadyExists(err) {
 		return nil // 已存在算成功
 	}
 	return err
 }
 
+// DefaultWorkspaceStorage 未指定容量时新建工作空间数据卷的容量
+const DefaultWorkspaceStorage = "20Gi"
+
+// StorageOf 返回工作空间数据卷的容量，未指定时使用默认值
+func StorageOf(appParam *model.AppParam) string {
+	if appParam.Storage == "" {
+		return DefaultWorkspaceStorage
+	}
+	return appParam.Storage
+}
+
 func (s *KubernetesUtil) CreatePvc(kbParam *model.KubernetesParam, appParam *model.AppParam) error {
-	storageClassName := "dynamic-hostpath"
+	return s.CreatePvcFromSource(kbParam, StorageOf(appParam), nil)
+}
+
+// CreatePvcFromSource 创建 PVC，dataSource 不为空时从快照或已有 PVC 复制数据
+// 存储类由 STORAGE_CLASS 配置，默认为 dynamic-hostpath，设置为 "-" 时不指定，使用集群的默认存储类
+func (s *KubernetesUtil) CreatePvcFromSource(kbParam *model.KubernetesParam, storage string, dataSource *corev1.TypedLocalObjectReference) error {
+	var storageClass *string
+	if name := GetEnvOrDefault("STORAGE_CLASS", "dynamic-hostpath"); name != "-" {
+		storageClass = &name
+	}
+
+	size, err := resource.ParseQuantity(storage)
+	if err != nil {
+		return fmt.Errorf("存储容量格式错误: %s", storage)
+	}
 
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
@@ -318,21 +382,107 @@ func (s *KubernetesUtil) CreatePvc(kbParam *model.KubernetesParam, appParam *mod
 			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
 			Resources: corev1.VolumeResourceRequirements{
 				Requests: corev1.ResourceList{
-					corev1.ResourceStorage: resource.MustParse("20Gi"),
+					corev1.ResourceStorage: size,
 				},
 			},
-			StorageClassName: &storageClassName,
+			StorageClassName: storageClass,
+			DataSource:       dataSource,
 		},
 	}
-	if _, err := config.KubernetesClient.CoreV1().PersistentVolumeClaims(kbParam.Namespace).Create(s.ctx, pvc, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
-		log.Printf("创建 PVC 失败: %w", err)
+	if _, err := s.client.CoreV1().PersistentVolumeClaims(kbParam.Namespace).Create(s.ctx, pvc, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+		log.Printf("创建 PVC 失败: %v", err)
 		return fmt.Errorf("创建 PVC 失败: %w", err)
 	}
 
 	return nil
 }
 
+func (s *KubernetesUtil) GetSecret(namespace, name string) (*corev1.Secret, error) {
+	secret, err := s.client.CoreV1().Secrets(namespace).Get(s.ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取 Secret %s 失败: %w", name, err)
+	}
+	return secret, nil
+}
+
+func (s *KubernetesUtil) GetPvc(kbParam *model.KubernetesParam) (*corev1.PersistentVolumeClaim, error) {
+	pvc, err := s.client.CoreV1().PersistentVolumeClaims(kbParam.Namespace).Get(s.ctx, kbParam.Pvc, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取 PVC 失败: %w", err)
+	}
+	return pvc, nil
+}
+
+func (s *KubernetesUtil) DeletePvc(kbParam *model.KubernetesParam) error {
+	if err := s.client.CoreV1().PersistentVolumeClaims(kbParam.Namespace).Delete(s.ctx, kbParam.Pvc, metav1.DeleteOptions{}); err != nil {
+		log.Printf("删除 PVC 失败: %v", err)
+		return fmt.Errorf("删除 PVC 失败: %w", err)
+	}
+	return nil
+}
+
+// SetDeploymentPvc 把 Deployment 的数据卷换成指定的 PVC，副本数为 0 时修改不会创建新的 Pod
+func (s *KubernetesUtil) SetDeploymentPvc(kbParam *model.KubernetesParam, pvc string) error {
+	deployment, err := s.client.AppsV1().Deployments(kbParam.Namespace).Get(s.ctx, kbParam.Deployment, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("获取Deployment信息失败: %w", err)
+	}
+
+	for i, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.Name != "data" || volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		deployment.Spec.Template.Spec.Volumes[i].PersistentVolumeClaim.ClaimName = pvc
+		if _, err := s.client.AppsV1().Deployments(kbParam.Namespace).Update(s.ctx, deployment, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("更新Deployment数据卷失败: %w", err)
+		}
+		log.Printf("Deployment %s 的数据卷已切换为 %s", kbParam.Deployment, pvc)
+		return nil
+	}
+	return fmt.Errorf("Deployment %s 中未找到数据卷", kbParam.Deployment)
+}
+
+// DataMountPath 返回 Deployment 中 code-server 容器挂载数据卷的路径，模板修改后已创建的工作空间仍沿用创建时的路径
+func DataMountPath(deployment *appsv1.Deployment) (string, error) {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name != model.ContainerCodeServer {
+			continue
+		}
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == "data" {
+				return mount.MountPath, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("Deployment %s 中未找到数据卷", deployment.Name)
+}
+
+// WaitPvcBound 等待 PVC 绑定完成，克隆卷在数据复制结束后才会进入 Bound 状态
+func (s *KubernetesUtil) WaitPvcBound(kbParam *model.KubernetesParam, timeout time.Duration) error {
+	return wait.PollUntilContextTimeout(s.ctx, 3*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		pvc, err := s.client.CoreV1().PersistentVolumeClaims(kbParam.Namespace).Get(ctx, kbParam.Pvc, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return pvc.Status.Phase == corev1.ClaimBound, nil
+	})
+}
+
 func (s *KubernetesUtil) CreateSvc(kbParam *model.KubernetesParam, application *model.Application) error {
+	result, err := s.CreateServiceOfType(kbParam, corev1.ServiceTypeNodePort)
+	if err != nil {
+		return err
+	}
+
+	nodePort := result.Spec.Ports[0].NodePort
+	application.Url = fmt.Sprintf("http://%s:%d", GetEnvOrDefault("NODE_ADDRESS", "223.2.19.172"), nodePort)
+	log.Printf("分配的NodePort端口: %d", nodePort)
+
+	return nil
+}
+
+// CreateServiceOfType 创建指向工作空间 Pod 的 Service，已存在时返回现有对象
+func (s *KubernetesUtil) CreateServiceOfType(kbParam *model.KubernetesParam, serviceType corev1.ServiceType) (*corev1.Service, error) {
 	// 3. 构造 Service 对象
 	svc := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -353,29 +503,27 @@ func (s *KubernetesUtil) CreateSvc(kbParam *model.KubernetesParam, application *
 			Ports: []corev1.ServicePort{
 				{
 					Name:       "web",
-					Port:       443,                    // Service 自己的端口
-					TargetPort: intstr.FromInt32(8443), // 目标 Pod 的端口
+					Port:       443,                                     // Service 自己的端口
+					TargetPort: intstr.FromInt32(targetPortOf(kbParam)), // 目标 Pod 的端口
 					Protocol:   corev1.ProtocolTCP,
 				},
 			},
-			Type: corev1.ServiceTypeNodePort, // 可改成 NodePort / LoadBalancer
+			Type: serviceType,
 		},
 	}
 
 	// 4. 调用 API 创建
-	result, err := config.KubernetesClient.CoreV1().
+	result, err := s.client.CoreV1().
 		Services(kbParam.Namespace).
 		Create(s.ctx, svc, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return s.client.CoreV1().Services(kbParam.Namespace).Get(s.ctx, kbParam.Svc, metav1.GetOptions{})
+	}
 	if err != nil {
-		log.Printf("创建 Service 失败: %w", err)
-		return err
+		log.Printf("创建 Service 失败: %v", err)
+		return nil, err
 	}
-
-	nodePort := result.Spec.Ports[0].NodePort
-	application.Url = fmt.Sprintf("http://223.2.19.172:%d", nodePort)
-	log.Printf("分配的NodePort端口: %d", nodePort)
-
-	return nil
+	return result, nil
 }
 
 func (s *KubernetesUtil) CreateSvcWithUpdate(kbParam *model.KubernetesParam, application *model.Application) error {
@@ -397,8 +545,8 @@ func (s *KubernetesUtil) CreateSvcWithUpdate(kbParam *model.KubernetesParam, app
 			Ports: []corev1.ServicePort{
 				{
 					Name:       "web",
-					Port:       443,                    // Service 自己的端口
-					TargetPort: intstr.FromInt32(8443), // 目标 Pod 的端口
+					Port:       443,                                     // Service 自己的端口
+					TargetPort: intstr.FromInt32(targetPortOf(kbParam)), // 目标 Pod 的端口
 					Protocol:   corev1.ProtocolTCP,
 				},
 			},
@@ -406,11 +554,11 @@ func (s *KubernetesUtil) CreateSvcWithUpdate(kbParam *model.KubernetesParam, app
 		},
 	}
 	// 4. 调用 API 创建
-	result, err := config.KubernetesClient.CoreV1().
+	result, err := s.client.CoreV1().
 		Services(kbParam.Namespace).
 		Update(s.ctx, svc, metav1.UpdateOptions{})
 	if err != nil {
-		log.Printf("创建 Service 失败: %w", err)
+		log.Printf("创建 Service 失败: %v", err)
 		return err
 	}
 
@@ -423,50 +571,213 @@ func (s *KubernetesUtil) CreateSvcWithUpdate(kbParam *model.KubernetesParam, app
 
 func (s *KubernetesUtil) DeleteSvc(kbParam *model.KubernetesParam) error {
 
-	if err := config.KubernetesClient.CoreV1().Services(kbParam.Namespace).Delete(s.ctx, kbParam.Svc, metav1.DeleteOptions{}); err != nil {
+	if err := s.client.CoreV1().Services(kbParam.Namespace).Delete(s.ctx, kbParam.Svc, metav1.DeleteOptions{}); err != nil {
 		log.Printf("删除 Service 失败: %v", err)
 		return fmt.Errorf("删除 Service 失败: %w", err)
 	}
 	return nil
 }
 
-func (s *KubernetesUtil) ModifyPodResource(kbParam *model.KubernetesParam) error {
-	// 获取现有Pod
-	pod, err := config.KubernetesClient.CoreV1().Pods(kbParam.Namespace).Get(s.ctx, kbParam.Pod, metav1.GetOptions{})
+// podResizeTimeout 等待 kubelet 完成原地调整的最长时间
+const podResizeTimeout = 30 * time.Second
+
+// ResizePodInPlace 通过 pods/resize 子资源原地调整 Deployment 当前 Pod 的资源，不重启容器，
+// 等到容器实际生效的资源与新规格一致后，再把新规格写入 Pod 所属的 ReplicaSet 与 Deployment 模板，使之后的发布不会回退到旧规格
+// 集群不支持原地扩缩容、节点资源不足、调整被推迟或超时、模板同步失败时返回错误，由调用方回退到重建更新
+func (s *KubernetesUtil) ResizePodInPlace(kbParam *model.KubernetesParam) error {
+	pod, err := s.livePodOf(kbParam)
+	if err != nil {
+		return err
+	}
+
+	if err := setCodeServerResources(&pod.Spec, kbParam); err != nil {
+		return err
+	}
+
+	if _, err := s.client.CoreV1().Pods(kbParam.Namespace).UpdateResize(s.ctx, pod.Name, pod, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("原地调整Pod资源失败: %w", err)
+	}
+	if err := s.waitPodResized(kbParam, pod.Name); err != nil {
+		return err
+	}
+
+	if err := s.syncTemplateResources(kbParam, pod); err != nil {
+		return err
+	}
+
+	log.Printf("成功原地调整Pod %s 的资源: CPU=%s, Memory=%s", pod.Name, kbParam.Cpu, kbParam.Memory)
+	return nil
+}
+
+// livePodOf 返回 Deployment 中未处于删除过程的 Pod，滚动更新期间旧 Pod 可能仍在终止
+func (s *KubernetesUtil) livePodOf(kbParam *model.KubernetesParam) (*corev1.Pod, error) {
+	pods, err := s.client.CoreV1().Pods(kbParam.Namespace).List(s.ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=code-server,deployment=%s", kbParam.Deployment),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取Deployment的Pod列表失败: %w", err)
+	}
+	for i := range pods.Items {
+		if pods.Items[i].DeletionTimestamp == nil {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("未找到Deployment %s 对应的运行中Pod", kbParam.Deployment)
+}
+
+// waitPodResized 轮询 Pod 状态，直到 code-server 容器实际生效的资源与新规格一致
+// kubelet 判定节点无法满足（Infeasible）、暂时推迟（Deferred）或调整出错时立即返回错误
+func (s *KubernetesUtil) waitPodResized(kbParam *model.KubernetesParam, name string) error {
+	desired, err := codeServerResources(kbParam.Cpu, kbParam.Memory)
 	if err != nil {
-		return fmt.Errorf("获取Pod信息失败: %w", err)
+		return err
 	}
 
-	// 修改第一个容器的资源限制
-	if len(pod.Spec.Containers) > 0 {
-		pod.Spec.Containers[0].Resources.Limits = corev1.ResourceList{
-			corev1.ResourceCPU:    resource.MustParse(kbParam.Cpu),
-			corev1.ResourceMemory: resource.MustParse(kbParam.Memory),
+	err = wait.PollUntilContextTimeout(s.ctx, time.Second, podResizeTimeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := s.client.CoreV1().Pods(kbParam.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, condition := range pod.Status.Conditions {
+			switch {
+			case condition.Type == corev1.PodResizePending && condition.Reason == corev1.PodReasonInfeasible:
+				return false, fmt.Errorf("节点无法满足新的资源规格: %s", condition.Message)
+			case condition.Type == corev1.PodResizePending && condition.Reason == corev1.PodReasonDeferred:
+				return false, fmt.Errorf("节点暂时无法满足新的资源规格: %s", condition.Message)
+			case condition.Type == corev1.PodResizeInProgress && condition.Reason == corev1.PodReasonError:
+				return false, fmt.Errorf("原地调整Pod资源出错: %s", condition.Message)
+			}
 		}
-		pod.Spec.Containers[0].Resources.Requests = corev1.ResourceList{
-			corev1.ResourceCPU:    resource.MustParse(kbParam.Cpu),
-			corev1.ResourceMemory: resource.MustParse(kbParam.Memory),
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == model.ContainerCodeServer {
+				return status.Resources != nil &&
+					sameResources(status.Resources.Requests, desired) &&
+					sameResources(status.Resources.Limits, desired), nil
+			}
 		}
+		return false, nil
+	})
+	if wait.Interrupted(err) {
+		return fmt.Errorf("等待Pod %s 原地调整资源超时", name)
 	}
+	return err
+}
 
-	// 更新Pod
-	_, err = config.KubernetesClient.CoreV1().Pods(kbParam.Namespace).Update(s.ctx, pod, metav1.UpdateOptions{})
+// sameResources 比较 CPU 与内存的数值，不关心单位写法
+func sameResources(actual, desired corev1.ResourceList) bool {
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		quantity, ok := actual[name]
+		if !ok || quantity.Cmp(desired[name]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// syncTemplateResources 在原地调整 Pod 后修改模板
+// 先修改 Pod 所属的 ReplicaSet，Deployment 控制器忽略 pod-template-hash 比较模板，
+// 修改后的 Deployment 模板与该 ReplicaSet 一致，不会创建新的 ReplicaSet，也就不会重建 Pod
+func (s *KubernetesUtil) syncTemplateResources(kbParam *model.KubernetesParam, pod *corev1.Pod) error {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "ReplicaSet" {
+		return fmt.Errorf("Pod %s 不属于任何 ReplicaSet", pod.Name)
+	}
+
+	replicaSets := s.client.AppsV1().ReplicaSets(kbParam.Namespace)
+	replicaSet, err := replicaSets.Get(s.ctx, owner.Name, metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("更新Pod资源失败: %w", err)
+		return fmt.Errorf("获取ReplicaSet信息失败: %w", err)
+	}
+	if err := setCodeServerResources(&replicaSet.Spec.Template.Spec, kbParam); err != nil {
+		return err
+	}
+	if _, err := replicaSets.Update(s.ctx, replicaSet, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("更新ReplicaSet资源失败: %w", err)
 	}
 
-	log.Printf("成功修改Pod %s 的资源限制: CPU=%s, Memory=%s", kbParam.Pod, kbParam.Cpu, kbParam.Memory)
+	if _, err := s.UpdateDeploymentResources(kbParam); err != nil {
+		return err
+	}
 	return nil
 }
 
+// UpdateDeploymentResources 修改 Deployment 模板中的资源规格，副本数大于0时会先停止旧 Pod 再按新规格重建
+func (s *KubernetesUtil) UpdateDeploymentResources(kbParam *model.KubernetesParam) (*appsv1.Deployment, error) {
+	deployment, err := s.client.AppsV1().Deployments(kbParam.Namespace).Get(s.ctx, kbParam.Deployment, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取Deployment信息失败: %w", err)
+	}
+
+	if err := setCodeServerResources(&deployment.Spec.Template.Spec, kbParam); err != nil {
+		return nil, err
+	}
+	// 早期创建的 Deployment 使用默认的滚动更新，新旧 Pod 同时运行需要两倍的配额，数据卷也只能挂载到一个节点
+	deployment.Spec.Strategy = recreateStrategy()
+
+	result, err := s.client.AppsV1().Deployments(kbParam.Namespace).Update(s.ctx, deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("更新Deployment资源失败: %w", err)
+	}
+
+	log.Printf("成功修改Deployment %s 的资源: CPU=%s, Memory=%s", kbParam.Deployment, kbParam.Cpu, kbParam.Memory)
+	return result, nil
+}
+
+func (s *KubernetesUtil) GetDeployment(kbParam *model.KubernetesParam) (*appsv1.Deployment, error) {
+	deployment, err := s.client.AppsV1().Deployments(kbParam.Namespace).Get(s.ctx, kbParam.Deployment, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取Deployment信息失败: %w", err)
+	}
+	return deployment, nil
+}
+
+// recreateStrategy 工作空间只有一个副本并独占数据卷，更新时先删除旧 Pod 再创建新 Pod
+func recreateStrategy() appsv1.DeploymentStrategy {
+	return appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+}
+
+// codeServerResources 解析 code-server 容器的 CPU/内存，requests 与 limits 使用同一份规格
+func codeServerResources(cpu, memory string) (corev1.ResourceList, error) {
+	cpuQuantity, err := resource.ParseQuantity(cpu)
+	if err != nil {
+		return nil, fmt.Errorf("CPU 格式错误: %s", cpu)
+	}
+	memoryQuantity, err := resource.ParseQuantity(memory)
+	if err != nil {
+		return nil, fmt.Errorf("内存格式错误: %s", memory)
+	}
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    cpuQuantity,
+		corev1.ResourceMemory: memoryQuantity,
+	}, nil
+}
+
+// setCodeServerResources 把 kbParam 中的 CPU/内存写入 code-server 容器的 requests 与 limits
+func setCodeServerResources(spec *corev1.PodSpec, kbParam *model.KubernetesParam) error {
+	resources, err := codeServerResources(kbParam.Cpu, kbParam.Memory)
+	if err != nil {
+		return err
+	}
+
+	for i := range spec.Containers {
+		if spec.Containers[i].Name != "code-server" {
+			continue
+		}
+		spec.Containers[i].Resources.Requests = resources
+		spec.Containers[i].Resources.Limits = resources.DeepCopy()
+		return nil
+	}
+	return fmt.Errorf("未找到 code-server 容器")
+}
+
 func (s *KubernetesUtil) DeletePodSvc(kbParam *model.KubernetesParam) error {
-	err := config.KubernetesClient.CoreV1().Pods(kbParam.Namespace).Delete(s.ctx, kbParam.Pod, metav1.DeleteOptions{})
+	err := s.client.CoreV1().Pods(kbParam.Namespace).Delete(s.ctx, kbParam.Pod, metav1.DeleteOptions{})
 	if err != nil {
 		log.Printf("删除 Pod 失败: %v", err)
 		return fmt.Errorf("删除 Pod 失败: %w", err)
 	}
 
-	if err := config.KubernetesClient.CoreV1().Services(kbParam.Namespace).Delete(s.ctx, kbParam.Svc, metav1.DeleteOptions{}); err != nil {
+	if err := s.client.CoreV1().Services(kbParam.Namespace).Delete(s.ctx, kbParam.Svc, metav1.DeleteOptions{}); err != nil {
 		log.Printf("删除 Service 失败: %v", err)
 		return fmt.Errorf("删除 Service 失败: %w", err)
 	}
@@ -475,18 +786,18 @@ func (s *KubernetesUtil) DeletePodSvc(kbParam *model.KubernetesParam) error {
 }
 
 func (s *KubernetesUtil) DeleteDeploymentSvcPvc(kbParam *model.KubernetesParam) error {
-	err := config.KubernetesClient.AppsV1().Deployments(kbParam.Namespace).Delete(s.ctx, kbParam.Deployment, metav1.DeleteOptions{})
+	err := s.client.AppsV1().Deployments(kbParam.Namespace).Delete(s.ctx, kbParam.Deployment, metav1.DeleteOptions{})
 	if errors.IsNotFound(err) {
 		log.Printf("删除 Deployment %s 失败: %v", kbParam.Deployment, err)
 		return fmt.Errorf("删除 Deployment %s 失败: %w", kbParam.Deployment, err)
 	}
 
-	if err := config.KubernetesClient.CoreV1().Services(kbParam.Namespace).Delete(s.ctx, kbParam.Svc, metav1.DeleteOptions{}); err != nil {
+	if err := s.client.CoreV1().Services(kbParam.Namespace).Delete(s.ctx, kbParam.Svc, metav1.DeleteOptions{}); err != nil {
 		log.Printf("删除 Service 失败: %v", err)
 		return fmt.Errorf("删除 Service 失败: %w", err)
 	}
 
-	if err := config.KubernetesClient.CoreV1().PersistentVolumeClaims(kbParam.Namespace).Delete(s.ctx, kbParam.Pvc, metav1.DeleteOptions{}); err != nil {
+	if err := s.client.CoreV1().PersistentVolumeClaims(kbParam.Namespace).Delete(s.ctx, kbParam.Pvc, metav1.DeleteOptions{}); err != nil {
 		log.Printf("删除 PVC 失败: %v", err)
 		return fmt.Errorf("删除 PVC 失败: %w", err)
 	}
@@ -497,17 +808,17 @@ func (s *KubernetesUtil) DeleteDeploymentSvcPvc(kbParam *model.KubernetesParam)
 }
 
 func (s *KubernetesUtil) DeletePodSvcPvc(kbParam *model.KubernetesParam) error {
-	if err := config.KubernetesClient.CoreV1().Pods(kbParam.Namespace).Delete(s.ctx, kbParam.Pod, metav1.DeleteOptions{}); err != nil {
+	if err := s.client.CoreV1().Pods(kbParam.Namespace).Delete(s.ctx, kbParam.Pod, metav1.DeleteOptions{}); err != nil {
 		log.Printf("删除 Pod 失败: %v", err)
 		return fmt.Errorf("删除 Pod 失败: %w", err)
 	}
 
-	if err := config.KubernetesClient.CoreV1().Services(kbParam.Namespace).Delete(s.ctx, kbParam.Svc, metav1.DeleteOptions{}); err != nil {
+	if err := s.client.CoreV1().Services(kbParam.Namespace).Delete(s.ctx, kbParam.Svc, metav1.DeleteOptions{}); err != nil {
 		log.Printf("删除 Service 失败: %v", err)
 		return fmt.Errorf("删除 Service 失败: %w", err)
 	}
 
-	if err := config.KubernetesClient.CoreV1().PersistentVolumeClaims(kbParam.Namespace).Delete(s.ctx, kbParam.Pvc, metav1.DeleteOptions{}); err != nil {
+	if err := s.client.CoreV1().PersistentVolumeClaims(kbParam.Namespace).Delete(s.ctx, kbParam.Pvc, metav1.DeleteOptions{}); err != nil {
 		log.Printf("删除 PVC 失败: %v", err)
 		return fmt.Errorf("删除 PVC 失败: %w", err)
 	}
@@ -521,7 +832,7 @@ func (s *KubernetesUtil) GetPodInfo(kbParam *model.KubernetesParam) (*corev1.Pod
 	// 使用Deployment的标签选择器获取Pod
 	labelSelector := fmt.Sprintf("app=code-server,deployment=%s", kbParam.Deployment)
 
-	pods, err := config.KubernetesClient.CoreV1().Pods(kbParam.Namespace).List(s.ctx, metav1.ListOptions{
+	pods, err := s.client.CoreV1().Pods(kbParam.Namespace).List(s.ctx, metav1.ListOptions{
 		LabelSelector: labelSelector,
 	})
 	if err != nil {
@@ -537,27 +848,24 @@ func (s *KubernetesUtil) GetPodInfo(kbParam *model.KubernetesParam) (*corev1.Pod
 	return &pod, nil
 }
 
-func (s *KubernetesUtil) GetPodLog(kbParam *model.KubernetesParam) (string, error) {
-	// 使用Deployment的标签选择器获取Pod
+// WaitPodsGone 等待 Deployment 的 Pod 全部退出，正在终止的 Pod 也要等到删除完成
+func (s *KubernetesUtil) WaitPodsGone(kbParam *model.KubernetesParam, timeout time.Duration) error {
 	labelSelector := fmt.Sprintf("app=code-server,deployment=%s", kbParam.Deployment)
-
-	pods, err := config.KubernetesClient.CoreV1().Pods(kbParam.Namespace).List(s.ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
+	return wait.PollUntilContextTimeout(s.ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := s.client.CoreV1().Pods(kbParam.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labelSelector,
+		})
+		if err != nil {
+			return false, err
+		}
+		return len(pods.Items) == 0, nil
 	})
-	if err != nil {
-		return "", fmt.Errorf("获取Deployment的Pod列表失败: %w", err)
-	}
-
-	if len(pods.Items) == 0 {
-		return "", fmt.Errorf("未找到Deployment %s 对应的Pod", kbParam.Deployment)
-	}
+}
 
-	// 获取第一个Pod的日志
-	pod := pods.Items[0]
-	req := config.KubernetesClient.CoreV1().Pods(kbParam.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{})
-	podLogs, err := req.Stream(s.ctx)
+func (s *KubernetesUtil) GetPodLog(kbParam *model.KubernetesParam) (string, error) {
+	podLogs, err := s.StreamPodLog(kbParam, &corev1.PodLogOptions{})
 	if err != nil {
-		return "", fmt.Errorf("获取Pod日志失败: %w", err)
+		return "", err
 	}
 
 	defer func(podLogs io.ReadCloser) {
@@ -575,6 +883,26 @@ func (s *KubernetesUtil) GetPodLog(kbParam *model.KubernetesParam) (string, erro
 	return string(data), nil
 }
 
-func CreateHttpRoute() {
+// StreamPodLog 打开 Deployment 第一个 Pod 的日志流，调用方负责关闭
+// options.Follow 为 true 时流会一直保持，直到 ctx 取消或容器退出
+func (s *KubernetesUtil) StreamPodLog(kbParam *model.KubernetesParam, options *corev1.PodLogOptions) (io.ReadCloser, error) {
+	pod, err := s.GetPodInfo(kbParam)
+	if err != nil {
+		return nil, err
+	}
 
+	req := s.client.CoreV1().Pods(kbParam.Namespace).GetLogs(pod.Name, options)
+	podLogs, err := req.Stream(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取Pod日志失败: %w", err)
+	}
+	return podLogs, nil
+}
+
+// targetPortOf 返回 Service 需要转发到的容器端口，未指定时沿用 code-server 默认的 8443
+func targetPortOf(kbParam *model.KubernetesParam) int32 {
+	if kbParam.Port > 0 {
+		return kbParam.Port
+	}
+	return 8443
 }
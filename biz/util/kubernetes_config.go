@@ -0,0 +1,50 @@
+package util
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	// RestConfig 访问集群使用的连接配置，exec 等需要直接构造请求的场景会用到
+	RestConfig *rest.Config
+	// DynamicClient 用于操作 VolumeSnapshot、HTTPRoute 等没有内置类型的资源
+	DynamicClient dynamic.Interface
+)
+
+// InitDynamicClient 初始化动态客户端，优先使用集群内配置，其次使用 KUBECONFIG 或 ~/.kube/config
+func InitDynamicClient() {
+	log.Printf("初始化 Kubernetes 动态客户端...")
+
+	cfg, err := loadRestConfig()
+	if err != nil {
+		log.Fatalf("加载 Kubernetes 配置失败: %v", err)
+	}
+
+	client, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		log.Fatalf("创建 Kubernetes 动态客户端失败: %v", err)
+	}
+
+	RestConfig = cfg
+	DynamicClient = client
+	log.Printf("初始化 Kubernetes 动态客户端完毕")
+}
+
+func loadRestConfig() (*rest.Config, error) {
+	if cfg, err := rest.InClusterConfig(); err == nil {
+		return cfg, nil
+	}
+
+	kubeconfig := os.Getenv("KUBECONFIG")
+	if kubeconfig == "" {
+		home, _ := os.UserHomeDir()
+		kubeconfig = filepath.Join(home, ".kube", "config")
+	}
+	return clientcmd.BuildConfigFromFlags("", kubeconfig)
+}
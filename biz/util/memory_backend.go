@@ -0,0 +1,377 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"learn/biz/model"
+)
+
+// memoryBasePort 进程内后端分配访问端口的起始值，与 NodePort 的范围保持一致
+const memoryBasePort = 30000
+
+// MemoryBackend 完全在进程内模拟工作空间的后端，不需要集群，供测试和本地 --dev 模式使用
+// 工作空间只有状态没有真正运行的容器，访问地址仅用于展示
+// 密码、数据卷和工作空间分别保存，与 Kubernetes 后端一样可以逐个创建和回滚
+type MemoryBackend struct {
+	mu         sync.Mutex
+	quotas     map[string]*model.NamespaceQuota
+	secrets    map[string]string
+	volumes    map[string]string
+	workspaces map[string]*memoryWorkspace
+	nextPort   int32
+}
+
+type memoryWorkspace struct {
+	namespace  string
+	deployment string
+	image      string
+	request    corev1.ResourceList
+	replicas   int32
+	port       int32
+	exposed    bool
+	startedAt  time.Time
+	logs       []string
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		quotas:     make(map[string]*model.NamespaceQuota),
+		secrets:    make(map[string]string),
+		volumes:    make(map[string]string),
+		workspaces: make(map[string]*memoryWorkspace),
+		nextPort:   memoryBasePort,
+	}
+}
+
+// WithContext 进程内后端不需要 context，返回自身
+func (b *MemoryBackend) WithContext(ctx context.Context) WorkspaceBackend {
+	return b
+}
+
+func (b *MemoryBackend) EnsureTenant(namespace string, quota *model.NamespaceQuota, request corev1.ResourceList) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if quota != nil {
+		b.quotas[namespace] = quota
+	}
+	if request == nil || b.quotas[namespace] == nil {
+		return nil
+	}
+
+	hard, err := quotaHardOf(b.quotas[namespace])
+	if err != nil {
+		return err
+	}
+	return checkQuotaUsage(hard, nil, b.usedOf(namespace), request)
+}
+
+// usedOf 统计命名空间的已用量，与 ResourceQuota 一致：数据卷始终计入，CPU、内存和 Pod 只计算运行中的工作空间
+func (b *MemoryBackend) usedOf(namespace string) corev1.ResourceList {
+	used := corev1.ResourceList{}
+	for _, workspace := range b.workspaces {
+		if workspace.namespace != namespace {
+			continue
+		}
+		for name, quantity := range workspace.request {
+			switch name {
+			case corev1.ResourceRequestsStorage, corev1.ResourcePersistentVolumeClaims:
+			default:
+				if workspace.replicas == 0 {
+					continue
+				}
+			}
+			total := used[name].DeepCopy()
+			total.Add(quantity)
+			used[name] = total
+		}
+	}
+	return used
+}
+
+func (b *MemoryBackend) CreateSecret(kbParam *model.KubernetesParam, password string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.secrets[kbParam.Namespace+"/"+kbParam.Secret] = password
+	return nil
+}
+
+func (b *MemoryBackend) DeleteSecret(kbParam *model.KubernetesParam) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.secrets, kbParam.Namespace+"/"+kbParam.Secret)
+	return nil
+}
+
+func (b *MemoryBackend) CreateVolume(kbParam *model.KubernetesParam, appParam *model.AppParam) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := kbParam.Namespace + "/" + kbParam.Pvc
+	if _, ok := b.volumes[key]; !ok {
+		b.volumes[key] = StorageOf(appParam)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) DeleteVolume(kbParam *model.KubernetesParam) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.volumes, kbParam.Namespace+"/"+kbParam.Pvc)
+	return nil
+}
+
+// CloneVolume 复制源数据卷，进程内的数据卷只有容量，创建后立即可用
+func (b *MemoryBackend) CloneVolume(kbParam, source *model.KubernetesParam, storage string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.volumes[source.Namespace+"/"+source.Pvc]; !ok {
+		return fmt.Errorf("创建PVC失败: 源数据卷 %s 不存在", source.Pvc)
+	}
+	key := kbParam.Namespace + "/" + kbParam.Pvc
+	if _, ok := b.volumes[key]; !ok {
+		b.volumes[key] = storage
+	}
+	return nil
+}
+
+func (b *MemoryBackend) VolumeSize(kbParam *model.KubernetesParam) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	storage, ok := b.volumes[kbParam.Namespace+"/"+kbParam.Pvc]
+	if !ok {
+		return "", fmt.Errorf("获取PVC失败: 数据卷 %s 不存在", kbParam.Pvc)
+	}
+	return storage, nil
+}
+
+func (b *MemoryBackend) WaitVolumeReady(kbParam *model.KubernetesParam, timeout time.Duration) error {
+	_, err := b.VolumeSize(kbParam)
+	return err
+}
+
+// ReleaseVolume 进程内后端没有辅助 Pod
+func (b *MemoryBackend) ReleaseVolume(kbParam *model.KubernetesParam) error {
+	return nil
+}
+
+func (b *MemoryBackend) Password(kbParam *model.KubernetesParam) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	password, ok := b.secrets[kbParam.Namespace+"/"+kbParam.Secret]
+	if !ok {
+		return "", fmt.Errorf("获取 Secret %s 失败: 不存在", kbParam.Secret)
+	}
+	return password, nil
+}
+
+// CreateWorkload 创建工作空间，数据卷需要已经存在
+func (b *MemoryBackend) CreateWorkload(kbParam *model.KubernetesParam, appParam *model.AppParam, template *model.WorkspaceTemplate) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := memoryKeyOf(kbParam)
+	if _, ok := b.workspaces[key]; ok {
+		return nil
+	}
+	storage, ok := b.volumes[kbParam.Namespace+"/"+kbParam.Pvc]
+	if !ok {
+		return fmt.Errorf("创建Deployment失败: 数据卷 %s 不存在", kbParam.Pvc)
+	}
+
+	quota := b.quotas[kbParam.Namespace]
+	if quota == nil {
+		quota = &model.NamespaceQuota{}
+	}
+	request, err := WorkspaceRequest(appParam.Cpu, appParam.Memory, storage, quota)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	b.workspaces[key] = &memoryWorkspace{
+		namespace:  kbParam.Namespace,
+		deployment: kbParam.Deployment,
+		image:      template.Image,
+		request:    request,
+		replicas:   1,
+		startedAt:  now,
+		logs:       []string{fmt.Sprintf("%s [dev] 工作空间 %s 已创建", now.Format(time.RFC3339), kbParam.Deployment)},
+	}
+	return nil
+}
+
+func (b *MemoryBackend) DeleteWorkload(kbParam *model.KubernetesParam) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.workspaces, memoryKeyOf(kbParam))
+	return nil
+}
+
+func (b *MemoryBackend) Scale(kbParam *model.KubernetesParam, replicas int32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	workspace, err := b.workspaceOf(kbParam)
+	if err != nil {
+		return err
+	}
+	if workspace.replicas == 0 && replicas > 0 {
+		workspace.startedAt = time.Now()
+	}
+	workspace.replicas = replicas
+	workspace.logs = append(workspace.logs, fmt.Sprintf("%s [dev] 副本数修改为 %d", time.Now().Format(time.RFC3339), replicas))
+	return nil
+}
+
+// WaitStopped 进程内的工作空间缩容后立即停止，只检查副本数
+func (b *MemoryBackend) WaitStopped(kbParam *model.KubernetesParam, timeout time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	workspace, err := b.workspaceOf(kbParam)
+	if err != nil {
+		return err
+	}
+	if workspace.replicas > 0 {
+		return fmt.Errorf("工作空间 %s 仍在运行", kbParam.Deployment)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Expose(kbParam *model.KubernetesParam, application *model.Application) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	workspace, err := b.workspaceOf(kbParam)
+	if err != nil {
+		return err
+	}
+	if workspace.port == 0 {
+		workspace.port = b.nextPort
+		b.nextPort++
+	}
+	workspace.exposed = true
+	application.Url = fmt.Sprintf("http://127.0.0.1:%d", workspace.port)
+	return nil
+}
+
+func (b *MemoryBackend) Unexpose(kbParam *model.KubernetesParam) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if workspace, ok := b.workspaces[memoryKeyOf(kbParam)]; ok {
+		workspace.exposed = false
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Destroy(kbParam *model.KubernetesParam) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.workspaces, memoryKeyOf(kbParam))
+	delete(b.volumes, kbParam.Namespace+"/"+kbParam.Pvc)
+	delete(b.secrets, kbParam.Namespace+"/"+kbParam.Secret)
+	return nil
+}
+
+func (b *MemoryBackend) Status(kbParam *model.KubernetesParam) (*WorkspaceStatus, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	workspace, err := b.workspaceOf(kbParam)
+	if err != nil {
+		return nil, err
+	}
+	status := &WorkspaceStatus{Replicas: workspace.replicas}
+	if workspace.replicas > 0 {
+		status.Pod = workspace.pod()
+	}
+	return status, nil
+}
+
+func (b *MemoryBackend) Logs(kbParam *model.KubernetesParam, options *corev1.PodLogOptions) (io.ReadCloser, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	workspace, err := b.workspaceOf(kbParam)
+	if err != nil {
+		return nil, err
+	}
+	lines := workspace.logs
+	if options != nil && options.TailLines != nil && int(*options.TailLines) < len(lines) {
+		lines = lines[len(lines)-int(*options.TailLines):]
+	}
+	return io.NopCloser(strings.NewReader(strings.Join(lines, "\n") + "\n")), nil
+}
+
+func (b *MemoryBackend) workspaceOf(kbParam *model.KubernetesParam) (*memoryWorkspace, error) {
+	workspace, ok := b.workspaces[memoryKeyOf(kbParam)]
+	if !ok {
+		return nil, fmt.Errorf("获取Deployment信息失败: 工作空间 %s 不存在", kbParam.Deployment)
+	}
+	return workspace, nil
+}
+
+// pod 构造与真实 Pod 结构一致的对象，状态始终为就绪
+func (w *memoryWorkspace) pod() *corev1.Pod {
+	startTime := metav1.NewTime(w.startedAt)
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    w.request[corev1.ResourceLimitsCPU],
+		corev1.ResourceMemory: w.request[corev1.ResourceLimitsMemory],
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      w.deployment + "-dev",
+			Namespace: w.namespace,
+			Labels: map[string]string{
+				"app":        "code-server",
+				"deployment": w.deployment,
+			},
+			CreationTimestamp: startTime,
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "dev",
+			Containers: []corev1.Container{{
+				Name:      model.ContainerCodeServer,
+				Image:     w.image,
+				Resources: corev1.ResourceRequirements{Requests: resources, Limits: resources},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:     corev1.PodRunning,
+			StartTime: &startTime,
+			Conditions: []corev1.PodCondition{{
+				Type:   corev1.PodReady,
+				Status: corev1.ConditionTrue,
+			}},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  model.ContainerCodeServer,
+				Image: w.image,
+				Ready: true,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: startTime}},
+			}},
+		},
+	}
+}
+
+func memoryKeyOf(kbParam *model.KubernetesParam) string {
+	return kbParam.Namespace + "/" + kbParam.Deployment
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// MetricsFetcher 从 metrics.k8s.io 读取 Pod 的实时用量，测试时可替换为假实现
+type MetricsFetcher interface {
+	GetPodMetrics(ctx context.Context, namespace, name string) (*metricsv1beta1.PodMetrics, error)
+	// ListPodMetrics 列出所有命名空间中匹配标签选择器的 Pod 用量
+	ListPodMetrics(ctx context.Context, labelSelector string) ([]metricsv1beta1.PodMetrics, error)
+}
+
+// Metrics 全局使用的用量查询实现
+var Metrics MetricsFetcher
+
+// InitMetricsClient 初始化 metrics-server 客户端，需要在 InitDynamicClient 之后调用
+func InitMetricsClient() {
+	log.Printf("初始化 Metrics 客户端...")
+
+	client, err := metricsclient.NewForConfig(RestConfig)
+	if err != nil {
+		log.Fatalf("创建 Metrics 客户端失败: %v", err)
+	}
+
+	Metrics = &metricsServerFetcher{client: client}
+	log.Printf("初始化 Metrics 客户端完毕")
+}
+
+type metricsServerFetcher struct {
+	client metricsclient.Interface
+}
+
+func (f *metricsServerFetcher) GetPodMetrics(ctx context.Context, namespace, name string) (*metricsv1beta1.PodMetrics, error) {
+	podMetrics, err := f.client.MetricsV1beta1().PodMetricses(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取 Pod 用量失败: %w", err)
+	}
+	return podMetrics, nil
+}
+
+func (f *metricsServerFetcher) ListPodMetrics(ctx context.Context, labelSelector string) ([]metricsv1beta1.PodMetrics, error) {
+	list, err := f.client.MetricsV1beta1().PodMetricses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("获取 Pod 用量列表失败: %w", err)
+	}
+	return list.Items, nil
+}
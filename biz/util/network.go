@@ -0,0 +1,200 @@
+package util
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// EnsureNetworkPolicies 为用户命名空间同步网络隔离策略：
+// 默认拒绝所有进出流量，只放行来自访问入口（NodePort、Ingress 控制器或 Gateway）的入站流量，
+// 以及到集群 DNS、heartbeater 使用的 Kafka、EGRESS_PROXY 代理和 EGRESS_ALLOW_CIDRS 白名单的出站流量
+func (s *KubernetesUtil) EnsureNetworkPolicies(namespace string) error {
+	policies := []*networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "default-deny"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-workspace-ingress"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "code-server"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: ingressPeers()}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "allow-workspace-egress"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress:      egressRules(),
+			},
+		},
+	}
+
+	client := s.client.NetworkingV1().NetworkPolicies(namespace)
+	for _, policy := range policies {
+		policy.Namespace = namespace
+		policy.Labels = map[string]string{"created-by": "hertz"}
+
+		existing, err := client.Get(s.ctx, policy.Name, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			_, err = client.Create(s.ctx, policy, metav1.CreateOptions{})
+		case err == nil:
+			existing.Spec = policy.Spec
+			_, err = client.Update(s.ctx, existing, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			log.Printf("同步 NetworkPolicy %s 失败: %v", policy.Name, err)
+			return fmt.Errorf("同步网络策略失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// SyncNetworkPolicies 为所有已有的用户命名空间同步网络隔离策略，
+// 策略或配置更新后，在此之前创建的命名空间也能生效
+func (s *KubernetesUtil) SyncNetworkPolicies() error {
+	namespaces, err := s.ListUserNamespaces()
+	if err != nil {
+		return fmt.Errorf("查询用户命名空间失败: %w", err)
+	}
+	for _, namespace := range namespaces {
+		if err := s.EnsureNetworkPolicies(namespace); err != nil {
+			log.Printf("同步命名空间 %s 的网络策略失败: %v", namespace, err)
+		}
+	}
+	return nil
+}
+
+// ingressPeers 按 EXPOSE_MODE 放行访问入口所在的来源
+// NodePort 的流量来自集群外部，放行除 Pod 网段（CLUSTER_POD_CIDR）以外的地址，防止其他用户的 Pod 直接访问
+func ingressPeers() []networkingv1.NetworkPolicyPeer {
+	switch ExposeMode() {
+	case ExposeModeIngress:
+		return []networkingv1.NetworkPolicyPeer{namespacePeer(GetEnvOrDefault("EXPOSE_INGRESS_NAMESPACE", "ingress-nginx"))}
+	case ExposeModeHttpRoute:
+		return []networkingv1.NetworkPolicyPeer{namespacePeer(GetEnvOrDefault("EXPOSE_GATEWAY_NAMESPACE", "minics"))}
+	default:
+		return []networkingv1.NetworkPolicyPeer{{
+			IPBlock: &networkingv1.IPBlock{
+				CIDR:   "0.0.0.0/0",
+				Except: splitList(GetEnvOrDefault("CLUSTER_POD_CIDR", "10.244.0.0/16")),
+			},
+		}}
+	}
+}
+
+func egressRules() []networkingv1.NetworkPolicyEgressRule {
+	udp := corev1.ProtocolUDP
+	tcp := corev1.ProtocolTCP
+	dnsPort := intstr.FromInt32(53)
+
+	rules := []networkingv1.NetworkPolicyEgressRule{
+		{
+			// 集群 DNS
+			To: []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "kube-system"}},
+				PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+			}},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dnsPort},
+				{Protocol: &tcp, Port: &dnsPort},
+			},
+		},
+		{
+			// heartbeater 上报心跳使用的 Kafka
+			To: []networkingv1.NetworkPolicyPeer{namespacePeer(GetEnvOrDefault("KAFKA_NAMESPACE", "minics"))},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &tcp, Port: kafkaPort()},
+			},
+		},
+	}
+
+	// 模板中配置的代理，格式为 <IP>:<端口>，设置为 - 时不放行
+	// 代理部署在节点上，只放行代理端口，放行整个地址会让工作空间通过 NodePort 访问其他用户的工作空间
+	if proxy := GetEnvOrDefault("EGRESS_PROXY", "223.2.19.172:3128"); proxy != "-" {
+		if rule, err := proxyRule(proxy); err != nil {
+			log.Printf("EGRESS_PROXY 格式错误: %v", err)
+		} else {
+			rules = append(rules, *rule)
+		}
+	}
+
+	// 镜像仓库等外部地址白名单，默认为空
+	// 不能包含节点地址（NODE_ADDRESS）或其他 NodePort 可达的地址，否则工作空间可以访问其他用户的工作空间
+	var allowed []networkingv1.NetworkPolicyPeer
+	for _, cidr := range splitList(GetEnvOrDefault("EGRESS_ALLOW_CIDRS", "")) {
+		allowed = append(allowed, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	if len(allowed) > 0 {
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: allowed})
+	}
+	return rules
+}
+
+func proxyRule(proxy string) (*networkingv1.NetworkPolicyEgressRule, error) {
+	host, portValue, err := net.SplitHostPort(proxy)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("代理地址必须是 IP: %s", host)
+	}
+	port, err := strconv.Atoi(portValue)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("代理端口错误: %s", portValue)
+	}
+
+	cidr := ip.String() + "/32"
+	if ip.To4() == nil {
+		cidr = ip.String() + "/128"
+	}
+	tcp := corev1.ProtocolTCP
+	target := intstr.FromInt32(int32(port))
+	return &networkingv1.NetworkPolicyEgressRule{
+		To:    []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}},
+		Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &target}},
+	}, nil
+}
+
+func namespacePeer(namespace string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: namespace}},
+	}
+}
+
+func kafkaPort() *intstr.IntOrString {
+	port, err := strconv.Atoi(GetEnvOrDefault("KAFKA_PORT", "9092"))
+	if err != nil {
+		port = 9092
+	}
+	value := intstr.FromInt32(int32(port))
+	return &value
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,9 +1,14 @@
 package util
 
 import (
+	"crypto/rand"
+	"math/big"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+const passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+
 // HashPassword 对密码进行哈希加密
 func HashPassword(password string) (string, error) {
 	// 使用 bcrypt 默认成本（10）进行哈希
@@ -33,3 +38,17 @@ func HashPasswordWithCost(password string, cost int) (string, error) {
 	}
 	return string(hashedBytes), nil
 }
+
+// GeneratePassword 生成指定长度的随机密码，去掉了容易混淆的字符
+func GeneratePassword(length int) (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
@@ -0,0 +1,189 @@
+package util
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"learn/biz/model"
+)
+
+const (
+	ResourceQuotaName = "workspace-quota"
+	LimitRangeName    = "workspace-limits"
+)
+
+var quotaResourceNames = map[corev1.ResourceName]string{
+	corev1.ResourceRequestsCPU:            "CPU",
+	corev1.ResourceLimitsCPU:              "CPU",
+	corev1.ResourceRequestsMemory:         "内存",
+	corev1.ResourceLimitsMemory:           "内存",
+	corev1.ResourceRequestsStorage:        "存储",
+	corev1.ResourcePersistentVolumeClaims: "数据卷数量",
+	corev1.ResourcePods:                   "Pod 数量",
+}
+
+// EnsureQuota 按配额创建或更新命名空间中的 ResourceQuota 和 LimitRange
+func (s *KubernetesUtil) EnsureQuota(namespace string, quota *model.NamespaceQuota) error {
+	hard, err := quotaHardOf(quota)
+	if err != nil {
+		return err
+	}
+	defaults, err := parseResourceList(map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    quota.DefaultCpu,
+		corev1.ResourceMemory: quota.DefaultMemory,
+	})
+	if err != nil {
+		return err
+	}
+
+	labels := map[string]string{"created-by": "hertz"}
+	resourceQuota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: ResourceQuotaName, Namespace: namespace, Labels: labels},
+		Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+	}
+	limitRange := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: LimitRangeName, Namespace: namespace, Labels: labels},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				Default:        defaults,
+				DefaultRequest: defaults,
+			}},
+		},
+	}
+
+	quotas := s.client.CoreV1().ResourceQuotas(namespace)
+	existingQuota, err := quotas.Get(s.ctx, ResourceQuotaName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = quotas.Create(s.ctx, resourceQuota, metav1.CreateOptions{})
+	case err == nil:
+		existingQuota.Spec = resourceQuota.Spec
+		_, err = quotas.Update(s.ctx, existingQuota, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		log.Printf("同步 ResourceQuota 失败: %v", err)
+		return fmt.Errorf("同步资源配额失败: %w", err)
+	}
+
+	limitRanges := s.client.CoreV1().LimitRanges(namespace)
+	existingLimitRange, err := limitRanges.Get(s.ctx, LimitRangeName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = limitRanges.Create(s.ctx, limitRange, metav1.CreateOptions{})
+	case err == nil:
+		existingLimitRange.Spec = limitRange.Spec
+		_, err = limitRanges.Update(s.ctx, existingLimitRange, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		log.Printf("同步 LimitRange 失败: %v", err)
+		return fmt.Errorf("同步默认资源限制失败: %w", err)
+	}
+	return nil
+}
+
+// CheckQuota 检查在当前用量上再申请 request 是否会超出配额，超出时返回说明哪项不足的错误
+// 命名空间中没有 ResourceQuota 时不做限制
+func (s *KubernetesUtil) CheckQuota(namespace string, request corev1.ResourceList) error {
+	quota, err := s.client.CoreV1().ResourceQuotas(namespace).Get(s.ctx, ResourceQuotaName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("获取资源配额失败: %w", err)
+	}
+
+	return checkQuotaUsage(quota.Spec.Hard, quota.Status.Hard, quota.Status.Used, request)
+}
+
+// checkQuotaUsage 逐项比较已用量加上 request 是否超过上限，statusHard 为空时使用 specHard
+func checkQuotaUsage(specHard, statusHard, used, request corev1.ResourceList) error {
+	var exceeded []string
+	for name, amount := range request {
+		hard, ok := statusHard[name]
+		if !ok {
+			hard, ok = specHard[name]
+		}
+		if !ok {
+			continue
+		}
+
+		total := used[name].DeepCopy()
+		total.Add(amount)
+		if total.Cmp(hard) > 0 {
+			current := used[name]
+			exceeded = append(exceeded, fmt.Sprintf("%s 已用 %s，本次申请 %s，上限 %s",
+				quotaResourceNames[name], current.String(), amount.String(), hard.String()))
+		}
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("超出资源配额: %s", strings.Join(exceeded, "; "))
+	}
+	return nil
+}
+
+// WorkspaceRequest 计算一个工作空间需要占用的配额，sidecar 按 LimitRange 的默认规格计算
+func WorkspaceRequest(cpu, memory, storage string, quota *model.NamespaceQuota) (corev1.ResourceList, error) {
+	cpuQuantity, err := resource.ParseQuantity(cpu)
+	if err != nil {
+		return nil, fmt.Errorf("CPU 规格错误: %w", err)
+	}
+	memoryQuantity, err := resource.ParseQuantity(memory)
+	if err != nil {
+		return nil, fmt.Errorf("内存规格错误: %w", err)
+	}
+	storageQuantity, err := resource.ParseQuantity(storage)
+	if err != nil {
+		return nil, fmt.Errorf("存储规格错误: %w", err)
+	}
+	if defaultCpu, err := resource.ParseQuantity(quota.DefaultCpu); err == nil {
+		cpuQuantity.Add(defaultCpu)
+	}
+	if defaultMemory, err := resource.ParseQuantity(quota.DefaultMemory); err == nil {
+		memoryQuantity.Add(defaultMemory)
+	}
+
+	return corev1.ResourceList{
+		corev1.ResourceRequestsCPU:            cpuQuantity,
+		corev1.ResourceLimitsCPU:              cpuQuantity,
+		corev1.ResourceRequestsMemory:         memoryQuantity,
+		corev1.ResourceLimitsMemory:           memoryQuantity,
+		corev1.ResourceRequestsStorage:        storageQuantity,
+		corev1.ResourcePersistentVolumeClaims: *resource.NewQuantity(1, resource.DecimalSI),
+		corev1.ResourcePods:                   *resource.NewQuantity(1, resource.DecimalSI),
+	}, nil
+}
+
+func quotaHardOf(quota *model.NamespaceQuota) (corev1.ResourceList, error) {
+	hard, err := parseResourceList(map[corev1.ResourceName]string{
+		corev1.ResourceRequestsCPU:     quota.Cpu,
+		corev1.ResourceLimitsCPU:       quota.Cpu,
+		corev1.ResourceRequestsMemory:  quota.Memory,
+		corev1.ResourceLimitsMemory:    quota.Memory,
+		corev1.ResourceRequestsStorage: quota.Storage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	hard[corev1.ResourcePersistentVolumeClaims] = *resource.NewQuantity(quota.Pvcs, resource.DecimalSI)
+	hard[corev1.ResourcePods] = *resource.NewQuantity(quota.Pods, resource.DecimalSI)
+	return hard, nil
+}
+
+func parseResourceList(values map[corev1.ResourceName]string) (corev1.ResourceList, error) {
+	list := corev1.ResourceList{}
+	for name, value := range values {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("配额 %s 的值 %q 错误: %w", name, value, err)
+		}
+		list[name] = quantity
+	}
+	return list, nil
+}
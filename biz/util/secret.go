@@ -0,0 +1,199 @@
+package util
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"learn/biz/model"
+)
+
+// PasswordSecretKey 工作空间 Secret 中保存 code-server 密码的键
+const PasswordSecretKey = "password"
+
+// ApplyPasswordSecret 创建或更新工作空间的密码 Secret
+func (s *KubernetesUtil) ApplyPasswordSecret(kbParam *model.KubernetesParam, password string) error {
+	secrets := s.client.CoreV1().Secrets(kbParam.Namespace)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kbParam.Secret,
+			Namespace: kbParam.Namespace,
+			Labels: map[string]string{
+				"app":        "code-server",
+				"deployment": kbParam.Deployment,
+			},
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: map[string]string{PasswordSecretKey: password},
+	}
+
+	existing, err := secrets.Get(s.ctx, kbParam.Secret, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = secrets.Create(s.ctx, secret, metav1.CreateOptions{})
+	case err == nil:
+		existing.Data = nil
+		existing.StringData = secret.StringData
+		_, err = secrets.Update(s.ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		log.Printf("保存密码 Secret 失败: %v", err)
+		return fmt.Errorf("保存密码失败: %w", err)
+	}
+	return nil
+}
+
+func (s *KubernetesUtil) GetPassword(kbParam *model.KubernetesParam) (string, error) {
+	secret, err := s.GetSecret(kbParam.Namespace, kbParam.Secret)
+	if err != nil {
+		return "", err
+	}
+	return string(secret.Data[PasswordSecretKey]), nil
+}
+
+// codeServerEnv 读取 Deployment 中 code-server 容器的环境变量
+func codeServerEnv(deployment *appsv1.Deployment, name string) string {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name != model.ContainerCodeServer {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == name {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+func (s *KubernetesUtil) DeleteSecret(kbParam *model.KubernetesParam) error {
+	// 旧版本创建的应用没有密码 Secret
+	if kbParam.Secret == "" {
+		return nil
+	}
+	if err := s.client.CoreV1().Secrets(kbParam.Namespace).Delete(s.ctx, kbParam.Secret, metav1.DeleteOptions{}); err != nil {
+		log.Printf("删除 Secret 失败: %v", err)
+		return fmt.Errorf("删除 Secret 失败: %w", err)
+	}
+	return nil
+}
+
+// RolloutPassword 让 code-server 容器从 Secret 读取密码并触发滚动重启，使新密码生效
+// 旧版本直接写在环境变量中的密码也会在这里迁移到 Secret 引用
+func (s *KubernetesUtil) RolloutPassword(kbParam *model.KubernetesParam) error {
+	deployment, err := s.client.AppsV1().Deployments(kbParam.Namespace).Get(s.ctx, kbParam.Deployment, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("获取Deployment信息失败: %w", err)
+	}
+
+	podSpec := &deployment.Spec.Template.Spec
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name != model.ContainerCodeServer {
+			continue
+		}
+		env := podSpec.Containers[i].Env[:0]
+		for _, e := range podSpec.Containers[i].Env {
+			if e.Name != "PASSWORD" && e.Name != "SUDO_PASSWORD" {
+				env = append(env, e)
+			}
+		}
+		podSpec.Containers[i].Env = append(env, passwordEnv(kbParam)...)
+	}
+
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = time.Now().Format(time.RFC3339)
+
+	_, err = s.client.AppsV1().Deployments(kbParam.Namespace).Update(s.ctx, deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("更新Deployment失败: %w", err)
+	}
+	return nil
+}
+
+// passwordEnv code-server 的登录密码与 sudo 密码，都引用工作空间的密码 Secret
+func passwordEnv(kbParam *model.KubernetesParam) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		secretEnv("PASSWORD", kbParam.Secret, PasswordSecretKey),
+		secretEnv("SUDO_PASSWORD", kbParam.Secret, PasswordSecretKey),
+	}
+}
+
+// gitCredentialLabel 标记用户通过接口创建的仓库凭据 Secret，只有带此标签的 Secret 可以被列出、覆盖和删除
+const gitCredentialLabel = "git-credential"
+
+// ApplyGitCredential 创建或更新仓库凭据 Secret，同名的其他 Secret（如工作空间的密码 Secret）不会被覆盖
+func (s *KubernetesUtil) ApplyGitCredential(namespace string, credential *model.GitCredential) error {
+	secrets := s.client.CoreV1().Secrets(namespace)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      credential.Name,
+			Namespace: namespace,
+			Labels:    map[string]string{"type": gitCredentialLabel},
+		},
+		Type: corev1.SecretTypeOpaque,
+		StringData: map[string]string{
+			"username": credential.Username,
+			"password": credential.Password,
+		},
+	}
+
+	existing, err := secrets.Get(s.ctx, credential.Name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = secrets.Create(s.ctx, secret, metav1.CreateOptions{})
+	case err == nil:
+		if existing.Labels["type"] != gitCredentialLabel {
+			return fmt.Errorf("名称 %s 已被占用", credential.Name)
+		}
+		existing.Data = nil
+		existing.StringData = secret.StringData
+		_, err = secrets.Update(s.ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		log.Printf("保存仓库凭据失败: %v", err)
+		return fmt.Errorf("保存仓库凭据失败: %w", err)
+	}
+	return nil
+}
+
+// ListGitCredentials 列出命名空间中的仓库凭据，只返回名称和用户名
+func (s *KubernetesUtil) ListGitCredentials(namespace string) ([]*model.GitCredential, error) {
+	list, err := s.client.CoreV1().Secrets(namespace).List(s.ctx, metav1.ListOptions{
+		LabelSelector: "type=" + gitCredentialLabel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取仓库凭据列表失败: %w", err)
+	}
+
+	credentials := make([]*model.GitCredential, 0, len(list.Items))
+	for _, secret := range list.Items {
+		credentials = append(credentials, &model.GitCredential{
+			Name:     secret.Name,
+			Username: string(secret.Data["username"]),
+		})
+	}
+	return credentials, nil
+}
+
+// DeleteGitCredential 删除仓库凭据，不是仓库凭据的 Secret 按不存在处理
+func (s *KubernetesUtil) DeleteGitCredential(namespace, name string) error {
+	secret, err := s.client.CoreV1().Secrets(namespace).Get(s.ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) || (err == nil && secret.Labels["type"] != gitCredentialLabel) {
+		return fmt.Errorf("仓库凭据 %s 不存在", name)
+	}
+	if err != nil {
+		return fmt.Errorf("获取仓库凭据失败: %w", err)
+	}
+	if err := s.client.CoreV1().Secrets(namespace).Delete(s.ctx, name, metav1.DeleteOptions{}); err != nil {
+		log.Printf("删除仓库凭据失败: %v", err)
+		return fmt.Errorf("删除仓库凭据失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var volumeSnapshotGVR = schema.GroupVersionResource{
+	Group:    "snapshot.storage.k8s.io",
+	Version:  "v1",
+	Resource: "volumesnapshots",
+}
+
+// SnapshotStatus VolumeSnapshot 的状态摘要
+type SnapshotStatus struct {
+	ReadyToUse  bool
+	RestoreSize string
+	Error       string
+}
+
+// SnapshotUtil 通过动态客户端操作 CSI VolumeSnapshot，测试时可传入 fake 动态客户端
+type SnapshotUtil struct {
+	ctx    context.Context
+	client dynamic.Interface
+}
+
+func NewSnapshotUtil(ctx context.Context, client dynamic.Interface) *SnapshotUtil {
+	return &SnapshotUtil{ctx: ctx, client: client}
+}
+
+// CreateSnapshot 为 PVC 创建 VolumeSnapshot，snapshotClass 为空时使用集群默认的快照类
+func (s *SnapshotUtil) CreateSnapshot(namespace, name, pvc, snapshotClass string) error {
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvc,
+		},
+	}
+	if snapshotClass != "" {
+		spec["volumeSnapshotClassName"] = snapshotClass
+	}
+
+	snapshot := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+			"labels": map[string]interface{}{
+				"app": "code-server",
+				"pvc": pvc,
+			},
+		},
+		"spec": spec,
+	}}
+
+	_, err := s.client.Resource(volumeSnapshotGVR).Namespace(namespace).Create(s.ctx, snapshot, metav1.CreateOptions{})
+	if err != nil {
+		log.Printf("创建快照失败: %v", err)
+		return fmt.Errorf("创建快照失败: %w", err)
+	}
+	return nil
+}
+
+func (s *SnapshotUtil) GetSnapshotStatus(namespace, name string) (*SnapshotStatus, error) {
+	snapshot, err := s.client.Resource(volumeSnapshotGVR).Namespace(namespace).Get(s.ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取快照失败: %w", err)
+	}
+
+	status := &SnapshotStatus{}
+	status.ReadyToUse, _, _ = unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	status.RestoreSize, _, _ = unstructured.NestedString(snapshot.Object, "status", "restoreSize")
+	status.Error, _, _ = unstructured.NestedString(snapshot.Object, "status", "error", "message")
+	return status, nil
+}
+
+func (s *SnapshotUtil) DeleteSnapshot(namespace, name string) error {
+	err := s.client.Resource(volumeSnapshotGVR).Namespace(namespace).Delete(s.ctx, name, metav1.DeleteOptions{})
+	if err != nil {
+		log.Printf("删除快照失败: %v", err)
+		return fmt.Errorf("删除快照失败: %w", err)
+	}
+	return nil
+}
@@ -3,15 +3,24 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"learn/biz/config"
 	"learn/biz/middleware"
+	"learn/biz/model"
+	"learn/biz/service"
 	"learn/biz/task"
+	"learn/biz/util"
+	"log"
 	"sync"
 
 	"github.com/cloudwego/hertz/pkg/app/server"
 	"github.com/hertz-contrib/logger/accesslog"
 )
 
+// devMode 以 --dev 启动时不连接集群，工作空间由进程内的后端模拟，便于在本地调试接口
+var devMode = flag.Bool("dev", false, "不连接Kubernetes集群，使用进程内的工作空间后端")
+
 func Init() {
 	wg := sync.WaitGroup{}
 	wg.Add(5)
@@ -33,19 +42,57 @@ func Init() {
 		wg.Done()
 	}()
 	go func() {
+		if *devMode {
+			util.UseMemoryBackend()
+			log.Println("以开发模式启动，工作空间不会真正运行")
+			wg.Done()
+			return
+		}
 		config.InitKubernetesClient()
+		util.InitDynamicClient()
+		util.InitMetricsClient()
+		util.InitWorkspaceCache()
 		wg.Done()
 	}()
 	wg.Wait()
+
+	if err := model.AutoMigrate(config.DB); err != nil {
+		log.Fatalf("同步数据表结构失败: %v", err)
+	}
+
+	// 为升级前创建的用户命名空间补上网络隔离策略
+	if !*devMode {
+		if err := util.NewKubernetesUtil(context.Background()).SyncNetworkPolicies(); err != nil {
+			log.Printf("同步网络策略失败: %v", err)
+		}
+	}
+
+	// 回滚心跳已过期的创建流程，之后由定时任务定期检查
+	service.RecoverInterruptedProvisions(context.Background())
 }
 
 func main() {
+	flag.Parse()
 	Init()
 
-	// 创建HTTP服务器
-	h := server.Default()
+	// 启动空闲检查、启停计划等定时任务，按运行时间计量的任务需要设置 USAGE_ACCOUNTING=true 才会启动
+	timerService := task.NewTimerService(context.Background())
+	timerService.Start()
+
+	// 创建HTTP服务器，请求体以流的方式读取，不预先解析 multipart 表单，上传的文件在读取表单时写入临时文件而不是整体放在内存中
+	// 只有文件上传接口按上传大小放宽请求体上限，并留出表单其余字段的余量
+	h := server.Default(server.WithStreamBody(true), server.WithDisablePreParseMultipartForm(true))
 	h.Use(accesslog.New())
+	h.Use(middleware.MaxBodySize(util.MaxRequestBodySize(), map[string]int64{
+		"/app/common/files/upload": util.MaxUploadSize() + 1<<20,
+	}))
 	register(h)
+	h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) {
+		timerService.Stop()
+		if util.Workspaces != nil {
+			util.Workspaces.Shutdown()
+		}
+	})
 
 	h.Spin()
 }